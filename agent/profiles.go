@@ -0,0 +1,174 @@
+package agent
+
+// Header is a request header name and value.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Profile describes the headers a real browser sends when navigating, so
+// requests are consistent with the user agent they claim to come from.
+type Profile struct {
+	// Name identifies the profile, eg "chrome-desktop".
+	Name string
+
+	// UserAgent is the User-Agent header value.
+	UserAgent string
+
+	// Headers are the other request headers sent with every request.
+	Headers []Header
+
+	// HeaderOrder is the order the browser sends its request headers in.
+	// Headers which are not listed are sent after the listed ones.
+	HeaderOrder []string
+
+	// FetchSite is whether the browser sends the Sec-Fetch-Site header, which
+	// depends on what started each navigation and is not part of Headers.
+	FetchSite bool
+
+	// FetchUser is whether the browser sends the Sec-Fetch-User header with
+	// navigations started by the user.
+	FetchUser bool
+}
+
+// chromeAccept is the Accept header Chrome sends for navigations.
+const chromeAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+
+// firefoxAccept is the Accept header Firefox sends for navigations.
+const firefoxAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+// safariAccept is the Accept header Safari sends for navigations.
+const safariAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// chromeOrder is the order Chrome sends the headers of navigations in.
+var chromeOrder = []string{
+	"Host", "Connection", "Content-Length", "Cache-Control", "sec-ch-ua",
+	"sec-ch-ua-mobile", "sec-ch-ua-platform", "Upgrade-Insecure-Requests",
+	"Origin", "Content-Type", "User-Agent", "Accept", "Sec-Fetch-Site",
+	"Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest", "Referer",
+	"Accept-Encoding", "Accept-Language", "Cookie",
+}
+
+// firefoxOrder is the order Firefox sends the headers of navigations in.
+var firefoxOrder = []string{
+	"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding",
+	"Content-Type", "Content-Length", "Origin", "Connection", "Referer",
+	"Cookie", "Upgrade-Insecure-Requests", "Sec-Fetch-Dest", "Sec-Fetch-Mode",
+	"Sec-Fetch-Site", "Sec-Fetch-User",
+}
+
+// safariOrder is the order Safari sends the headers of navigations in.
+var safariOrder = []string{
+	"Host", "Content-Type", "Origin", "Accept", "Sec-Fetch-Site", "Cookie",
+	"Sec-Fetch-Dest", "Content-Length", "Accept-Language", "Sec-Fetch-Mode",
+	"User-Agent", "Referer", "Accept-Encoding", "Connection",
+}
+
+// chromeClientHints is the sec-ch-ua header value sent by Chrome.
+const chromeClientHints = `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`
+
+var (
+	// ChromeDesktop emulates Chrome on Windows.
+	ChromeDesktop = Profile{
+		Name:      "chrome-desktop",
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		Headers: []Header{
+			{"sec-ch-ua", chromeClientHints},
+			{"sec-ch-ua-mobile", "?0"},
+			{"sec-ch-ua-platform", `"Windows"`},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Accept", chromeAccept},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+		},
+		HeaderOrder: chromeOrder,
+		FetchSite:   true,
+		FetchUser:   true,
+	}
+
+	// ChromeMobile emulates Chrome on Android.
+	ChromeMobile = Profile{
+		Name:      "chrome-mobile",
+		UserAgent: "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+		Headers: []Header{
+			{"sec-ch-ua", chromeClientHints},
+			{"sec-ch-ua-mobile", "?1"},
+			{"sec-ch-ua-platform", `"Android"`},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Accept", chromeAccept},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+		},
+		HeaderOrder: chromeOrder,
+		FetchSite:   true,
+		FetchUser:   true,
+	}
+
+	// FirefoxDesktop emulates Firefox on Windows.
+	FirefoxDesktop = Profile{
+		Name:      "firefox-desktop",
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		Headers: []Header{
+			{"Accept", firefoxAccept},
+			{"Accept-Language", "en-US,en;q=0.5"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Sec-Fetch-Mode", "navigate"},
+		},
+		HeaderOrder: firefoxOrder,
+		FetchSite:   true,
+		FetchUser:   true,
+	}
+
+	// FirefoxMobile emulates Firefox on Android.
+	FirefoxMobile = Profile{
+		Name:      "firefox-mobile",
+		UserAgent: "Mozilla/5.0 (Android 14; Mobile; rv:125.0) Gecko/125.0 Firefox/125.0",
+		Headers: []Header{
+			{"Accept", firefoxAccept},
+			{"Accept-Language", "en-US,en;q=0.5"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Sec-Fetch-Mode", "navigate"},
+		},
+		HeaderOrder: firefoxOrder,
+		FetchSite:   true,
+		FetchUser:   true,
+	}
+
+	// SafariDesktop emulates Safari on macOS.
+	SafariDesktop = Profile{
+		Name:      "safari-desktop",
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+		Headers: []Header{
+			{"Accept", safariAccept},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Sec-Fetch-Dest", "document"},
+		},
+		HeaderOrder: safariOrder,
+		FetchSite:   true,
+	}
+
+	// SafariMobile emulates Safari on iOS.
+	SafariMobile = Profile{
+		Name:      "safari-mobile",
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+		Headers: []Header{
+			{"Accept", safariAccept},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Sec-Fetch-Dest", "document"},
+		},
+		HeaderOrder: safariOrder,
+		FetchSite:   true,
+	}
+)
@@ -0,0 +1,147 @@
+package browser
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAltSvcMaxAge is how long an alternative service is fresh when the
+// advertisement has no ma parameter.
+const defaultAltSvcMaxAge = 24 * time.Hour
+
+// AltService is an alternative service advertised by the Alt-Svc header.
+type AltService struct {
+	// Protocol is the ALPN protocol ID of the service, such as "h2" or "h3".
+	Protocol string
+
+	// Host is the host of the service. An empty host is the origin host.
+	Host string
+
+	// Port is the port of the service.
+	Port string
+
+	// Expires is when the advertisement expires.
+	Expires time.Time
+}
+
+// ParseAltSvc parses the value of an Alt-Svc header. Returns true as the
+// second value when the header is "clear", which withdraws every alternative
+// service of the origin.
+func ParseAltSvc(header string) ([]AltService, bool) {
+	if strings.TrimSpace(header) == "clear" {
+		return nil, true
+	}
+	var services []AltService
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		i := strings.Index(params[0], "=")
+		if i < 0 {
+			continue
+		}
+		host, port, err := net.SplitHostPort(strings.Trim(strings.TrimSpace(params[0][i+1:]), `"`))
+		if err != nil {
+			continue
+		}
+		svc := AltService{
+			Protocol: strings.TrimSpace(params[0][:i]),
+			Host:     host,
+			Port:     port,
+			Expires:  time.Now().Add(defaultAltSvcMaxAge),
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "ma=") {
+				if ma, err := strconv.ParseInt(strings.Trim(param[3:], `"`), 10, 64); err == nil {
+					svc.Expires = time.Now().Add(time.Duration(ma) * time.Second)
+				}
+			}
+		}
+		services = append(services, svc)
+	}
+	return services, false
+}
+
+// AltServices returns the unexpired alternative services advertised for the
+// origin of the given URL.
+func (bow *Browser) AltServices(u *url.URL) []AltService {
+	return bow.altServices(originAddr(u.Scheme, u.Host))
+}
+
+// altServices returns the unexpired alternative services of the origin address.
+func (bow *Browser) altServices(addr string) []AltService {
+	bow.altSvcMutex.Lock()
+	defer bow.altSvcMutex.Unlock()
+	var services []AltService
+	now := time.Now()
+	for _, svc := range bow.altSvc[addr] {
+		if svc.Expires.After(now) {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// recordAltSvc saves the alternative services advertised with the response.
+func (bow *Browser) recordAltSvc(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	header := resp.Header.Get("Alt-Svc")
+	if header == "" {
+		return
+	}
+	services, clear := ParseAltSvc(header)
+	addr := originAddr(resp.Request.URL.Scheme, resp.Request.URL.Host)
+	bow.altSvcMutex.Lock()
+	defer bow.altSvcMutex.Unlock()
+	if bow.altSvc == nil {
+		bow.altSvc = make(map[string][]AltService)
+	}
+	if clear {
+		delete(bow.altSvc, addr)
+	} else if len(services) > 0 {
+		bow.altSvc[addr] = services
+	}
+}
+
+// altSvcDialer wraps the dial function of the transport to connect to an
+// advertised h2 or http/1.1 alternative service of the origin when the
+// FollowAltSvc attribute is set. The origin is dialed when the alternative
+// cannot be reached.
+func (bow *Browser) altSvcDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if bow.attributes[FollowAltSvc] {
+			host, _, _ := net.SplitHostPort(addr)
+			for _, svc := range bow.altServices(addr) {
+				if svc.Protocol != "h2" && svc.Protocol != "http/1.1" {
+					continue
+				}
+				alt := svc.Host
+				if alt == "" {
+					alt = host
+				}
+				if conn, err := dial(ctx, network, net.JoinHostPort(alt, svc.Port)); err == nil {
+					return conn, nil
+				}
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// originAddr returns the host and port of an origin, adding the default port
+// of the scheme when the host has none.
+func originAddr(scheme, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
@@ -0,0 +1,59 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+func TestAltSvc(t *testing.T) {
+	ut.Run(t)
+	alt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "alternative")
+	}))
+	defer alt.Close()
+	altURL, _ := url.Parse(alt.URL)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", `h3=":443"; ma=60, h2=":`+altURL.Port()+`"; ma=60`)
+		fmt.Fprint(w, "origin")
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+	bow.attributes = AttributeMap{DisableKeepAlives: true}
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("origin", bow.Body())
+	services := bow.AltServices(bow.Url())
+	ut.AssertEquals(2, len(services))
+	ut.AssertEquals("h3", services[0].Protocol)
+	ut.AssertEquals("h2", services[1].Protocol)
+	ut.AssertEquals("", services[1].Host)
+	ut.AssertEquals(altURL.Port(), services[1].Port)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("origin", bow.Body())
+
+	bow.SetAttribute(FollowAltSvc, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("alternative", bow.Body())
+}
+
+func TestParseAltSvc(t *testing.T) {
+	ut.Run(t)
+	services, clear := ParseAltSvc(`h2="alt.example.com:8443"; ma=3600; persist=1, bogus`)
+	ut.AssertFalse(clear)
+	ut.AssertEquals(1, len(services))
+	ut.AssertEquals("alt.example.com", services[0].Host)
+	ut.AssertEquals("8443", services[0].Port)
+
+	_, clear = ParseAltSvc("clear")
+	ut.AssertTrue(clear)
+}
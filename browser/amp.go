@@ -0,0 +1,79 @@
+package browser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// AMPUrl returns the absolute URL of the AMP version of the page, declared by
+// a <link rel="amphtml"> element, or nil when the page has none.
+func (p *Page) AMPUrl() *url.URL {
+	return p.linkUrl(func(s *goquery.Selection) bool {
+		return matchRel(s, "amphtml") != ""
+	})
+}
+
+// MobileAlternateUrl returns the absolute URL of the mobile version of the
+// page, declared by a <link rel="alternate"> element whose media query
+// targets small screens or handheld devices, or nil when the page has none.
+func (p *Page) MobileAlternateUrl() *url.URL {
+	return p.linkUrl(func(s *goquery.Selection) bool {
+		if matchRel(s, "alternate") == "" || s.AttrOr("hreflang", "") != "" {
+			return false
+		}
+		media := strings.ToLower(s.AttrOr("media", ""))
+		return strings.Contains(media, "max-width") || strings.Contains(media, "handheld")
+	})
+}
+
+// linkUrl returns the absolute URL of the first link element of the page
+// matched by the function, or nil when there is none.
+func (p *Page) linkUrl(match func(s *goquery.Selection) bool) *url.URL {
+	rc := p.ResolutionContext()
+	var u *url.URL
+	p.Find("link[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !match(s) {
+			return true
+		}
+		if href, err := rc.resolveAttr("href", s); err == nil {
+			u = href
+		}
+		return u == nil
+	})
+	return u
+}
+
+// AMPUrl returns the URL of the AMP version of the current page, or nil.
+func (bow *Browser) AMPUrl() *url.URL {
+	return bow.Page().AMPUrl()
+}
+
+// MobileAlternateUrl returns the URL of the mobile version of the current
+// page, or nil.
+func (bow *Browser) MobileAlternateUrl() *url.URL {
+	return bow.Page().MobileAlternateUrl()
+}
+
+// OpenAMP follows the link to the AMP version of the current page, which is
+// often easier to parse than the canonical page. Returns an
+// errors.LinkNotFound when the page has no AMP version.
+func (bow *Browser) OpenAMP() error {
+	u := bow.AMPUrl()
+	if u == nil {
+		return errors.NewLinkNotFound("The page has no AMP version.")
+	}
+	return bow.httpGET(u, bow.Url(), TriggerClick)
+}
+
+// OpenMobileAlternate follows the link to the mobile version of the current
+// page. Returns an errors.LinkNotFound when the page has no mobile version.
+func (bow *Browser) OpenMobileAlternate() error {
+	u := bow.MobileAlternateUrl()
+	if u == nil {
+		return errors.NewLinkNotFound("The page has no mobile version.")
+	}
+	return bow.httpGET(u, bow.Url(), TriggerClick)
+}
@@ -0,0 +1,111 @@
+package browser
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveFunc returns the writer the body of a response is copied to as the
+// browser reads it. Returning a nil writer skips the response, and returning
+// an error fails the request.
+//
+// The writer is closed once the body has been read, even when reading fails.
+type ArchiveFunc func(resp *http.Response) (io.WriteCloser, error)
+
+// SetArchiveFunc sets the function returning the writers the body of each
+// page is copied to, which archives pages during ordinary browsing without
+// requesting them again. Passing nil stops archiving.
+//
+// The body is copied after it has been decompressed, and before it is parsed.
+func (bow *Browser) SetArchiveFunc(f ArchiveFunc) {
+	bow.archive = f
+}
+
+// archiveBody returns a reader which copies the body to the writer returned by
+// the archive function, and the function which closes the writer.
+func (bow *Browser) archiveBody(resp *http.Response, body io.Reader) (io.Reader, func(), error) {
+	if bow.archive == nil {
+		return body, func() {}, nil
+	}
+	w, err := bow.archive(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if w == nil {
+		return body, func() {}, nil
+	}
+	return io.TeeReader(body, w), func() { w.Close() }, nil
+}
+
+// TarArchive writes archived bodies to a tar stream. Each body is an entry
+// named by the host and path of its URL, eg "example.com/docs/index.html".
+//
+// Use its Archive method as the archive function of one or more browsers.
+type TarArchive struct {
+	tw    *tar.Writer
+	mutex sync.Mutex
+}
+
+// NewTarArchive creates and returns a new *TarArchive type writing to w.
+func NewTarArchive(w io.Writer) *TarArchive {
+	return &TarArchive{tw: tar.NewWriter(w)}
+}
+
+// Archive returns a writer which buffers the body of the response, and writes
+// it to the tar stream as a single entry when closed.
+func (ta *TarArchive) Archive(resp *http.Response) (io.WriteCloser, error) {
+	return &tarEntry{archive: ta, name: archiveName(resp)}, nil
+}
+
+// Close writes the end of the tar stream. The underlying writer is not closed.
+func (ta *TarArchive) Close() error {
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+	return ta.tw.Close()
+}
+
+// tarEntry buffers a body until it's written to the tar stream.
+type tarEntry struct {
+	bytes.Buffer
+	archive *TarArchive
+	name    string
+}
+
+// Close writes the buffered body to the tar stream.
+func (e *tarEntry) Close() error {
+	ta := e.archive
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+	err := ta.tw.WriteHeader(&tar.Header{
+		Name:    e.name,
+		Mode:    0644,
+		Size:    int64(e.Len()),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = e.WriteTo(ta.tw)
+	return err
+}
+
+// archiveName returns the name of the archive entry for the response.
+func archiveName(resp *http.Response) string {
+	if resp.Request == nil {
+		return "unknown"
+	}
+	u := resp.Request.URL
+	name := u.Host + u.Path
+	if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		name = strings.TrimSuffix(name, "/") + "/index.html"
+	}
+	if u.RawQuery != "" {
+		name += "?" + u.RawQuery
+	}
+	return name
+}
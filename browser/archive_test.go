@@ -0,0 +1,49 @@
+package browser
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+func TestTarArchive(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>"+r.URL.Path+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+	buf := &bytes.Buffer{}
+	archive := NewTarArchive(buf)
+	bow.SetArchiveFunc(archive.Archive)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL + "/docs/page?id=1"))
+	ut.AssertEquals("/docs/page", bow.Body())
+	ut.AssertNil(archive.Close())
+
+	host := ts.Listener.Addr().String()
+	expected := []string{host + "/index.html", host + "/docs/page?id=1"}
+	bodies := []string{"/", "/docs/page"}
+	tr := tar.NewReader(buf)
+	for i := range expected {
+		h, err := tr.Next()
+		ut.AssertNil(err)
+		ut.AssertEquals(expected[i], h.Name)
+		body, err := ioutil.ReadAll(tr)
+		ut.AssertNil(err)
+		ut.AssertEquals("<html><body>"+bodies[i]+"</body></html>", string(body))
+	}
+	_, err := tr.Next()
+	ut.AssertNotNil(err)
+}
@@ -4,6 +4,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+
+	"golang.org/x/net/html"
 )
 
 // AssetType describes a type of page asset, such as an image or stylesheet.
@@ -21,6 +23,15 @@ const (
 
 	// ScriptAsset describes a *Script asset.
 	ScriptAsset
+
+	// ResourceHintAsset describes a *ResourceHint asset.
+	ResourceHintAsset
+
+	// MediaAsset describes a *Media asset.
+	MediaAsset
+
+	// FaviconAsset describes a *Favicon asset.
+	FaviconAsset
 )
 
 // AsyncDownloadResult has the results of an asynchronous download.
@@ -64,6 +75,9 @@ type Asset struct {
 
 	// Type describes the type of asset.
 	Type AssetType
+
+	// bow is the browser which found the asset, or nil.
+	bow *Browser
 }
 
 // Url returns the asset URL.
@@ -81,6 +95,33 @@ func (at *Asset) AssetType() AssetType {
 	return at.Type
 }
 
+// owner returns the browser which found the asset, or nil.
+func (at *Asset) owner() *Browser {
+	return at.bow
+}
+
+// ownerOf returns the browser which found the asset, or nil.
+func ownerOf(asset Assetable) *Browser {
+	if o, ok := asset.(interface{ owner() *Browser }); ok {
+		return o.owner()
+	}
+	return nil
+}
+
+// assetClient returns the client of the browser which found the asset, so
+// the requests downloading it are recorded by the audit jar of the browser,
+// and each takes a request slot, or the default client when the asset has no
+// browser.
+func assetClient(asset Assetable) *http.Client {
+	bow := ownerOf(asset)
+	if bow == nil {
+		return http.DefaultClient
+	}
+	client := bow.buildClient()
+	client.Transport = &limitTransport{bow: bow, transport: client.Transport}
+	return client
+}
+
 // Downloadable represents an asset that may be downloaded.
 type Downloadable interface {
 	Assetable
@@ -112,12 +153,51 @@ func (at *DownloadableAsset) DownloadAsync(out io.Writer, ch AsyncDownloadChanne
 	DownloadAssetAsync(at, out, ch)
 }
 
+// DownloadSegmented downloads the asset using concurrent range requests.
+func (at *DownloadableAsset) DownloadSegmented(out io.WriterAt, segments int) (int64, error) {
+	return DownloadAssetSegmented(at, out, segments)
+}
+
 // Link stores the properties of a page link.
 type Link struct {
 	Asset
 
 	// Text is the text appearing between the opening and closing anchor tag.
 	Text string
+
+	// Rel are the lower case link types of the rel attribute, eg "nofollow".
+	Rel []string
+
+	// Position is the index of the link among the links of the page, in
+	// document order.
+	Position int
+
+	// node is the anchor element of the link, or nil when the link was not
+	// found in a page.
+	node *html.Node
+}
+
+// Heading returns the trimmed text of the nearest heading before the link, or
+// of the heading containing it.
+//
+// The heading is searched for when called, since finding it walks the
+// document before the link.
+func (l *Link) Heading() string {
+	if l.node == nil {
+		return ""
+	}
+	return normalizeText(nearestHeading(l.node))
+}
+
+// ListItem returns the trimmed text of the list item containing the link, or
+// an empty string when the link is not in a list.
+func (l *Link) ListItem() string {
+	for n := l.node; n != nil; n = n.Parent {
+		if n.Type == html.ElementNode && n.Data == "li" {
+			return normalizeText(nodeText(n))
+		}
+	}
+	return ""
 }
 
 // NewLinkAsset creates and returns a new *Link type.
@@ -141,6 +221,58 @@ type Image struct {
 
 	// Title is the value of the image title attribute if available.
 	Title string
+
+	// Width is the value of the width attribute, or 0 when not available.
+	Width int
+
+	// Height is the value of the height attribute, or 0 when not available.
+	Height int
+
+	// Loading is the value of the loading attribute, eg "lazy", if available.
+	Loading string
+
+	// Decoding is the value of the decoding attribute, eg "async", if available.
+	Decoding string
+
+	// HasSrcset is true when the image has a srcset attribute.
+	HasSrcset bool
+}
+
+// ImageProbe has the metadata of an image requested using the HEAD method.
+type ImageProbe struct {
+	// StatusCode is the response status code.
+	StatusCode int
+
+	// ContentLength is the size of the image in bytes, or -1 when unknown.
+	ContentLength int64
+
+	// ContentType is the value of the Content-Type header.
+	ContentType string
+}
+
+// Probe requests the image using the HEAD method, and returns its size and
+// type without downloading it.
+//
+// The request is sent by the browser which found the image when available,
+// so cookies are sent as usual.
+func (at *Image) Probe() (*ImageProbe, error) {
+	var resp *http.Response
+	var err error
+	if at.bow != nil {
+		resp, err = at.bow.httpProbe("HEAD", at.URL)
+	} else {
+		resp, err = http.Head(at.URL.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &ImageProbe{
+		StatusCode:    resp.StatusCode,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}, nil
 }
 
 // NewImageAsset creates and returns a new *Image type.
@@ -206,9 +338,106 @@ func NewScriptAsset(url *url.URL, id, typ string) *Script {
 	}
 }
 
+// ResourceHint stores the properties of a preload, prefetch, or modulepreload
+// link.
+type ResourceHint struct {
+	DownloadableAsset
+
+	// Rel is the matched link relation, eg "preload" or "prefetch".
+	Rel string
+
+	// As is the value of the as attribute, eg "script" or "font", if available.
+	As string
+}
+
+// NewResourceHintAsset creates and returns a new *ResourceHint type.
+func NewResourceHintAsset(url *url.URL, id, rel, as string) *ResourceHint {
+	return &ResourceHint{
+		DownloadableAsset: DownloadableAsset{
+			Asset: Asset{
+				URL:  url,
+				Type: ResourceHintAsset,
+				ID:   id,
+			},
+		},
+		Rel: rel,
+		As:  as,
+	}
+}
+
+// Media stores the properties of an audio or video source.
+type Media struct {
+	DownloadableAsset
+
+	// Tag is the name of the element, either "audio" or "video".
+	Tag string
+
+	// Type is the value of the type attribute if available.
+	Type string
+}
+
+// NewMediaAsset creates and returns a new *Media type.
+func NewMediaAsset(url *url.URL, id, tag, typ string) *Media {
+	return &Media{
+		DownloadableAsset: DownloadableAsset{
+			Asset: Asset{
+				URL:  url,
+				Type: MediaAsset,
+				ID:   id,
+			},
+		},
+		Tag:  tag,
+		Type: typ,
+	}
+}
+
+// Favicon stores the properties of an icon or apple-touch-icon link.
+type Favicon struct {
+	DownloadableAsset
+
+	// Sizes is the value of the sizes attribute, eg "32x32", if available.
+	Sizes string
+
+	// Type is the value of the type attribute if available.
+	Type string
+}
+
+// NewFaviconAsset creates and returns a new *Favicon type.
+func NewFaviconAsset(url *url.URL, id, sizes, typ string) *Favicon {
+	return &Favicon{
+		DownloadableAsset: DownloadableAsset{
+			Asset: Asset{
+				URL:  url,
+				Type: FaviconAsset,
+				ID:   id,
+			},
+		},
+		Sizes: sizes,
+		Type:  typ,
+	}
+}
+
+// ConnectionHint stores the properties of a dns-prefetch or preconnect link.
+type ConnectionHint struct {
+	// Rel is the matched link relation, either "dns-prefetch" or "preconnect".
+	Rel string
+
+	// Origin is the scheme and host the page expects to connect to.
+	Origin *url.URL
+}
+
 // DownloadAsset copies a remote file to the given writer.
+//
+// The download events are fired on the browser which found the asset.
 func DownloadAsset(asset Downloadable, out io.Writer) (int64, error) {
-	resp, err := http.Get(asset.Url().String())
+	return trackDownload(asset, func() (int64, error) {
+		return downloadAsset(asset, out)
+	})
+}
+
+// downloadAsset copies a remote file to the given writer without firing events.
+func downloadAsset(asset Downloadable, out io.Writer) (int64, error) {
+	resp, err := assetClient(asset).Get(asset.Url().String())
 	if err != nil {
 		return 0, err
 	}
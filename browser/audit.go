@@ -0,0 +1,92 @@
+package browser
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// triggerKey is the context key of the trigger of a navigation request.
+type triggerKey struct{}
+
+// SetAuditJar sets the jar receiving a record of every request the browser
+// sends, including the requests which follow redirects, the requests of
+// Peek(), and the requests downloading the assets found by the browser. Errors returned by the jar are ignored. Passing nil
+// stops recording.
+func (bow *Browser) SetAuditJar(aj jar.AuditJar) {
+	bow.audit = aj
+}
+
+// AuditJar returns the jar receiving the records of the requests.
+func (bow *Browser) AuditJar() jar.AuditJar {
+	return bow.audit
+}
+
+// withTrigger returns a copy of the request carrying the trigger of the
+// navigation it belongs to.
+func withTrigger(req *http.Request, trigger NavigationTrigger) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), triggerKey{}, trigger))
+}
+
+// auditTransport records each request sent through the transport once its
+// response body has been closed.
+type auditTransport struct {
+	bow       *Browser
+	transport http.RoundTripper
+}
+
+// RoundTrip sends the request, and records it.
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	audit := t.bow.audit
+	if audit == nil {
+		return t.transport.RoundTrip(req)
+	}
+	rec := &jar.AuditRecord{
+		Time:   time.Now(),
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+	if trigger, ok := req.Context().Value(triggerKey{}).(NavigationTrigger); ok {
+		rec.Trigger = trigger.String()
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		rec.Duration = time.Since(rec.Time)
+		rec.Error = err.Error()
+		audit.Record(rec)
+		return resp, err
+	}
+	rec.Status = resp.StatusCode
+	resp.Body = &auditBody{ReadCloser: resp.Body, audit: audit, rec: rec}
+	return resp, nil
+}
+
+// auditBody counts the bytes read from a response body, and records the
+// request when it's closed.
+type auditBody struct {
+	io.ReadCloser
+	audit jar.AuditJar
+	rec   *jar.AuditRecord
+	once  sync.Once
+}
+
+// Read reads from the body, counting the bytes.
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.rec.Bytes += int64(n)
+	return n, err
+}
+
+// Close closes the body, and records the request.
+func (b *auditBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.rec.Duration = time.Since(b.rec.Time)
+		b.audit.Record(b.rec)
+	})
+	return err
+}
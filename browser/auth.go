@@ -0,0 +1,102 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// realmRegexp matches the realm parameter of a WWW-Authenticate header.
+var realmRegexp = regexp.MustCompile(`(?i)realm="?([^",]*)"?`)
+
+// SetCredentialsJar sets the credentials jar the browser uses.
+func (bow *Browser) SetCredentialsJar(cj jar.CredentialsJar) {
+	bow.credentials = cj
+}
+
+// CredentialsJar returns the credentials jar the browser uses.
+func (bow *Browser) CredentialsJar() jar.CredentialsJar {
+	return bow.credentials
+}
+
+// authorize sets the Authorization header of the request using the
+// credentials saved for its scheme and host, or removes it when the
+// PreemptiveAuth attribute is unset.
+//
+// The header is left alone when there are no credentials for the request, so
+// a header added by AddRequestHeader() or by a site profile is still sent.
+// When the request follows a redirect, a header holding the credentials of
+// the previous request is removed, so credentials are never sent to a host
+// they were not saved for.
+func (bow *Browser) authorize(req *http.Request, via ...*http.Request) {
+	if bow.credentials == nil {
+		return
+	}
+	if c, ok := bow.credentials.Read(credentialsHost(req.URL), ""); ok {
+		req.Header.Del("Authorization")
+		if bow.attributes[PreemptiveAuth] {
+			req.Header.Set("Authorization", c.Authorization())
+		}
+		return
+	}
+	if len(via) > 0 {
+		prev := via[len(via)-1]
+		c, ok := bow.credentials.Read(credentialsHost(prev.URL), "")
+		if ok && req.Header.Get("Authorization") == c.Authorization() {
+			req.Header.Del("Authorization")
+		}
+	}
+}
+
+// credentialsHost returns the scheme and host of the URL, which credentials
+// are read for, eg "https://example.com:8443".
+func credentialsHost(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// challengeRequest returns a copy of the request which answered the 401
+// response, authorized using the credentials saved for the challenged realm.
+//
+// Returns nil when there are no credentials for the realm, when they were
+// already sent, when the request body cannot be sent again, or when the
+// RetryAuthChallenges attribute is unset.
+func (bow *Browser) challengeRequest(resp *http.Response) *http.Request {
+	if bow.credentials == nil || !bow.attributes[RetryAuthChallenges] {
+		return nil
+	}
+	req := resp.Request
+	realm := ""
+	if m := realmRegexp.FindStringSubmatch(resp.Header.Get("WWW-Authenticate")); m != nil {
+		realm = m[1]
+	}
+	c, ok := bow.credentials.Read(credentialsHost(req.URL), realm)
+	if !ok || req.Header.Get("Authorization") == c.Authorization() {
+		return nil
+	}
+
+	retry := replayRequest(req)
+	if retry == nil {
+		return nil
+	}
+	retry.Header.Set("Authorization", c.Authorization())
+	return retry
+}
+
+// replayRequest returns a copy of the request which may be sent again, or nil
+// when the request body cannot be read a second time.
+func replayRequest(req *http.Request) *http.Request {
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		retry.Body = body
+	}
+	return retry
+}
@@ -2,16 +2,23 @@ package browser
 
 import (
 	"bytes"
+	"context"
 	"io"
-	"mime/multipart"
+	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/agent"
 	"github.com/haruyama/surf/errors"
 	"github.com/haruyama/surf/jar"
+	"golang.org/x/net/html"
 )
 
 // Attribute represents a Browser capability.
@@ -29,6 +36,56 @@ const (
 
 	// FollowRedirectsAttribute instructs a Browser to follow Location headers.
 	FollowRedirects
+
+	// DisableKeepAlives instructs a Browser to close the connection after each
+	// request instead of returning it to the idle pool.
+	DisableKeepAlives
+
+	// ProxyFromEnvironment instructs a Browser to send requests through the
+	// proxy named by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables when no proxy has been set with SetProxy. Browsers whose
+	// attributes don't include it use the environment too.
+	ProxyFromEnvironment
+
+	// FollowAltSvc instructs a Browser to connect to the h2 or http/1.1
+	// alternative services advertised by the Alt-Svc header of an origin.
+	FollowAltSvc
+
+	// ConditionalRequests instructs a Browser to send the If-None-Match and
+	// If-Modified-Since headers when it has the validators of a page.
+	ConditionalRequests
+
+	// RetryTransportErrors instructs a Browser to send a GET or HEAD request
+	// once more when the connection is reset or closed before a response.
+	RetryTransportErrors
+
+	// ValidateForms instructs a Browser to validate the fields of a form
+	// before submitting it, the way an HTML5 browser does.
+	ValidateForms
+
+	// HTTPOnly instructs a Browser to never parse pages, which disables the
+	// DOM, meta refresh handling and asset discovery. The browser then acts as
+	// a cookie, header and redirect aware HTTP client, and Body() returns the
+	// raw body.
+	HTTPOnly
+
+	// RefetchOnBack instructs a Browser to request the page again when going
+	// back, instead of restoring the page stored in the history.
+	RefetchOnBack
+
+	// PreemptiveAuth instructs a Browser to send the credentials saved for a
+	// host with every request to that host. When unset, credentials are only
+	// sent in answer to a 401 challenge.
+	PreemptiveAuth
+
+	// RetryAuthChallenges instructs a Browser to send a request again with the
+	// saved credentials when the server responds with 401 Unauthorized.
+	RetryAuthChallenges
+
+	// LenientParsing instructs a Browser to recover from responses which are
+	// truncated, cannot be decoded as their Content-Encoding declares, or are
+	// not valid UTF-8, firing the ParseError event instead of failing.
+	LenientParsing
 )
 
 // InitialAssetsArraySize is the initial size when allocating a slice of page
@@ -41,6 +98,9 @@ type Browsable interface {
 	// SetUserAgent sets the user agent.
 	SetUserAgent(ua string)
 
+	// Emulate configures the browser to send the same headers as the browser described by the profile.
+	Emulate(p agent.Profile)
+
 	// SetAttribute sets a browser instruction attribute.
 	SetAttribute(a Attribute, v bool)
 
@@ -53,15 +113,81 @@ type Browsable interface {
 	// SetCookieJar is used to set the cookie jar the browser uses.
 	SetCookieJar(cj http.CookieJar)
 
+	// PinCookieHeader sends the raw Cookie header value with every request to the host, bypassing the jar.
+	PinCookieHeader(host, value string)
+
+	// UnpinCookieHeader stops sending the Cookie header pinned for the host.
+	UnpinCookieHeader(host string)
+
+	// FreezeCookies makes the cookie jar read-only when frozen is true.
+	FreezeCookies(frozen bool)
+
 	// SetHistoryJar is used to set the history jar the browser uses.
 	SetHistoryJar(hj jar.History)
 
+	// SetStateJar sets the jar storing the state of the current page.
+	SetStateJar(sj jar.StateJar)
+
 	// SetHeadersJar sets the headers the browser sends with each request.
 	SetHeadersJar(h http.Header)
 
+	// SetCredentialsJar sets the credentials jar the browser uses.
+	SetCredentialsJar(cj jar.CredentialsJar)
+
+	// AltServices returns the unexpired alternative services advertised for the origin of the given URL.
+	AltServices(u *url.URL) []AltService
+
+	// SetValidatorJar sets the jar storing the ETag and Last-Modified validators of visited pages.
+	SetValidatorJar(vj jar.ValidatorJar)
+
+	// NotModified returns whether the last request received 304 Not Modified, leaving the current page unchanged.
+	NotModified() bool
+
+	// SetHSTSJar sets the jar storing the Strict-Transport-Security policies of visited hosts.
+	SetHSTSJar(hj jar.HSTSJar)
+
+	// SetProxy sets the proxy every request is sent through.
+	SetProxy(u *url.URL)
+
+	// SetProxyCredentials sets the credentials sent to the proxy.
+	SetProxyCredentials(c *jar.Credentials)
+
 	// AddRequestHeader adds a header the browser sends with each request.
 	AddRequestHeader(name, value string)
 
+	// SetMaxIdleConns sets the maximum number of idle connections kept across all hosts.
+	SetMaxIdleConns(n int)
+
+	// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept per host.
+	SetMaxIdleConnsPerHost(n int)
+
+	// SetIdleConnTimeout sets how long an idle connection is kept before closing.
+	SetIdleConnTimeout(d time.Duration)
+
+	// SetCharset sets the character encoding used to decode page bodies.
+	SetCharset(name string) error
+
+	// Reparse builds the DOM of the current page again from the original body.
+	Reparse() error
+
+	// SetLanguageDetector sets the function used to detect the language of pages without a declared language.
+	SetLanguageDetector(d LanguageDetector)
+
+	// SetDialTLSContext sets the function used to open TLS connections.
+	SetDialTLSContext(dial func(ctx context.Context, network, addr string) (net.Conn, error))
+
+	// SetHeaderOrder sets the order the request headers are sent in.
+	SetHeaderOrder(names ...string)
+
+	// SetSigner sets the signer used to sign each request.
+	SetSigner(s Signer)
+
+	// SetMaxConcurrentRequests limits the number of requests the browser sends at the same time.
+	SetMaxConcurrentRequests(n int)
+
+	// SetExpectContinueThreshold sets the body size at which uploads send Expect: 100-continue.
+	SetExpectContinueThreshold(n int64)
+
 	// Open requests the given URL using the GET method.
 	Open(url string) error
 
@@ -71,27 +197,117 @@ type Browsable interface {
 	// OpenBookmark calls Get() with the URL for the bookmark with the given name.
 	OpenBookmark(name string) error
 
+	// OpenTemplate expands the URL template with the given variables, and requests the URL using the GET method.
+	OpenTemplate(tmpl string, vars map[string]string) error
+
+	// OpenBookmarkTemplate expands the URL template saved as the bookmark with the given name, and requests the URL.
+	OpenBookmarkTemplate(name string, vars map[string]string) error
+
+	// Head requests the given URL using the HEAD method without changing the current page.
+	Head(url string) error
+
+	// HeadState returns the state recorded by the last call to Head().
+	HeadState() *HeadState
+
 	// Post requests the given URL using the POST method.
 	Post(url string, contentType string, body io.Reader) error
 
 	// PostForm requests the given URL using the POST method with the given data.
 	PostForm(url string, data url.Values) error
 
-	// PostMultipart requests the given URL using the POST method with the given data using multipart/form-data format.
-	PostMultipart(u string, data url.Values) error
+	// PostFormFiles requests the given URL using the POST method with the given data and files, using multipart/form-data only when there are files.
+	PostFormFiles(u string, data url.Values, files map[string]io.Reader) error
+
+	// PostMultipart requests the given URL using the POST method with the given fields and files using multipart/form-data format.
+	PostMultipart(u string, fields url.Values, files map[string]io.Reader) error
 
 	// Back loads the previously requested page.
 	Back() bool
 
+	// BackAndReload loads the previous page in the history by requesting it again.
+	BackAndReload() error
+
+	// SetPageLoadTimeout limits the time taken to load each page, including redirects and meta refreshes.
+	SetPageLoadTimeout(d time.Duration)
+
+	// SetLabels sets the labels of every request sent by the browser.
+	SetLabels(labels map[string]string)
+
+	// SiteProfile applies the profile to the requests sent to the hosts matching the pattern.
+	SiteProfile(pattern string, p Profile)
+
+	// RemoveSiteProfile removes the profile registered for the pattern.
+	RemoveSiteProfile(pattern string)
+
+	// HandleContentType registers the handler called each time the browser loads a page of the media type.
+	HandleContentType(mediaType string, h ContentHandler)
+
+	// KeepAlive requests the URL every interval, to keep a session on the server from expiring.
+	KeepAlive(u string, interval time.Duration) error
+
+	// StopKeepAlive stops the task started by KeepAlive().
+	StopKeepAlive()
+
+	// DetectChallenges turns on the detection of challenge pages matching the signatures.
+	DetectChallenges(sigs ...ChallengeSignature)
+
+	// SetChallengeSolver sets the function called with the challenge pages found.
+	SetChallengeSolver(s ChallengeSolver)
+
+	// HandleConsent clicks the reject or accept control of the cookie consent banner of the page.
+	HandleConsent(action ConsentAction) (bool, error)
+
+	// Alternates returns the locale variants of the current page.
+	Alternates() []*Alternate
+
+	// FetchLocales fetches each locale variant of the current page, and returns the pages keyed by language.
+	FetchLocales(concurrency int) (map[string]*Page, error)
+
+	// AMPUrl returns the URL of the AMP version of the current page, or nil.
+	AMPUrl() *url.URL
+
+	// MobileAlternateUrl returns the URL of the mobile version of the current page, or nil.
+	MobileAlternateUrl() *url.URL
+
+	// OpenAMP follows the link to the AMP version of the current page.
+	OpenAMP() error
+
+	// OpenMobileAlternate follows the link to the mobile version of the current page.
+	OpenMobileAlternate() error
+
 	// Reload duplicates the last successful request.
 	Reload() error
 
+	// ReloadFromOrigin duplicates the last successful request, bypassing validators and caches.
+	ReloadFromOrigin() error
+
+	// Resubmit duplicates the last successful request, even when it's not GET or HEAD.
+	Resubmit() error
+
+	// WaitFor reloads the current page until an element matching the selector appears on it.
+	WaitFor(selector string, timeout, pollInterval time.Duration) error
+
+	// NeedsResubmit returns whether reloading the current page sends a request which is not GET or HEAD.
+	NeedsResubmit() bool
+
+	// Close stops the refresh timer, cancels requests in flight, closes idle connections and flushes the jars.
+	Close() error
+
 	// Bookmark saves the page URL in the bookmarks with the given name.
 	Bookmark(name string) error
 
+	// RemoveBookmark removes the bookmark with the given name, and returns whether it existed.
+	RemoveBookmark(name string) bool
+
 	// Click clicks on the page element matched by the given expression.
 	Click(expr string) error
 
+	// ClickByText clicks the first link or submit button whose visible text equals the given text.
+	ClickByText(text string) error
+
+	// ClickByPartialText clicks the first link or submit button whose visible text contains the given text.
+	ClickByPartialText(text string) error
+
 	// Form returns the form in the current page that matches the given expr.
 	Form(expr string) (Submittable, error)
 
@@ -101,6 +317,9 @@ type Browsable interface {
 	// Links returns an array of every link found in the page.
 	Links() []*Link
 
+	// FollowLink follows the first link in the page selected by the matcher.
+	FollowLink(m LinkMatcher) error
+
 	// Images returns an array of every image found in the page.
 	Images() []*Image
 
@@ -110,47 +329,221 @@ type Browsable interface {
 	// Scripts returns an array of every script linked to the document.
 	Scripts() []*Script
 
+	// ResourceHints returns an array of every preload, prefetch, and modulepreload link in the document.
+	ResourceHints() []*ResourceHint
+
+	// Media returns an array of every audio and video source in the document.
+	Media() []*Media
+
+	// Favicons returns an array of every icon and apple-touch-icon link in the document.
+	Favicons() []*Favicon
+
+	// Assets returns every asset in the page of the given types, or of every type when none are given.
+	Assets(types ...AssetType) []Assetable
+
+	// ConnectionHints returns an array of every dns-prefetch and preconnect link in the document.
+	ConnectionHints() []*ConnectionHint
+
 	// SiteCookies returns the cookies for the current site.
 	SiteCookies() []*http.Cookie
 
+	// CSP returns the Content-Security-Policy policies sent with the current page.
+	CSP() []*CSP
+
+	// CSPViolations returns the page assets which are not allowed by its policies.
+	CSPViolations() []CSPViolation
+
+	// On registers a handler which is called each time an event of the given type is fired.
+	On(t EventType, h EventHandler)
+
+	// OnAsync registers a handler which is called on its own goroutine each time an event of the given type is fired.
+	OnAsync(t EventType, h EventHandler)
+
+	// Wait blocks until every asynchronous handler has returned, and returns their errors.
+	Wait() []error
+
+	// Stats returns the current values of the counters of the browser.
+	Stats() Stats
+
+	// Traffic returns the traffic exchanged with each registrable domain.
+	Traffic() map[string]DomainTraffic
+
+	// DomainTraffic returns the traffic exchanged with the registrable domain of the given host.
+	DomainTraffic(host string) DomainTraffic
+
+	// ResetTraffic clears the traffic of every domain.
+	ResetTraffic()
+
+	// PublishStats publishes the counters of the browser with expvar under the given name.
+	PublishStats(name string)
+
+	// Use adds a middleware to the chain wrapped around every request the browser sends.
+	Use(m Middleware)
+
+	// Exists requests the given URL using the HEAD method, falling back to GET, without changing the browser state.
+	Exists(u string) (*HeadState, error)
+
+	// OpenWithOptions requests the given URL using the GET method and the given options.
+	OpenWithOptions(u string, opts RequestOptions) error
+
+	// PostWithOptions requests the given URL using the POST method and the given options.
+	PostWithOptions(u string, contentType string, body io.Reader, opts RequestOptions) error
+
+	// SetMethodOverride sets how forms with a _method field are submitted.
+	SetMethodOverride(m MethodOverride)
+
+	// Peek requests the given URL using the GET method without replacing the current page.
+	Peek(u string) (*Page, error)
+
+	// PeekPost requests the given URL using the POST method without replacing the current page.
+	PeekPost(u string, contentType string, body io.Reader) (*Page, error)
+
+	// Session returns the configuration and session state of the browser, which may be encoded and restored elsewhere.
+	Session() (*Session, error)
+
+	// RestoreSession applies the configuration and session state to the browser.
+	RestoreSession(s *Session) error
+
+	// OpenInNewTab opens a new tab, makes it the active tab, and requests the given URL in it.
+	OpenInNewTab(u string) error
+
+	// SwitchTab makes the tab with the given index the active tab.
+	SwitchTab(i int) error
+
+	// CloseTab closes the tab with the given index.
+	CloseTab(i int) error
+
+	// TabCount returns the number of open tabs.
+	TabCount() int
+
+	// ActiveTab returns the index of the active tab.
+	ActiveTab() int
+
 	// ResolveUrl returns an absolute URL for a possibly relative URL.
 	ResolveUrl(u *url.URL) *url.URL
 
 	// ResolveStringUrl works just like ResolveUrl, but the argument and return value are strings.
 	ResolveStringUrl(u string) (string, error)
 
+	// ResolutionContext returns the resolution context of the current page.
+	ResolutionContext() *ResolutionContext
+
 	// Download writes the contents of the document to the given writer.
 	Download(o io.Writer) (int64, error)
 
+	// DownloadAs writes the document to the given writer, transcoded to the charset.
+	DownloadAs(o io.Writer, charset string) (int64, error)
+
+	// SetAuditJar sets the jar receiving a record of every request the browser sends.
+	SetAuditJar(aj jar.AuditJar)
+
+	// AuditJar returns the jar receiving the records of the requests.
+	AuditJar() jar.AuditJar
+
+	// SetArchiveFunc sets the function returning the writers the body of each page is copied to.
+	SetArchiveFunc(f ArchiveFunc)
+
+	// SetSpoolThreshold sets the body size above which response bodies are kept on disk.
+	SetSpoolThreshold(n int64, dir string)
+
+	// DownloadWithOptions writes the contents of the document to the given writer using the given options.
+	DownloadWithOptions(o io.Writer, opts DownloadOptions) (int64, error)
+
 	// Url returns the page URL as a string.
 	Url() *url.URL
 
 	// StatusCode returns the response status code.
 	StatusCode() int
 
+	// IsSuccess returns whether the response status code is in the 2xx range.
+	IsSuccess() bool
+
+	// IsRedirect returns whether the response status code is in the 3xx range.
+	IsRedirect() bool
+
+	// IsClientError returns whether the response status code is in the 4xx range.
+	IsClientError() bool
+
+	// IsServerError returns whether the response status code is in the 5xx range.
+	IsServerError() bool
+
 	// Title returns the page title.
 	Title() string
 
+	// Description returns the page description.
+	Description() string
+
+	// Keywords returns the page keywords.
+	Keywords() []string
+
 	// ResponseHeaders returns the page headers.
 	ResponseHeaders() http.Header
 
+	// Trailer returns the trailers sent by the server after the page body.
+	Trailer() http.Header
+
+	// EarlyHints returns the links of the 103 Early Hints responses of the page.
+	EarlyHints() []*ResourceHint
+
+	// RobotsMeta returns the directives from the robots meta tag and X-Robots-Tag header.
+	RobotsMeta() *Robots
+
+	// Language returns the declared, or detected, language of the page.
+	Language() string
+
 	// Response returns a http.Response pointer.
 	Response() *http.Response
 
 	// Body returns the page body as a string of html.
 	Body() string
 
+	// RawBody returns the original body of the page, without parsing it.
+	RawBody() ([]byte, error)
+
+	// Page returns the current page.
+	Page() *Page
+
+	// OpenPage requests the given URL using the GET method, and returns the page it loaded.
+	OpenPage(u string) (*Page, error)
+
+	// PostPage requests the given URL using the POST method, and returns the page it loaded.
+	PostPage(u string, contentType string, body io.Reader) (*Page, error)
+
+	// ClickPage clicks on the page element matched by the given expression, and returns the page it loaded.
+	ClickPage(expr string) (*Page, error)
+
+	// ReloadPage duplicates the last successful request, and returns the page it loaded.
+	ReloadPage() (*Page, error)
+
+	// BackPage loads the previously requested page, and returns it.
+	BackPage() *Page
+
+	// HistoryPages returns the pages stored in the history, the most recent first.
+	HistoryPages() []*Page
+
 	// Dom returns the inner *goquery.Selection.
 	Dom() *goquery.Selection
 
+	// Document returns the parsed *goquery.Document of the current page.
+	Document() *goquery.Document
+
+	// Node returns the root *html.Node of the current page.
+	Node() *html.Node
+
 	// Find returns the dom selections matching the given expression.
 	Find(expr string) *goquery.Selection
+
+	// FindByText returns the elements matching the selector whose visible text equals the given text.
+	FindByText(selector, text string) *goquery.Selection
+
+	// FindByAttr returns the elements matching the selector whose attribute equals the given value.
+	FindByAttr(selector, attr, value string) *goquery.Selection
 }
 
 // Default is the default Browser implementation.
 type Browser struct {
-	// state is the current browser state.
-	state *jar.State
+	// states stores the state of the current page.
+	states jar.StateJar
 
 	// userAgent is the User-Agent header value sent with requests.
 	userAgent string
@@ -158,6 +551,13 @@ type Browser struct {
 	// cookies stores cookies for every site visited by the browser.
 	cookies http.CookieJar
 
+	// pinnedCookies maps hosts to the raw Cookie header sent to them in place
+	// of the cookies in the jar.
+	pinnedCookies map[string]string
+
+	// cookiesFrozen is true when cookies set by responses are discarded.
+	cookiesFrozen bool
+
 	// bookmarks stores the saved bookmarks.
 	bookmarks jar.BookmarksJar
 
@@ -172,6 +572,168 @@ type Browser struct {
 
 	// refresh is a timer used to meta refresh pages.
 	refresh *time.Timer
+
+	// refreshMutex guards refresh, which is replaced by the timer goroutine.
+	refreshMutex sync.Mutex
+
+	// pageLoadTimeout is the time allowed to load a page, including redirects
+	// and meta refreshes. Zero means no limit.
+	pageLoadTimeout time.Duration
+
+	// profiles are the site profiles, in the order they were registered.
+	profiles []*siteProfile
+
+	// profilesMutex protects profiles.
+	profilesMutex sync.Mutex
+
+	// contentHandlers are the handlers of the pages loaded, by media type.
+	contentHandlers map[string]ContentHandler
+
+	// keepAlive stops the keep-alive task when closed, or is nil.
+	keepAlive chan struct{}
+
+	// keepAliveMutex protects keepAlive.
+	keepAliveMutex sync.Mutex
+
+	// challenges are the signatures of the challenge pages to detect.
+	challenges []ChallengeSignature
+
+	// challengeSolver is called with the challenge pages found.
+	challengeSolver ChallengeSolver
+
+	// audit receives a record of every request sent by the browser.
+	audit jar.AuditJar
+
+	// labels are the labels of every request sent by the browser.
+	labels map[string]string
+
+	// loadDeadline is when the meta refreshes of the current page must be
+	// loaded by, or zero when there is no deadline.
+	loadDeadline time.Time
+
+	// closed is true once Close() has been called.
+	closed bool
+
+	// inflight maps the requests in flight to the functions which cancel them.
+	inflight map[*http.Request]context.CancelFunc
+
+	// closeMutex guards closed and inflight.
+	closeMutex sync.Mutex
+
+	// transport is the connection pool shared by every request the browser makes.
+	transport *http.Transport
+
+	// transportMutex protects the creation and the settings of transport.
+	transportMutex sync.Mutex
+
+	// proxySettings are the settings the proxy of transport was chosen by.
+	proxySettings proxySettings
+
+	// headerOrder is the order the request headers are sent in.
+	headerOrder []string
+
+	// dialTLS is the function set by SetDialTLSContext().
+	dialTLS func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// fetchSite is whether navigations send the Sec-Fetch-Site header.
+	fetchSite bool
+
+	// fetchUser is whether navigations started by the user send the
+	// Sec-Fetch-User header.
+	fetchUser bool
+
+	// expectContinueThreshold is the body size at which the Expect: 100-continue
+	// header is sent. Zero disables the header.
+	expectContinueThreshold int64
+
+	// head is the state recorded by the last HEAD request.
+	head *HeadState
+
+	// languageDetector detects the language of pages without a declared language.
+	languageDetector LanguageDetector
+
+	// charset is the character encoding used to decode page bodies.
+	charset string
+
+	// spoolThreshold is the body size above which response bodies are
+	// written to a file. Zero keeps every body in memory.
+	spoolThreshold int64
+
+	// spoolDir is the directory where response bodies are spooled.
+	spoolDir string
+
+	// spoolMutex protects spooled.
+	spoolMutex sync.Mutex
+
+	// spooled are the names of the files holding spooled response bodies.
+	spooled map[string]bool
+
+	// signer signs each request before it's sent.
+	signer Signer
+
+	// credentials stores the credentials sent to protected sites.
+	credentials jar.CredentialsJar
+
+	// proxy is the proxy every request is sent through.
+	proxy *url.URL
+
+	// proxyCredentials are the credentials sent to the proxy.
+	proxyCredentials *jar.Credentials
+
+	// proxyChallenged is whether the proxy has asked for credentials.
+	proxyChallenged bool
+
+	// handlers are the event handlers keyed by event type.
+	handlers map[EventType][]eventHandler
+
+	// asyncHandlers counts the running asynchronous event handlers.
+	asyncHandlers sync.WaitGroup
+
+	// asyncMutex guards asyncErrors.
+	asyncMutex sync.Mutex
+
+	// asyncErrors are the errors returned by asynchronous event handlers.
+	asyncErrors []error
+
+	// middleware is the chain wrapped around every request.
+	middleware []Middleware
+
+	// tabs are the open tabs, or nil until a second tab is opened.
+	tabs []*tab
+
+	// activeTab is the index of the active tab.
+	activeTab int
+
+	// methodOverride is how forms with a _method field are submitted.
+	methodOverride MethodOverride
+
+	// hsts stores the Strict-Transport-Security policies of visited hosts.
+	hsts jar.HSTSJar
+
+	// altSvc stores the alternative services keyed by origin host and port.
+	altSvc map[string][]AltService
+
+	// altSvcMutex guards altSvc, which is read when dialing.
+	altSvcMutex sync.Mutex
+
+	// validators stores the ETag and Last-Modified validators of visited pages.
+	validators jar.ValidatorJar
+
+	// notModified is whether the last request received 304 Not Modified.
+	notModified bool
+
+	// counters are the live counters returned by Stats().
+	counters counters
+
+	// slots limits the number of concurrent requests, or is nil when there
+	// is no limit.
+	slots chan struct{}
+
+	// slotsMutex guards slots.
+	slotsMutex sync.Mutex
+
+	// archive returns the writers the body of each page is copied to.
+	archive ArchiveFunc
 }
 
 // Open requests the given URL using the GET method.
@@ -180,7 +742,7 @@ func (bow *Browser) Open(u string) error {
 	if err != nil {
 		return err
 	}
-	return bow.httpGET(ur, nil)
+	return bow.httpGET(ur, nil, TriggerOpen)
 }
 
 // OpenForm appends the data values to the given URL and sends a GET request.
@@ -209,7 +771,7 @@ func (bow *Browser) Post(u string, contentType string, body io.Reader) error {
 	if err != nil {
 		return err
 	}
-	return bow.httpPOST(ur, nil, contentType, body)
+	return bow.httpPOST(ur, nil, contentType, body, TriggerOpen)
 }
 
 // PostForm requests the given URL using the POST method with the given data.
@@ -217,47 +779,217 @@ func (bow *Browser) PostForm(u string, data url.Values) error {
 	return bow.Post(u, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
 }
 
-// PostMultipart requests the given URL using the POST method with the given data using multipart/form-data format.
-func (bow *Browser) PostMultipart(u string, data url.Values) error {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	for k, vs := range data {
-		for _, v := range vs {
-			writer.WriteField(k, v)
-		}
+// PostMultipart requests the given URL using the POST method with the given fields and files using multipart/form-data format.
+//
+// The files map field names to their contents, which are streamed to the
+// server rather than buffered in memory. The file name sent for each file is
+// the base name of the reader when it has a Name() method, such as *os.File,
+// and the field name otherwise. Files may be nil.
+func (bow *Browser) PostMultipart(u string, fields url.Values, files map[string]io.Reader) error {
+	mf := make(multipartFiles, len(files))
+	for name, r := range files {
+		mf[name] = []multipartFile{{fileName: readerFileName(name, r), reader: r}}
 	}
-	err := writer.Close()
-	if err != nil {
-		return err
+	contentType, body := newMultipartReader(fields, mf)
+	return bow.Post(u, contentType, body)
+}
 
+// PostFormFiles requests the given URL using the POST method with the given
+// data and files.
+//
+// The request is sent using the multipart/form-data format when there are
+// files, and the application/x-www-form-urlencoded format otherwise, so the
+// same call works for endpoints which take optional attachments.
+func (bow *Browser) PostFormFiles(u string, data url.Values, files map[string]io.Reader) error {
+	if len(files) == 0 {
+		return bow.PostForm(u, data)
 	}
-	return bow.Post(u, writer.FormDataContentType(), body)
+	return bow.PostMultipart(u, data, files)
 }
 
 // Back loads the previously requested page.
 //
 // Returns a boolean value indicating whether a previous page existed, and was
 // successfully loaded.
+//
+// The stored page is restored, unless the RefetchOnBack attribute is set, in
+// which case the page is requested again. The stored page is kept when the
+// request fails, and when the page is the result of a submitted form which
+// a handler of the BeforeResubmit event does not confirm sending again. Use
+// BackAndReload() to always request the page again, and to find out whether
+// the request failed.
 func (bow *Browser) Back() bool {
 	if bow.history.Len() > 1 {
-		bow.state = bow.history.Pop()
+		bow.popHistory()
+		if bow.attributes[RefetchOnBack] {
+			bow.refetch()
+		}
 		return true
 	}
 	return false
 }
 
+// BackAndReload loads the previous page in the history by requesting it
+// again, which replaces the page stored in the history.
+//
+// Like Reload(), requests which are not GET or HEAD are only sent again when
+// a handler of the BeforeResubmit event confirms it.
+func (bow *Browser) BackAndReload() error {
+	if bow.history.Len() <= 1 {
+		return errors.NewPageNotLoaded("Cannot go back, the history is empty.")
+	}
+	bow.popHistory()
+	return bow.refetch()
+}
+
+// refetch requests the current page again, replacing it without adding to
+// the history.
+func (bow *Browser) refetch() error {
+	req, err := bow.reloadRequest(TriggerBack, false)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req, TriggerBack)
+}
+
 // Reload duplicates the last successful request.
+//
+// The page is revalidated using the If-None-Match and If-Modified-Since
+// headers when the ConditionalRequests attribute is set, in which case the
+// current page is kept when the server responds with 304 Not Modified. Use
+// ReloadFromOrigin() to fetch the page again regardless.
+//
+// Requests which are not GET or HEAD, such as submitted forms, are only sent
+// again when a handler of the BeforeResubmit event confirms it. Use
+// Resubmit() to send them again without asking.
 func (bow *Browser) Reload() error {
-	if bow.state.Request != nil {
-		return bow.httpRequest(bow.state.Request)
+	req, err := bow.reloadRequest(TriggerRefresh, false)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req, TriggerRefresh)
+}
+
+// ReloadFromOrigin duplicates the last successful request without sending
+// validators, and asks caches along the way not to answer it, so the page is
+// always fetched again from the server.
+func (bow *Browser) ReloadFromOrigin() error {
+	req, err := bow.reloadRequest(TriggerRefresh, false)
+	if err != nil {
+		return err
 	}
-	return errors.NewPageNotLoaded("Cannot reload, the previous request failed.")
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Pragma", "no-cache")
+	return bow.httpRequest(req, TriggerRefresh)
+}
+
+// Resubmit duplicates the last successful request like Reload(), and sends
+// requests which are not GET or HEAD again without firing the BeforeResubmit
+// event. Calling it is the confirmation.
+func (bow *Browser) Resubmit() error {
+	req, err := bow.reloadRequest(TriggerRefresh, true)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req, TriggerRefresh)
+}
+
+// NeedsResubmit returns whether the current page is the result of a request
+// which is not GET or HEAD, such as a submitted form, so reloading it must be
+// confirmed.
+func (bow *Browser) NeedsResubmit() bool {
+	prev := bow.state().Request
+	return prev != nil && prev.Method != "GET" && prev.Method != "HEAD"
+}
+
+// reloadRequest returns a copy of the last successful request, without the
+// validators and the request options it was sent with. Requests which are not GET or HEAD must be
+// confirmed by a handler of the BeforeResubmit event, unless confirmed is true.
+func (bow *Browser) reloadRequest(trigger NavigationTrigger, confirmed bool) (*http.Request, error) {
+	prev := bow.state().Request
+	if prev == nil {
+		return nil, errors.NewPageNotLoaded("Cannot reload, the previous request failed.")
+	}
+	if !confirmed && bow.NeedsResubmit() {
+		if len(bow.handlers[BeforeResubmit]) == 0 {
+			return nil, errors.NewPageNotLoaded(
+				"Cannot reload, resubmitting a %s request must be confirmed.", prev.Method)
+		}
+		if err := bow.fire(&Event{Type: BeforeResubmit, URL: prev.URL, Trigger: trigger, Labels: Labels(prev)}); err != nil {
+			return nil, err
+		}
+	}
+
+	if prev.GetBody == nil && prev.Body != nil && prev.Body != http.NoBody {
+		return nil, errors.New(
+			"Cannot reload, the streamed body of the %s request cannot be resent.", prev.Method)
+	}
+	req := prev.Clone(prev.Context())
+	if prev.GetBody != nil {
+		body, err := prev.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	return bow.withoutOptions(req), nil
 }
 
 // Bookmark saves the page URL in the bookmarks with the given name.
 func (bow *Browser) Bookmark(name string) error {
-	return bow.bookmarks.Save(name, bow.ResolveUrl(bow.Url()).String())
+	return bow.saveBookmark(name, bow.ResolveUrl(bow.Url()).String())
+}
+
+// RemoveBookmark removes the bookmark with the given name, and returns
+// whether it existed.
+func (bow *Browser) RemoveBookmark(name string) bool {
+	u, err := bow.bookmarks.Read(name)
+	if err != nil || !bow.bookmarks.Remove(name) {
+		return false
+	}
+	e := &Event{Type: BookmarkRemoved, Bookmark: name}
+	e.URL, _ = url.Parse(u)
+	bow.fire(e)
+	return true
+}
+
+// saveBookmark saves the bookmark, and fires the BookmarkSaved event.
+func (bow *Browser) saveBookmark(name, u string) error {
+	if err := bow.bookmarks.Save(name, u); err != nil {
+		return err
+	}
+	e := &Event{Type: BookmarkSaved, Bookmark: name}
+	e.URL, _ = url.Parse(u)
+	bow.fire(e)
+	return nil
+}
+
+// pushHistory pushes the current page onto the history, and fires the
+// HistoryPushed event unless no page was loaded.
+func (bow *Browser) pushHistory() {
+	s := bow.state()
+	bow.history.Push(s)
+	if s != nil && s.Request != nil {
+		bow.fire(&Event{Type: HistoryPushed, URL: s.Request.URL, State: s})
+	}
+}
+
+// popHistory makes the page popped from the history the current page, and
+// fires the HistoryPopped event.
+func (bow *Browser) popHistory() {
+	dropped := bow.state()
+	s := bow.history.Pop()
+	bow.setState(s)
+	if dropped != s {
+		bow.dropState(dropped)
+	}
+	e := &Event{Type: HistoryPopped, State: s}
+	if s != nil && s.Request != nil {
+		e.URL = s.Request.URL
+	}
+	bow.fire(e)
 }
 
 // Click clicks on the page element matched by the given expression.
@@ -276,115 +1008,138 @@ func (bow *Browser) Click(expr string) error {
 			"Expr '%s' must match an anchor tag.", expr)
 	}
 
-	href, err := bow.attrToResolvedUrl("href", sel)
+	href, err := bow.ResolutionContext().resolveAttr("href", sel)
 	if err != nil {
 		return err
 	}
 
-	return bow.httpGET(href, bow.Url())
+	return bow.httpGET(href, bow.Url(), TriggerClick)
 }
 
-// Form returns the form in the current page that matches the given expr.
-func (bow *Browser) Form(expr string) (Submittable, error) {
-	sel := bow.Find(expr)
-	if sel.Length() == 0 {
-		return nil, errors.NewElementNotFound(
-			"Form not found matching expr '%s'.", expr)
+// Page returns the current page.
+//
+// The page remains valid after the browser navigates elsewhere, so it may be
+// retained, or handed to another goroutine to extract its data.
+func (bow *Browser) Page() *Page {
+	return &Page{bow: bow, state: bow.state()}
+}
+
+// OpenPage requests the given URL using the GET method like Open(), and
+// returns the page it loaded.
+func (bow *Browser) OpenPage(u string) (*Page, error) {
+	return bow.pageOf(bow.Open(u))
+}
+
+// PostPage requests the given URL using the POST method like Post(), and
+// returns the page it loaded.
+func (bow *Browser) PostPage(u string, contentType string, body io.Reader) (*Page, error) {
+	return bow.pageOf(bow.Post(u, contentType, body))
+}
+
+// ClickPage clicks on the page element matched by the given expression like
+// Click(), and returns the page it loaded.
+func (bow *Browser) ClickPage(expr string) (*Page, error) {
+	return bow.pageOf(bow.Click(expr))
+}
+
+// ReloadPage duplicates the last successful request like Reload(), and
+// returns the page it loaded.
+func (bow *Browser) ReloadPage() (*Page, error) {
+	return bow.pageOf(bow.Reload())
+}
+
+// BackPage loads the previously requested page like Back(), and returns it,
+// or nil when there was no previous page.
+func (bow *Browser) BackPage() *Page {
+	if !bow.Back() {
+		return nil
+	}
+	return bow.Page()
+}
+
+// HistoryPages returns the pages stored in the history, the most recent
+// first. The current page is not included. Returns nil when the history jar
+// cannot list its pages.
+func (bow *Browser) HistoryPages() []*Page {
+	h, ok := bow.history.(interface{ States() []*jar.State })
+	if !ok {
+		return nil
 	}
-	if !sel.Is("form") {
-		return nil, errors.NewElementNotFound(
-			"Expr '%s' does not match a form tag.", expr)
+	var pages []*Page
+	for _, s := range h.States() {
+		if s != nil && s.Request != nil {
+			pages = append(pages, &Page{bow: bow, state: s})
+		}
 	}
+	return pages
+}
 
-	return NewForm(bow, sel), nil
+// pageOf returns the current page, or the error of the navigation which was
+// meant to load it.
+func (bow *Browser) pageOf(err error) (*Page, error) {
+	if err != nil {
+		return nil, err
+	}
+	return bow.Page(), nil
+}
+
+// Form returns the form in the current page that matches the given expr.
+func (bow *Browser) Form(expr string) (Submittable, error) {
+	return bow.Page().Form(expr)
 }
 
 // Forms returns an array of every form in the page.
 func (bow *Browser) Forms() []Submittable {
-	sel := bow.Find("form")
-	len := sel.Length()
-	if len == 0 {
-		return nil
-	}
-
-	forms := make([]Submittable, len)
-	sel.Each(func(_ int, s *goquery.Selection) {
-		forms = append(forms, NewForm(bow, s))
-	})
-	return forms
+	return bow.Page().Forms()
 }
 
 // Links returns an array of every link found in the page.
 func (bow *Browser) Links() []*Link {
-	links := make([]*Link, 0, InitialAssetsSliceSize)
-	bow.Find("a").Each(func(_ int, s *goquery.Selection) {
-		href, err := bow.attrToResolvedUrl("href", s)
-		if err == nil {
-			links = append(links, NewLinkAsset(
-				href,
-				bow.attrOrDefault("id", "", s),
-				s.Text(),
-			))
-		}
-	})
-
-	return links
+	return bow.Page().Links()
 }
 
 // Images returns an array of every image found in the page.
 func (bow *Browser) Images() []*Image {
-	images := make([]*Image, 0, InitialAssetsSliceSize)
-	bow.Find("img").Each(func(_ int, s *goquery.Selection) {
-		src, err := bow.attrToResolvedUrl("src", s)
-		if err == nil {
-			images = append(images, NewImageAsset(
-				src,
-				bow.attrOrDefault("id", "", s),
-				bow.attrOrDefault("alt", "", s),
-				bow.attrOrDefault("title", "", s),
-			))
-		}
-	})
-
-	return images
+	return bow.Page().Images()
 }
 
 // Stylesheets returns an array of every stylesheet linked to the document.
 func (bow *Browser) Stylesheets() []*Stylesheet {
-	stylesheets := make([]*Stylesheet, 0, InitialAssetsSliceSize)
-	bow.Find("link").Each(func(_ int, s *goquery.Selection) {
-		rel, ok := s.Attr("rel")
-		if ok && rel == "stylesheet" {
-			href, err := bow.attrToResolvedUrl("href", s)
-			if err == nil {
-				stylesheets = append(stylesheets, NewStylesheetAsset(
-					href,
-					bow.attrOrDefault("id", "", s),
-					bow.attrOrDefault("media", "all", s),
-					bow.attrOrDefault("type", "text/css", s),
-				))
-			}
-		}
-	})
-
-	return stylesheets
+	return bow.Page().Stylesheets()
 }
 
 // Scripts returns an array of every script linked to the document.
 func (bow *Browser) Scripts() []*Script {
-	scripts := make([]*Script, 0, InitialAssetsSliceSize)
-	bow.Find("script").Each(func(_ int, s *goquery.Selection) {
-		src, err := bow.attrToResolvedUrl("src", s)
-		if err == nil {
-			scripts = append(scripts, NewScriptAsset(
-				src,
-				bow.attrOrDefault("id", "", s),
-				bow.attrOrDefault("type", "text/javascript", s),
-			))
-		}
-	})
+	return bow.Page().Scripts()
+}
+
+// ResourceHints returns an array of every preload, prefetch, and modulepreload
+// link in the document.
+func (bow *Browser) ResourceHints() []*ResourceHint {
+	return bow.Page().ResourceHints()
+}
+
+// Media returns an array of every audio and video source in the document.
+func (bow *Browser) Media() []*Media {
+	return bow.Page().Media()
+}
 
-	return scripts
+// Favicons returns an array of every icon and apple-touch-icon link in the
+// document.
+func (bow *Browser) Favicons() []*Favicon {
+	return bow.Page().Favicons()
+}
+
+// Assets returns every asset in the page of the given types, or of every
+// type when none are given.
+func (bow *Browser) Assets(types ...AssetType) []Assetable {
+	return bow.Page().Assets(types...)
+}
+
+// ConnectionHints returns an array of every dns-prefetch and preconnect link
+// in the document.
+func (bow *Browser) ConnectionHints() []*ConnectionHint {
+	return bow.Page().ConnectionHints()
 }
 
 // SiteCookies returns the cookies for the current site.
@@ -422,6 +1177,16 @@ func (bow *Browser) SetHistoryJar(hj jar.History) {
 	bow.history = hj
 }
 
+// SetStateJar sets the jar storing the state of the current page.
+//
+// The state of the current page is moved to the new jar.
+func (bow *Browser) SetStateJar(sj jar.StateJar) {
+	if s := bow.state(); s != nil {
+		sj.SetCurrent(s)
+	}
+	bow.states = sj
+}
+
 // SetHeadersJar sets the headers the browser sends with each request.
 func (bow *Browser) SetHeadersJar(h http.Header) {
 	bow.headers = h
@@ -433,8 +1198,10 @@ func (bow *Browser) AddRequestHeader(name, value string) {
 }
 
 // ResolveUrl returns an absolute URL for a possibly relative URL.
+//
+// The URL is resolved against the base element of the page when it has one.
 func (bow *Browser) ResolveUrl(u *url.URL) *url.URL {
-	return bow.Url().ResolveReference(u)
+	return bow.ResolutionContext().Resolve(u)
 }
 
 // ResolveStringUrl works just like ResolveUrl, but the argument and return value are strings.
@@ -443,67 +1210,118 @@ func (bow *Browser) ResolveStringUrl(u string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	pu = bow.Url().ResolveReference(pu)
+	pu = bow.ResolveUrl(pu)
 	return pu.String(), nil
 }
 
 // Download writes the contents of the document to the given writer.
+//
+// The original response body is written byte for byte. Use
+// DownloadWithOptions() to write the document serialized from the DOM.
 func (bow *Browser) Download(o io.Writer) (int64, error) {
-	h, err := bow.state.Dom.Html()
+	r, err := bow.state().BodyReader()
 	if err != nil {
 		return 0, err
 	}
-	l, err := io.WriteString(o, h)
-	return int64(l), err
+	defer r.Close()
+	return io.Copy(o, r)
 }
 
 // Url returns the page URL as a string.
 func (bow *Browser) Url() *url.URL {
-	return bow.state.Request.URL
+	return bow.state().Request.URL
 }
 
 // StatusCode returns the response status code.
 func (bow *Browser) StatusCode() int {
-	return bow.state.Response.StatusCode
+	return bow.state().Response.StatusCode
 }
 
 // Title returns the page title.
 func (bow *Browser) Title() string {
-	return bow.state.Dom.Find("title").Text()
+	return bow.document().Find("title").Text()
 }
 
 // ResponseHeaders returns the page headers.
 func (bow *Browser) ResponseHeaders() http.Header {
-	return bow.state.Response.Header
+	return bow.state().Response.Header
 }
 
 // Response returns the pointer to http.Response.
 func (bow *Browser) Response() *http.Response {
-	return bow.state.Response
+	return bow.state().Response
 }
 
 // Body returns the page body as a string of html.
+//
+// The original body is returned unchanged when the page is not HTML.
 func (bow *Browser) Body() string {
-	body, _ := bow.state.Dom.Find("body").Html()
-	return body
+	return bow.bodyOf(bow.state())
 }
 
 // Dom returns the inner *goquery.Selection.
 func (bow *Browser) Dom() *goquery.Selection {
-	return bow.state.Dom.First()
+	return bow.document().First()
+}
+
+// Document returns the parsed *goquery.Document of the current page.
+func (bow *Browser) Document() *goquery.Document {
+	return bow.document()
+}
+
+// Node returns the root *html.Node of the current page, which may be
+// traversed without re-parsing the body.
+func (bow *Browser) Node() *html.Node {
+	return bow.document().Get(0)
 }
 
 // Find returns the dom selections matching the given expression.
 func (bow *Browser) Find(expr string) *goquery.Selection {
-	return bow.state.Dom.Find(expr)
+	return bow.document().Find(expr)
+}
+
+// RenderNode writes the HTML of each node in the selection to the writer,
+// including the outer tags.
+func RenderNode(w io.Writer, sel *goquery.Selection) error {
+	for _, n := range sel.Nodes {
+		if err := html.Render(w, n); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // -- Unexported methods --
 
+// state returns the state of the current page, or nil when no page has been loaded.
+func (bow *Browser) state() *jar.State {
+	if bow.states == nil {
+		return nil
+	}
+	return bow.states.Current()
+}
+
+// setState replaces the state of the current page.
+func (bow *Browser) setState(s *jar.State) {
+	if bow.states == nil {
+		bow.states = jar.NewMemoryState()
+	}
+	bow.states.SetCurrent(s)
+}
+
 // buildClient creates, configures, and returns a *http.Client type.
 func (bow *Browser) buildClient() *http.Client {
 	client := &http.Client{}
-	client.Jar = bow.cookies
+	client.Transport = bow.buildTransport()
+	if len(bow.pinnedCookies) > 0 {
+		client.Transport = &pinnedCookieTransport{bow: bow, transport: client.Transport}
+	}
+	if bow.audit != nil {
+		client.Transport = &auditTransport{bow: bow, transport: client.Transport}
+	}
+	if bow.cookies != nil {
+		client.Jar = &eventCookieJar{bow: bow}
+	}
 	client.CheckRedirect = bow.shouldRedirect
 	return client
 }
@@ -515,106 +1333,321 @@ func (bow *Browser) buildRequest(method, url string, ref *url.URL, body io.Reade
 	if err != nil {
 		return nil, err
 	}
-	req.Header = bow.headers
+	bow.upgradeHSTS(req)
+	if bow.headers != nil {
+		req.Header = bow.headers.Clone()
+	}
 	req.Header.Add("User-Agent", bow.userAgent)
 	if bow.attributes[SendReferer] && ref != nil {
 		req.Header.Add("Referer", ref.String())
 	}
+	bow.applyProfile(req)
+	bow.authorize(req)
 
-	return req, nil
+	return withLabels(req, bow.labels), nil
 }
 
 // httpGET makes an HTTP GET request for the given URL.
 // When via is not nil, and AttributeSendReferer is true, the Referer header will
 // be set to ref.
-func (bow *Browser) httpGET(u *url.URL, ref *url.URL) error {
+func (bow *Browser) httpGET(u *url.URL, ref *url.URL, trigger NavigationTrigger) error {
 	req, err := bow.buildRequest("GET", u.String(), ref, nil)
 	if err != nil {
 		return err
 	}
-	return bow.httpRequest(req)
+	return bow.httpRequest(req, trigger)
 }
 
 // httpPOST makes an HTTP POST request for the given URL.
 // When via is not nil, and AttributeSendReferer is true, the Referer header will
 // be set to ref.
-func (bow *Browser) httpPOST(u *url.URL, ref *url.URL, contentType string, body io.Reader) error {
-	req, err := bow.buildRequest("POST", u.String(), ref, body)
+func (bow *Browser) httpPOST(u *url.URL, ref *url.URL, contentType string, body io.Reader, trigger NavigationTrigger) error {
+	req, err := bow.buildPOST(u, ref, contentType, body)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", contentType)
+	return bow.httpRequest(req, trigger)
+}
 
-	return bow.httpRequest(req)
+// buildPOST creates and returns a POST request with the given body.
+func (bow *Browser) buildPOST(u *url.URL, ref *url.URL, contentType string, body io.Reader) (*http.Request, error) {
+	req, err := bow.buildRequest("POST", u.String(), ref, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if bow.shouldExpectContinue(req) {
+		req.Header.Set("Expect", "100-continue")
+	}
+	return req, nil
 }
 
 // send uses the given *http.Request to make an HTTP request.
-func (bow *Browser) httpRequest(req *http.Request) error {
-	bow.preSend()
-	resp, err := bow.buildClient().Do(req)
+//
+// The BeforeNavigate event is fired before the request is sent, and the
+// PageLoaded event after the response has become the current page. When a
+// conditional request receives 304 Not Modified, the current page is kept.
+// Pages requested again by going back replace the current page, rather than
+// adding it to the history.
+func (bow *Browser) httpRequest(req *http.Request, trigger NavigationTrigger) error {
+	var deadline time.Time
+	if bow.pageLoadTimeout > 0 {
+		deadline = time.Now().Add(bow.pageLoadTimeout)
+	}
+	return bow.loadPage(req, trigger, deadline)
+}
+
+// loadPage requests the page, which must be loaded before the deadline unless
+// it's zero. The deadline is shared by the meta refreshes of the page.
+func (bow *Browser) loadPage(req *http.Request, trigger NavigationTrigger, deadline time.Time) error {
+	req = withTrigger(req, trigger)
+	bow.setFetchMetadata(req, trigger)
+	err := bow.fire(&Event{Type: BeforeNavigate, URL: req.URL, Trigger: trigger, Labels: Labels(req)})
 	if err != nil {
 		return err
 	}
-	dom, err := goquery.NewDocumentFromResponse(resp)
+	bow.preSend()
+	req, err = bow.withDeadline(req, deadline)
 	if err != nil {
 		return err
 	}
-	bow.history.Push(bow.state)
-	bow.state = jar.NewHistoryState(req, resp, dom)
-	bow.postSend()
+	bow.loadDeadline = deadline
+	conditional := bow.conditional(req)
+	state, err := bow.fetch(req)
+	if err == nil {
+		state, err = bow.solveChallenge(req, state)
+	}
+	if err != nil {
+		return bow.deadlineError(req, deadline, err)
+	}
+	bow.notModified = conditional && state.Response.StatusCode == http.StatusNotModified
+	if bow.notModified {
+		return nil
+	}
+	bow.saveValidator(req, state.Response)
+	dropped := bow.state()
+	if trigger != TriggerBack {
+		bow.pushHistory()
+		dropped = nil
+	}
+	bow.setState(state)
+	bow.dropState(dropped)
+	atomic.AddInt64(&bow.counters.pages, 1)
+	err = bow.handleContent(bow.Page())
+
+	if len(bow.handlers[PageLoaded]) > 0 {
+		bow.document()
+		bow.fire(&Event{Type: PageLoaded, URL: bow.Url(), Trigger: trigger, Labels: Labels(req)})
+	}
+	if bow.state() == state {
+		bow.postSend()
+	}
 
-	return nil
+	return err
+}
+
+// fetch sends the request and returns the state of the page it responds with,
+// without changing the current page.
+//
+// The body is verified against the Content-Length header, and an
+// errors.TruncatedResponse is returned when it's shorter.
+func (bow *Browser) fetch(req *http.Request) (*jar.State, error) {
+	acceptEncoding(req)
+	var hints []http.Header
+	req = bow.traceEarlyHints(req, &hints)
+	resp, err := bow.send(req)
+	if err != nil {
+		return nil, err
+	}
+	wire := &countingReader{r: resp.Body}
+	resp.Body = &decodedBody{Reader: wire, Closer: resp.Body}
+	expected := resp.ContentLength
+	if req.Method == "HEAD" {
+		expected = -1
+	}
+	encoded := resp.Header.Get("Content-Encoding") != ""
+	if bow.attributes[LenientParsing] {
+		resp, err = bow.decodeLeniently(req, resp)
+		expected = -1
+	} else {
+		err = decodeResponse(resp)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	decoded := &countingReader{r: limitBody(req, resp.Body)}
+	body, closeArchive, err := bow.archiveBody(resp, decoded)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	state := jar.NewHistoryState(req, resp, nil)
+	state.EarlyHints = hints
+	err = bow.readBody(state, body)
+	closeArchive()
+	resp.Body.Close()
+	if encoded && resp.Header.Get("Content-Encoding") == "" {
+		bow.counters.countCompression(wire.n, decoded.n)
+	}
+	if err = verifyLength(req, expected, wire.n, err); err != nil {
+		return nil, err
+	}
+	return state, nil
 }
 
 // preSend sets browser state before sending a request.
 func (bow *Browser) preSend() {
+	bow.stopRefresh()
+}
+
+// stopRefresh stops the meta refresh timer.
+func (bow *Browser) stopRefresh() {
+	bow.refreshMutex.Lock()
+	defer bow.refreshMutex.Unlock()
 	if bow.refresh != nil {
 		bow.refresh.Stop()
 	}
 }
 
-// postSend sets browser state after sending a request.
+// postSend sets browser state after sending a request. It's called once the
+// page has been loaded, so a meta refresh never reloads the page while it's
+// still being loaded, and each refresh runs after the one before has ended.
 func (bow *Browser) postSend() {
-	if bow.attributes[MetaRefreshHandling] {
+	if bow.attributes[MetaRefreshHandling] && !bow.attributes[HTTPOnly] && bow.mayRefresh() {
 		sel := bow.Find("meta[http-equiv='refresh']")
 		if sel.Length() > 0 {
 			attr, ok := sel.Attr("content")
 			if ok {
 				dur, err := time.ParseDuration(attr + "s")
 				if err == nil {
-					bow.refresh = time.NewTimer(dur)
-					go func() {
-						<-bow.refresh.C
-						bow.Reload()
-					}()
+					bow.refreshMutex.Lock()
+					bow.refresh = time.AfterFunc(dur, bow.metaRefresh)
+					bow.refreshMutex.Unlock()
 				}
 			}
 		}
 	}
 }
 
+// document returns the document of the current page.
+//
+// The document is parsed from the body the first time it's needed, so pages
+// which are only inspected for their status or headers never pay the cost of
+// parsing. Pages which are not HTML are never parsed, and an empty document
+// is returned instead.
+func (bow *Browser) document() *goquery.Document {
+	return bow.documentOf(bow.state())
+}
+
+// documentOf returns the document of the given page, parsing it when needed.
+// Pages may be shared by goroutines, so the document is parsed at most once.
+// The ParseError events are fired once the document has been stored, so
+// their handlers may read it.
+func (bow *Browser) documentOf(s *jar.State) *goquery.Document {
+	var errs []error
+	report := func(err error) { errs = append(errs, err) }
+	dom := s.Document(func() *goquery.Document {
+		var dom *goquery.Document
+		var err error
+		if isHTML(s) && !bow.attributes[HTTPOnly] {
+			var r io.ReadCloser
+			if r, err = s.BodyReader(); err == nil {
+				dom, err = bow.parseBody(r, s.Request.URL, report)
+				r.Close()
+			}
+			if err != nil {
+				report(err)
+			}
+		}
+		if dom == nil || err != nil {
+			dom = goquery.NewDocumentFromNode(&html.Node{Type: html.DocumentNode})
+			dom.Url = s.Request.URL
+		}
+		return dom
+	})
+	for _, err := range errs {
+		bow.parseError(s.Request.URL, err)
+	}
+	return dom
+}
+
+// bodyOf returns the body of the given page as a string of html, or the
+// original body when the page is not HTML or the browser doesn't parse pages.
+func (bow *Browser) bodyOf(s *jar.State) string {
+	if !isHTML(s) || bow.attributes[HTTPOnly] {
+		r, err := s.BodyReader()
+		if err != nil {
+			return ""
+		}
+		defer r.Close()
+		body, _ := ioutil.ReadAll(r)
+		return string(body)
+	}
+	body, _ := bow.documentOf(s).Find("body").Html()
+	return body
+}
+
+// isHTML returns whether the current page is an HTML document.
+// Pages without a Content-Type header are assumed to be HTML.
+func (bow *Browser) isHTML() bool {
+	return isHTML(bow.state())
+}
+
+// isHTML returns whether the given page is an HTML document.
+func isHTML(s *jar.State) bool {
+	if s.Response == nil {
+		return true
+	}
+	ct := s.Response.Header.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return true
+	}
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// mayRefresh returns whether the current page may contain a refresh meta tag,
+// which avoids parsing pages that certainly do not.
+func (bow *Browser) mayRefresh() bool {
+	if bow.state().Parsed() || bow.state().BodyFile != "" {
+		return true
+	}
+	return bow.isHTML() && bytes.Contains(bytes.ToLower(bow.state().Body), []byte("refresh"))
+}
+
 // shouldRedirect is used as the value to http.Client.CheckRedirect.
-func (bow *Browser) shouldRedirect(req *http.Request, _ []*http.Request) error {
+func (bow *Browser) shouldRedirect(req *http.Request, via []*http.Request) error {
+	bow.recordHSTS(req.Response)
+	if opts := requestOptions(req); opts != nil && opts.NoFollowRedirects {
+		return http.ErrUseLastResponse
+	}
 	if bow.attributes[FollowRedirects] {
+		bow.upgradeHSTS(req)
+		bow.redirectFetchMetadata(req, via)
+		bow.applyProfile(req)
+		bow.authorize(req, via...)
+		bow.proxyAuthorize(req)
 		return nil
 	}
 	return errors.NewLocation(
 		"Redirects are disabled. Cannot follow '%s'.", req.URL.String())
 }
 
-// attributeToUrl reads an attribute from an element and returns a url.
-func (bow *Browser) attrToResolvedUrl(name string, sel *goquery.Selection) (*url.URL, error) {
-	src, ok := sel.Attr(name)
-	if !ok {
-		return nil, errors.NewAttributeNotFound(
-			"Attribute '%s' not found.", name)
-	}
-	ur, err := url.Parse(src)
-	if err != nil {
-		return nil, err
+// matchRel returns the first of the given link relations found in the rel
+// attribute of the element, or an empty string when none are found.
+func matchRel(sel *goquery.Selection, rels ...string) string {
+	for _, token := range strings.Fields(strings.ToLower(sel.AttrOr("rel", ""))) {
+		for _, rel := range rels {
+			if token == rel {
+				return rel
+			}
+		}
 	}
-
-	return bow.ResolveUrl(ur), nil
+	return ""
 }
 
 // attributeOrDefault reads an attribute and returns it or the default value when it's empty.
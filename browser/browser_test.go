@@ -0,0 +1,99 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+func TestLazyDom(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"title": "<title>not html</title>"}`)
+			return
+		}
+		fmt.Fprint(w, htmlForm)
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertTrue(bow.state().Dom == nil)
+	ut.AssertEquals("Echo Form", bow.Title())
+	ut.AssertTrue(bow.state().Dom != nil)
+
+	err = bow.Open(ts.URL + "/data.json")
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Title())
+	ut.AssertEquals(`{"title": "<title>not html</title>"}`, bow.Body())
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	ut.Run(t)
+	bow := &Browser{}
+	ut.AssertTrue(bow.buildTransport().Proxy != nil)
+
+	bow = &Browser{}
+	bow.attributes = AttributeMap{ProxyFromEnvironment: true}
+	ut.AssertTrue(bow.buildTransport().Proxy != nil)
+
+	bow.SetAttribute(ProxyFromEnvironment, false)
+	ut.AssertTrue(bow.buildTransport().Proxy == nil)
+
+	u, _ := url.Parse("http://proxy.example.com:3128")
+	bow.SetProxy(u)
+	proxy, err := bow.buildTransport().Proxy(&http.Request{URL: u})
+	ut.AssertNil(err)
+	ut.AssertEquals(u, proxy)
+}
+
+func TestFetchSite(t *testing.T) {
+	ut.Run(t)
+	from, _ := url.Parse("https://www.example.com/page")
+	for u, site := range map[string]string{
+		"https://www.example.com:443/next": "same-origin",
+		"https://shop.example.com/":        "same-site",
+		"http://www.example.com/":          "cross-site",
+		"https://www.example.org/":         "cross-site",
+	} {
+		to, _ := url.Parse(u)
+		ut.AssertEquals(site, fetchSite(from, to))
+	}
+	ut.AssertEquals("cross-site", fetchSite(nil, from))
+	ut.AssertEquals("same-site", furthestSite("same-origin", "same-site"))
+	ut.AssertEquals("cross-site", furthestSite("cross-site", "same-origin"))
+}
+
+func TestTransportSetters(t *testing.T) {
+	ut.Run(t)
+	bow := &Browser{}
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			bow.SetMaxIdleConns(i)
+			bow.SetIdleConnTimeout(time.Second)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		bow.SetMaxIdleConnsPerHost(i)
+		bow.SetDialTLSContext(nil)
+	}
+	<-done
+	transport := bow.buildTransport()
+	ut.AssertEquals(99, transport.MaxIdleConns)
+	ut.AssertEquals(99, transport.MaxIdleConnsPerHost)
+	ut.AssertEquals(time.Second, transport.IdleConnTimeout)
+}
@@ -0,0 +1,174 @@
+package browser
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+)
+
+// maxChallengeScan is the number of bytes of a body searched for the markers
+// of challenge signatures.
+const maxChallengeScan = 1 << 20
+
+// ChallengeSignature identifies an interstitial or anti-bot challenge page.
+// A page matches when its status code, header, and body match the signature.
+type ChallengeSignature struct {
+	// Name identifies the signature in events and errors.
+	Name string
+
+	// StatusCodes are the status codes of the challenge page. Empty matches
+	// any status code.
+	StatusCodes []int
+
+	// Header is the name of a response header which must be present. Empty
+	// matches any response.
+	Header string
+
+	// Markers are strings of which the body must contain at least one. Empty
+	// matches any body.
+	Markers []string
+}
+
+// DefaultChallengeSignatures are the signatures of common challenge pages.
+var DefaultChallengeSignatures = []ChallengeSignature{
+	{
+		Name:        "cloudflare",
+		StatusCodes: []int{http.StatusForbidden, http.StatusServiceUnavailable},
+		Markers:     []string{"cf-browser-verification", "challenge-platform", "cf_chl_opt"},
+	},
+	{
+		Name:   "cloudflare-mitigated",
+		Header: "Cf-Mitigated",
+	},
+	{
+		Name:        "akamai",
+		StatusCodes: []int{http.StatusForbidden},
+		Markers:     []string{"_abck", "Reference&#32;&#35;"},
+	},
+	{
+		Name:        "captcha",
+		StatusCodes: []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		Markers:     []string{"g-recaptcha", "h-captcha", "cf-turnstile"},
+	},
+}
+
+// ChallengeSolver is called with a challenge page, and returns nil once the
+// challenge has been solved, eg by setting the cookies issued by a solving
+// service, after which the request is sent again.
+type ChallengeSolver func(p *Page, signature string) error
+
+// DetectChallenges turns on the detection of challenge pages matching the
+// signatures, or DefaultChallengeSignatures when none are given.
+//
+// The Challenge event is fired for each page which matches, before the page
+// is loaded. Handlers may pause the browser by not returning until they are
+// ready, or cancel the navigation by returning an error. The challenge page
+// is then loaded as usual, unless a solver has been set.
+func (bow *Browser) DetectChallenges(sigs ...ChallengeSignature) {
+	if len(sigs) == 0 {
+		sigs = DefaultChallengeSignatures
+	}
+	bow.challenges = sigs
+}
+
+// SetChallengeSolver sets the function called with the challenge pages found
+// by DetectChallenges(). The request is sent once more after the solver
+// returns nil, and an errors.Challenge is returned when the page is still a
+// challenge. Passing nil removes the solver.
+func (bow *Browser) SetChallengeSolver(s ChallengeSolver) {
+	bow.challengeSolver = s
+}
+
+// solveChallenge returns the state of the page which answered the request,
+// which is the given state unless it's a challenge solved by the solver.
+func (bow *Browser) solveChallenge(req *http.Request, state *jar.State) (*jar.State, error) {
+	name := bow.matchChallenge(state)
+	if name == "" {
+		return state, nil
+	}
+	p := &Page{bow: bow, state: state}
+	err := bow.fire(&Event{Type: Challenge, URL: req.URL, Challenge: name, Labels: Labels(req)})
+	if err != nil || bow.challengeSolver == nil {
+		return state, err
+	}
+	if err = bow.challengeSolver(p, name); err != nil {
+		return nil, err
+	}
+
+	retry := replayRequest(req)
+	if retry == nil {
+		return nil, errors.NewChallenge(req.URL.String(), name)
+	}
+	retry.Header.Del("Cookie")
+	bow.dropState(state)
+	if state, err = bow.fetch(retry); err != nil {
+		return nil, err
+	}
+	if name = bow.matchChallenge(state); name != "" {
+		bow.dropState(state)
+		return nil, errors.NewChallenge(req.URL.String(), name)
+	}
+	return state, nil
+}
+
+// matchChallenge returns the name of the first signature matching the page,
+// or an empty string when the page is not a challenge.
+func (bow *Browser) matchChallenge(state *jar.State) string {
+	if len(bow.challenges) == 0 {
+		return ""
+	}
+	var body []byte
+	for _, sig := range bow.challenges {
+		if len(sig.StatusCodes) > 0 && !containsInt(sig.StatusCodes, state.Response.StatusCode) {
+			continue
+		}
+		if sig.Header != "" && state.Response.Header.Get(sig.Header) == "" {
+			continue
+		}
+		if len(sig.Markers) > 0 {
+			if body == nil {
+				body = challengeBody(state)
+			}
+			if !containsMarker(body, sig.Markers) {
+				continue
+			}
+		}
+		return sig.Name
+	}
+	return ""
+}
+
+// challengeBody returns the beginning of the body of the page.
+func challengeBody(state *jar.State) []byte {
+	r, err := state.BodyReader()
+	if err != nil {
+		return []byte{}
+	}
+	defer r.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(r, maxChallengeScan))
+	return body
+}
+
+// containsMarker returns whether the body contains one of the markers.
+func containsMarker(body []byte, markers []string) bool {
+	for _, m := range markers {
+		if bytes.Contains(body, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsInt returns whether the slice contains the value.
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
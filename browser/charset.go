@@ -0,0 +1,134 @@
+package browser
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"regexp"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// SetCharset sets the character encoding used to decode page bodies,
+// eg "windows-1251" or "shift_jis".
+//
+// The encoding is applied to every subsequent page, and the current page is
+// parsed again using the new encoding. This is useful for servers that
+// declare the wrong encoding. Passing an empty string removes the override
+// and pages are once again parsed as UTF-8.
+func (bow *Browser) SetCharset(name string) error {
+	if name != "" {
+		if enc, _ := charset.Lookup(name); enc == nil {
+			return errors.New(
+				"Unknown charset '%s'.", name)
+		}
+	}
+	bow.charset = name
+	if bow.state() != nil && bow.state().HasBody() {
+		return bow.Reparse()
+	}
+	return nil
+}
+
+// Charset returns the character encoding set with SetCharset().
+func (bow *Browser) Charset() string {
+	return bow.charset
+}
+
+// Reparse discards the DOM of the current page, which is built again from
+// the original body, using the current charset, the next time it's needed.
+func (bow *Browser) Reparse() error {
+	if bow.state() == nil || !bow.state().HasBody() {
+		return errors.NewPageNotLoaded("Cannot reparse, no page has been loaded.")
+	}
+	bow.state().SetDocument(nil)
+	return nil
+}
+
+// parseBody decodes the body using the browser charset, and returns the
+// parsed document. Without a charset, bodies which are not valid UTF-8 are
+// decoded using the charset found in the body when the LenientParsing
+// attribute is set, and the error describing it is passed to report.
+func (bow *Browser) parseBody(r io.Reader, u *url.URL, report func(error)) (*goquery.Document, error) {
+	if bow.charset != "" {
+		enc, _ := charset.Lookup(bow.charset)
+		r = enc.NewDecoder().Reader(r)
+	} else if bow.attributes[LenientParsing] {
+		var err error
+		if r, err = bow.sniffCharset(r, u, report); err != nil {
+			return nil, err
+		}
+	}
+	dom, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	dom.Url = u
+
+	return dom, nil
+}
+
+// DownloadAs writes the body of the current page to the writer, transcoded
+// to the given charset, eg "iso-8859-1" or "shift_jis".
+//
+// The body is decoded using the charset set with SetCharset(), or else the
+// charset declared by the response or found in the body. The charset
+// declarations in the <meta> elements of HTML pages are rewritten to the new
+// charset, and a <meta charset> element is added to the <head> when there is
+// none. Characters which the charset cannot represent are written as HTML
+// character references. Returns the number of bytes written.
+func (bow *Browser) DownloadAs(o io.Writer, name string) (int64, error) {
+	enc, canonical := charset.Lookup(name)
+	if enc == nil {
+		return 0, errors.New("Unknown charset '%s'.", name)
+	}
+	body, err := bow.RawBody()
+	if err != nil {
+		return 0, err
+	}
+
+	var src encoding.Encoding
+	if bow.charset != "" {
+		src, _ = charset.Lookup(bow.charset)
+	} else {
+		src, _, _ = charset.DetermineEncoding(body, bow.ResponseHeaders().Get("Content-Type"))
+	}
+	if body, err = src.NewDecoder().Bytes(body); err != nil {
+		return 0, err
+	}
+
+	if isHTML(bow.state()) {
+		body = declareCharset(body, canonical)
+	}
+	if body, err = enc.NewEncoder().Bytes(body); err != nil {
+		return 0, err
+	}
+	n, err := o.Write(body)
+	return int64(n), err
+}
+
+var (
+	// metaCharset matches the charset declared by a <meta> element, either
+	// in its charset attribute or in the content of a Content-Type pragma.
+	metaCharset = regexp.MustCompile(`(?is)(<meta\b[^>]*?\bcharset\s*=\s*["']?)[^"'\s;/>]+`)
+
+	// headTag matches the opening <head> tag.
+	headTag = regexp.MustCompile(`(?i)<head\b[^>]*>`)
+)
+
+// declareCharset rewrites the charset declarations of the HTML document to
+// the given charset, or adds one after the opening <head> tag.
+func declareCharset(body []byte, name string) []byte {
+	if metaCharset.Match(body) {
+		return metaCharset.ReplaceAll(body, []byte("${1}"+name))
+	}
+	loc := headTag.FindIndex(body)
+	if loc == nil {
+		return body
+	}
+	meta := []byte(`<meta charset="` + name + `">`)
+	return bytes.Join([][]byte{body[:loc[1]], meta, body[loc[1]:]}, nil)
+}
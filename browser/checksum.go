@@ -0,0 +1,90 @@
+package browser
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// Checksum is the expected digest of a download.
+type Checksum struct {
+	// Algorithm is the name of the hash algorithm, either "sha256" or "md5".
+	Algorithm string
+
+	// Sum is the expected digest, hex encoded.
+	Sum string
+}
+
+// newHash returns a new hash.Hash for the checksum algorithm.
+func (c Checksum) newHash() (hash.Hash, error) {
+	switch strings.ToLower(c.Algorithm) {
+	case "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, errors.New(
+		"Unsupported checksum algorithm '%s'.", c.Algorithm)
+}
+
+// SiblingChecksum reads the checksum for an asset from a sibling URL, which
+// is the asset URL with "." and the algorithm name appended, eg
+// "http://example.com/archive.tar.gz.sha256".
+//
+// The sibling file may contain only the digest, or use the format written by
+// the sha256sum and md5sum tools.
+func SiblingChecksum(asset Assetable, algorithm string) (Checksum, error) {
+	u := asset.Url().String() + "." + strings.ToLower(algorithm)
+	resp, err := assetClient(asset).Get(u)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Checksum{}, errors.NewPageNotFound(
+			"Checksum '%s' returned status %d.", u, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return Checksum{}, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return Checksum{}, errors.New(
+			"Checksum '%s' is empty.", u)
+	}
+
+	return Checksum{Algorithm: algorithm, Sum: fields[0]}, nil
+}
+
+// DownloadAssetVerified copies a remote file to the given writer, and
+// verifies its digest while streaming.
+//
+// The data is written to out as it's received, so callers should discard what
+// was written when an error is returned. Returns an errors.ChecksumMismatch
+// when the digest does not match.
+func DownloadAssetVerified(asset Downloadable, out io.Writer, sum Checksum) (int64, error) {
+	h, err := sum.newHash()
+	if err != nil {
+		return 0, err
+	}
+	return trackDownload(asset, func() (int64, error) {
+		l, err := downloadAsset(asset, io.MultiWriter(out, h))
+		if err != nil {
+			return l, err
+		}
+		actual := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(actual, sum.Sum) {
+			return l, errors.NewChecksumMismatch(sum.Sum, actual)
+		}
+
+		return l, nil
+	})
+}
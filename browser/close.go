@@ -0,0 +1,67 @@
+package browser
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+)
+
+// Close releases the resources held by the browser.
+//
+// The meta refresh timer and the keep-alive task are stopped, requests in
+// flight are canceled, idle connections are closed, spooled response bodies
+// are removed, and the jars which persist their contents are flushed. Requests sent after the browser has
+// been closed fail. The first error flushing a jar is returned.
+func (bow *Browser) Close() error {
+	bow.closeMutex.Lock()
+	bow.closed = true
+	inflight := bow.inflight
+	bow.inflight = nil
+	bow.closeMutex.Unlock()
+
+	bow.stopRefresh()
+	bow.StopKeepAlive()
+	for _, cancel := range inflight {
+		cancel()
+	}
+	if bow.transport != nil {
+		bow.transport.CloseIdleConnections()
+	}
+	bow.removeSpooled()
+
+	var err error
+	for _, j := range []interface{}{bow.cookies, bow.bookmarks, bow.history, bow.credentials, bow.hsts, bow.validators} {
+		if f, ok := j.(jar.Flusher); ok {
+			if ferr := f.Flush(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+	}
+	return err
+}
+
+// track makes the request cancelable by Close(), and returns the request
+// along with the function which stops tracking it.
+func (bow *Browser) track(req *http.Request) (*http.Request, func(), error) {
+	bow.closeMutex.Lock()
+	defer bow.closeMutex.Unlock()
+	if bow.closed {
+		return nil, nil, errors.New("Browser has been closed.")
+	}
+	if bow.inflight == nil {
+		bow.inflight = make(map[*http.Request]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+	bow.inflight[req] = cancel
+
+	// The context isn't canceled once the request is done, since requests
+	// which answer an authentication challenge are copied from the response.
+	return req, func() {
+		bow.closeMutex.Lock()
+		delete(bow.inflight, req)
+		bow.closeMutex.Unlock()
+	}, nil
+}
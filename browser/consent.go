@@ -0,0 +1,123 @@
+package browser
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// ConsentAction is the choice made on a cookie consent banner.
+type ConsentAction int
+
+const (
+	// ConsentReject rejects the optional cookies.
+	ConsentReject ConsentAction = iota
+
+	// ConsentAccept accepts all the cookies.
+	ConsentAccept
+)
+
+// ConsentSelectors are the selectors of the reject and accept controls of
+// common consent management platforms, tried in order by HandleConsent().
+var ConsentSelectors = map[ConsentAction][]string{
+	ConsentReject: {
+		"#onetrust-reject-all-handler",
+		"#CybotCookiebotDialogBodyButtonDecline",
+		"button.fc-cta-do-not-consent",
+		"[data-cookiefirst-action=reject]",
+		"#didomi-notice-disagree-button",
+		".cc-deny",
+	},
+	ConsentAccept: {
+		"#onetrust-accept-btn-handler",
+		"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll",
+		"button.fc-cta-consent",
+		"[data-cookiefirst-action=accept]",
+		"#didomi-notice-agree-button",
+		".cc-allow",
+	},
+}
+
+// ConsentTexts are the texts of the reject and accept links and buttons of
+// consent banners, tried in order by HandleConsent() when no selector
+// matches. Texts are compared without regard to case.
+var ConsentTexts = map[ConsentAction][]string{
+	ConsentReject: {"Reject all", "Reject", "Decline all", "Decline", "Refuse all", "Deny", "Only necessary"},
+	ConsentAccept: {"Accept all", "Accept", "Allow all", "I agree", "Agree", "OK"},
+}
+
+// consentBanners selects the elements which may hold a consent banner. Only
+// the links and buttons within them are matched by text.
+const consentBanners = "[id*=consent],[class*=consent],[id*=cookie],[class*=cookie],[id*=gdpr],[class*=gdpr],[role=dialog]"
+
+// HandleConsent looks for a cookie consent banner on the current page, and
+// clicks its reject or accept control, since consent walls frequently hide
+// the content of the page.
+//
+// The controls are found by ConsentSelectors, or by ConsentTexts within
+// elements which look like consent banners. Links are followed, and buttons
+// submit their form, so the page loaded afterwards is usually the page with
+// the consent cookies set. Controls which work only with JavaScript are
+// skipped. Returns false when no banner was handled.
+func (bow *Browser) HandleConsent(action ConsentAction) (bool, error) {
+	if bow.state() == nil || bow.state().Request == nil {
+		return false, errors.NewPageNotLoaded("Cannot handle consent, no page has been loaded.")
+	}
+	sel := bow.consentControl(action)
+	if sel == nil {
+		return false, nil
+	}
+	if sel.Is("a") {
+		href, err := bow.ResolutionContext().resolveAttr("href", sel)
+		if err != nil {
+			return false, err
+		}
+		return true, bow.httpGET(href, bow.Url(), TriggerClick)
+	}
+	return true, NewForm(bow, sel.Closest("form")).clickButton(sel)
+}
+
+// consentControl returns the first control of the action which may be
+// clicked without JavaScript, or nil when there is none.
+func (bow *Browser) consentControl(action ConsentAction) *goquery.Selection {
+	for _, expr := range ConsentSelectors[action] {
+		if sel := clickable(bow.Find(expr)); sel != nil {
+			return sel
+		}
+	}
+	controls := bow.Find(consentBanners).Find("a[href]," + submitButtons)
+	for _, text := range ConsentTexts[action] {
+		text = normalizeText(text)
+		sel := clickable(controls.FilterFunction(func(_ int, s *goquery.Selection) bool {
+			t := s.Text()
+			if goquery.NodeName(s) == "input" {
+				t = s.AttrOr("value", "")
+			}
+			return strings.EqualFold(normalizeText(t), text)
+		}))
+		if sel != nil {
+			return sel
+		}
+	}
+	return nil
+}
+
+// clickable returns the first element of the selection which is a link, or
+// a submit button within a form, or nil when there is none.
+func clickable(sel *goquery.Selection) *goquery.Selection {
+	for i := range sel.Nodes {
+		s := sel.Eq(i)
+		if s.Is("a[href]") {
+			href := strings.TrimSpace(s.AttrOr("href", ""))
+			if href != "" && href != "#" && !strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				return s
+			}
+			continue
+		}
+		if s.Is(submitButtons) && s.Closest("form").Length() > 0 {
+			return s
+		}
+	}
+	return nil
+}
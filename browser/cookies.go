@@ -0,0 +1,59 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PinCookieHeader sends the given raw Cookie header value with every request
+// to the host, in place of the cookies stored in the jar.
+//
+// The value is sent exactly as given, including on redirects, which is
+// useful to replay a captured session or to test how a server handles a
+// specific cookie string. Cookies set by the host are still stored in the
+// jar unless it's frozen.
+func (bow *Browser) PinCookieHeader(host, value string) {
+	if bow.pinnedCookies == nil {
+		bow.pinnedCookies = make(map[string]string)
+	}
+	bow.pinnedCookies[strings.ToLower(host)] = value
+}
+
+// UnpinCookieHeader stops sending the Cookie header pinned for the host, and
+// sends the cookies stored in the jar instead.
+func (bow *Browser) UnpinCookieHeader(host string) {
+	delete(bow.pinnedCookies, strings.ToLower(host))
+}
+
+// FreezeCookies makes the cookie jar read-only when frozen is true. The
+// cookies in the jar are still sent, but cookies set by responses are
+// discarded.
+func (bow *Browser) FreezeCookies(frozen bool) {
+	bow.cookiesFrozen = frozen
+}
+
+// pinnedCookie returns the Cookie header pinned for the host of the URL.
+func (bow *Browser) pinnedCookie(u *url.URL) (string, bool) {
+	if len(bow.pinnedCookies) == 0 {
+		return "", false
+	}
+	value, ok := bow.pinnedCookies[strings.ToLower(u.Hostname())]
+	return value, ok
+}
+
+// pinnedCookieTransport sets the pinned Cookie header of each request sent
+// through the transport, including the requests which follow redirects.
+type pinnedCookieTransport struct {
+	bow       *Browser
+	transport http.RoundTripper
+}
+
+// RoundTrip sends the request with the pinned Cookie header for its host.
+func (t *pinnedCookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if value, ok := t.bow.pinnedCookie(req.URL); ok {
+		req = req.Clone(req.Context())
+		req.Header.Set("Cookie", value)
+	}
+	return t.transport.RoundTrip(req)
+}
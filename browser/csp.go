@@ -0,0 +1,216 @@
+package browser
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CSP is a parsed Content-Security-Policy.
+type CSP struct {
+	// Directives maps the lower case directive names to their source lists.
+	Directives map[string][]string
+
+	// ReportOnly is whether the policy was sent using the
+	// Content-Security-Policy-Report-Only header, and is not enforced.
+	ReportOnly bool
+}
+
+// ParseCSP parses the value of a Content-Security-Policy header.
+//
+// Only the first occurrence of each directive is kept, as browsers do.
+func ParseCSP(header string) *CSP {
+	csp := &CSP{Directives: make(map[string][]string)}
+	for _, directive := range strings.Split(header, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := csp.Directives[name]; !ok {
+			csp.Directives[name] = fields[1:]
+		}
+	}
+	return csp
+}
+
+// Sources returns the source list which applies to the given fetch directive,
+// such as "script-src", falling back to the default-src directive. Returns
+// false when neither directive is present.
+func (csp *CSP) Sources(directive string) ([]string, bool) {
+	if sources, ok := csp.Directives[directive]; ok {
+		return sources, true
+	}
+	sources, ok := csp.Directives["default-src"]
+	return sources, ok
+}
+
+// Allows returns whether the policy allows loading u using the given fetch
+// directive, on a page found at self.
+//
+// Nonces, hashes and the other keywords which do not match URLs are ignored.
+func (csp *CSP) Allows(directive string, u, self *url.URL) bool {
+	sources, ok := csp.Sources(directive)
+	if !ok {
+		return true
+	}
+	for _, source := range sources {
+		if cspSourceMatch(source, u, self) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSPViolation is a page asset which a policy does not allow.
+type CSPViolation struct {
+	// Asset is the asset which violates the policy.
+	Asset Assetable
+
+	// Directive is the directive which does not allow the asset.
+	Directive string
+
+	// Policy is the violated policy.
+	Policy *CSP
+}
+
+// CSP returns the policies sent with the current page, in the order the
+// headers were received. Report-only policies are included, and marked.
+func (bow *Browser) CSP() []*CSP {
+	var policies []*CSP
+	if bow.state() == nil || bow.state().Response == nil {
+		return policies
+	}
+	header := bow.state().Response.Header
+	for _, h := range header["Content-Security-Policy"] {
+		policies = append(policies, ParseCSP(h))
+	}
+	for _, h := range header["Content-Security-Policy-Report-Only"] {
+		csp := ParseCSP(h)
+		csp.ReportOnly = true
+		policies = append(policies, csp)
+	}
+	return policies
+}
+
+// CSPViolations returns the images, scripts and stylesheets of the current
+// page which are not allowed by its policies.
+func (bow *Browser) CSPViolations() []CSPViolation {
+	policies := bow.CSP()
+	if len(policies) == 0 {
+		return nil
+	}
+
+	type checked struct {
+		asset     Assetable
+		directive string
+	}
+	var assets []checked
+	for _, a := range bow.Images() {
+		assets = append(assets, checked{a, "img-src"})
+	}
+	for _, a := range bow.Scripts() {
+		assets = append(assets, checked{a, "script-src"})
+	}
+	for _, a := range bow.Stylesheets() {
+		assets = append(assets, checked{a, "style-src"})
+	}
+
+	var violations []CSPViolation
+	for _, policy := range policies {
+		for _, a := range assets {
+			if !policy.Allows(a.directive, a.asset.Url(), bow.Url()) {
+				violations = append(violations, CSPViolation{
+					Asset:     a.asset,
+					Directive: a.directive,
+					Policy:    policy,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// cspSourceMatch returns whether the source expression matches u.
+func cspSourceMatch(source string, u, self *url.URL) bool {
+	source = strings.ToLower(source)
+	scheme := strings.ToLower(u.Scheme)
+	switch {
+	case source == "*":
+		return scheme != "data" && scheme != "blob" && scheme != "filesystem"
+	case source == "'self'":
+		return self != nil && cspSchemeMatch(self.Scheme, scheme) &&
+			strings.EqualFold(self.Host, u.Host)
+	case strings.HasPrefix(source, "'"):
+		return false
+	case strings.HasSuffix(source, ":"):
+		return cspSchemeMatch(strings.TrimSuffix(source, ":"), scheme)
+	}
+
+	if i := strings.Index(source, "://"); i >= 0 {
+		if !cspSchemeMatch(source[:i], scheme) {
+			return false
+		}
+		source = source[i+3:]
+	} else if self != nil && !cspSchemeMatch(self.Scheme, scheme) {
+		return false
+	}
+	path := ""
+	if i := strings.Index(source, "/"); i >= 0 {
+		source, path = source[:i], source[i:]
+	}
+	host, port := source, ""
+	if i := strings.LastIndex(source, ":"); i >= 0 {
+		host, port = source[:i], source[i+1:]
+	}
+
+	uhost := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(host, "*.") {
+		if !strings.HasSuffix(uhost, host[1:]) {
+			return false
+		}
+	} else if host != uhost {
+		return false
+	}
+	if port == "" {
+		port = cspPort("", scheme)
+	}
+	if port != "*" && port != cspPort(u.Port(), scheme) {
+		return false
+	}
+	if path != "" {
+		if strings.HasSuffix(path, "/") {
+			return strings.HasPrefix(u.Path, path)
+		}
+		return u.Path == path
+	}
+	return true
+}
+
+// cspSchemeMatch returns whether the scheme of a source expression matches
+// the scheme of a URL, allowing secure upgrades.
+func cspSchemeMatch(source, scheme string) bool {
+	source = strings.ToLower(source)
+	switch {
+	case source == scheme:
+		return true
+	case source == "http":
+		return scheme == "https"
+	case source == "ws":
+		return scheme == "wss" || scheme == "http" || scheme == "https"
+	}
+	return false
+}
+
+// cspPort returns the port of a URL, or the default port of its scheme.
+func cspPort(port, scheme string) string {
+	if port != "" {
+		return port
+	}
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
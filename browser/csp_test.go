@@ -0,0 +1,45 @@
+package browser
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestCSPAllows(t *testing.T) {
+	ut.Run(t)
+	csp := ParseCSP("default-src 'self'; img-src * data:; script-src 'self' https://cdn.example.com/js/ *.static.example.org:*; style-src 'none'; script-src 'unsafe-inline'")
+	self, _ := url.Parse("http://example.com/page")
+
+	tests := []struct {
+		directive string
+		url       string
+		allowed   bool
+	}{
+		{"img-src", "http://images.example.net/a.png", true},
+		{"img-src", "data:image/png;base64,AAAA", true},
+		{"script-src", "http://example.com/app.js", true},
+		{"script-src", "https://example.com/app.js", true},
+		{"script-src", "http://example.com:8080/app.js", false},
+		{"script-src", "https://cdn.example.com/js/lib.js", true},
+		{"script-src", "https://cdn.example.com/css/lib.js", false},
+		{"script-src", "http://a.static.example.org:9000/x.js", true},
+		{"script-src", "http://static.example.org/x.js", false},
+		{"script-src", "http://evil.example.net/x.js", false},
+		{"style-src", "http://example.com/style.css", false},
+		{"font-src", "http://example.com/font.woff", true},
+		{"font-src", "http://fonts.example.net/font.woff", false},
+	}
+	for _, tc := range tests {
+		u, _ := url.Parse(tc.url)
+		if csp.Allows(tc.directive, u, self) != tc.allowed {
+			t.Errorf("Allows(%s, %s) != %v", tc.directive, tc.url, tc.allowed)
+		}
+	}
+
+	csp = ParseCSP("img-src https:")
+	u, _ := url.Parse("http://example.com/script.js")
+	ut.AssertTrue(csp.Allows("script-src", u, self))
+	ut.AssertFalse(csp.Allows("img-src", u, self))
+}
@@ -0,0 +1,59 @@
+package browser
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// SetPageLoadTimeout limits the time taken to load each page, including
+// following redirects, reading the body, and the meta refreshes of the page.
+//
+// Loading a page which takes longer fails with an errors.PageLoadTimeout, and
+// meta refreshes are no longer followed once the time is up, so a single
+// pathological page cannot stall a crawl. Zero, the default, means no limit.
+func (bow *Browser) SetPageLoadTimeout(d time.Duration) {
+	bow.pageLoadTimeout = d
+}
+
+// metaRefresh reloads the current page when its refresh meta tag fires, as
+// long as the page load deadline has not passed.
+func (bow *Browser) metaRefresh() {
+	deadline := bow.loadDeadline
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return
+	}
+	req, err := bow.reloadRequest(TriggerRefresh, false)
+	if err != nil {
+		return
+	}
+	bow.loadPage(req, TriggerRefresh, deadline)
+}
+
+// withDeadline returns a copy of the request which must be answered before
+// the deadline. The request is returned unchanged when the deadline is zero.
+func (bow *Browser) withDeadline(req *http.Request, deadline time.Time) (*http.Request, error) {
+	if deadline.IsZero() {
+		return req, nil
+	}
+	if !time.Now().Before(deadline) {
+		return nil, errors.NewPageLoadTimeout(req.URL.String(), bow.pageLoadTimeout)
+	}
+	opts := &RequestOptions{}
+	if o := requestOptions(req); o != nil {
+		*opts = *o
+	}
+	opts.deadline = deadline
+	return req.WithContext(context.WithValue(req.Context(), requestOptionsKey{}, opts)), nil
+}
+
+// deadlineError returns an errors.PageLoadTimeout in place of err when the
+// deadline has passed.
+func (bow *Browser) deadlineError(req *http.Request, deadline time.Time, err error) error {
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return errors.NewPageLoadTimeout(req.URL.String(), bow.pageLoadTimeout)
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package browser
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// DownloadAssetSegmented downloads an asset in concurrent segments and writes
+// each segment to the given writer at its offset.
+//
+// The server must report the asset size and advertise "Accept-Ranges: bytes"
+// in response to a HEAD request. The asset is downloaded with a single
+// request when it does not, or when segments is less than two.
+//
+// The requests carry the user agent and the headers of the browser which
+// found the asset.
+//
+// Returns the number of bytes written.
+func DownloadAssetSegmented(asset Downloadable, out io.WriterAt, segments int) (int64, error) {
+	return trackDownload(asset, func() (int64, error) {
+		return downloadSegmented(asset, out, segments)
+	})
+}
+
+// downloadSegmented downloads an asset in concurrent segments without firing events.
+func downloadSegmented(asset Downloadable, out io.WriterAt, segments int) (int64, error) {
+	client := assetClient(asset)
+	req, err := assetRequest(asset, "HEAD")
+	if err != nil {
+		return 0, err
+	}
+	size, ok, err := probeRanges(client, req)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || segments < 2 || size < int64(segments) {
+		return downloadAsset(asset, io.NewOffsetWriter(out, 0))
+	}
+
+	// The requests are built before the downloads start, since building them
+	// reads the settings of the browser.
+	reqs := make([]*http.Request, segments)
+	for i := range reqs {
+		if reqs[i], err = assetRequest(asset, "GET"); err != nil {
+			return 0, err
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, segments)
+	segSize := size / int64(segments)
+	for i := 0; i < segments; i++ {
+		start := int64(i) * segSize
+		end := start + segSize - 1
+		if i == segments-1 {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			errs[i] = downloadRange(client, reqs[i], io.NewOffsetWriter(out, start), start, end)
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// assetRequest builds a request for the asset, carrying the user agent and
+// the headers of the browser which found it when there is one.
+func assetRequest(asset Assetable, method string) (*http.Request, error) {
+	if bow := ownerOf(asset); bow != nil {
+		return bow.buildRequest(method, asset.Url().String(), nil, nil)
+	}
+	return http.NewRequest(method, asset.Url().String(), nil)
+}
+
+// probeRanges sends the HEAD request using the client, and returns the size
+// of the resource and whether the server accepts byte ranges.
+func probeRanges(client *http.Client, req *http.Request) (int64, bool, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	resp.Body.Close()
+
+	ok := resp.StatusCode == http.StatusOK &&
+		resp.Header.Get("Accept-Ranges") == "bytes" &&
+		resp.ContentLength > 0
+	return resp.ContentLength, ok, nil
+}
+
+// downloadRange copies the bytes between start and end, inclusive, requested
+// by the GET request to the writer using the client.
+func downloadRange(client *http.Client, req *http.Request, out io.Writer, start, end int64) error {
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return errors.New(
+			"Range request for '%s' returned status %d.", req.URL, resp.StatusCode)
+	}
+
+	_, err = io.CopyN(out, resp.Body, end-start+1)
+	return err
+}
@@ -0,0 +1,89 @@
+package browser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/headzoo/ut"
+)
+
+func TestDownloadSegmented(t *testing.T) {
+	ut.Run(t)
+	content := strings.Repeat("0123456789", 1000)
+	var ranges, agents int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+		if r.UserAgent() == "Segments/1.0" && r.Header.Get("X-Token") == "abc" {
+			atomic.AddInt32(&agents, 1)
+		}
+		http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer ts.Close()
+
+	out, err := ioutil.TempFile("", "surf")
+	ut.AssertNil(err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	u, _ := url.Parse(ts.URL)
+	asset := NewImageAsset(u, "", "", "")
+	l, err := asset.DownloadSegmented(out, 4)
+	ut.AssertNil(err)
+	ut.AssertEquals(len(content), int(l))
+	ut.AssertEquals(4, int(atomic.LoadInt32(&ranges)))
+
+	b, err := ioutil.ReadFile(out.Name())
+	ut.AssertNil(err)
+	ut.AssertTrue(bytes.Equal([]byte(content), b))
+
+	// The probe and the range requests are sent like the browser requests.
+	bow := &Browser{userAgent: "Segments/1.0", headers: http.Header{"X-Token": {"abc"}}}
+	asset.bow = bow
+	_, err = asset.DownloadSegmented(out, 4)
+	ut.AssertNil(err)
+	ut.AssertEquals(5, int(atomic.LoadInt32(&agents)))
+}
+
+func TestDownloadVerified(t *testing.T) {
+	ut.Run(t)
+	content := "surf archive contents"
+	sum := sha256.Sum256([]byte(content))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x  archive.tar.gz\n", sum)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/archive.tar.gz")
+	asset := NewImageAsset(u, "", "", "")
+	checksum, err := SiblingChecksum(asset, "sha256")
+	ut.AssertNil(err)
+	ut.AssertEquals(fmt.Sprintf("%x", sum), checksum.Sum)
+
+	out := &bytes.Buffer{}
+	l, err := DownloadAssetVerified(asset, out, checksum)
+	ut.AssertNil(err)
+	ut.AssertEquals(len(content), int(l))
+
+	out.Reset()
+	_, err = DownloadAssetVerified(asset, out, Checksum{Algorithm: "md5", Sum: "00"})
+	mismatch, ok := err.(errors.ChecksumMismatch)
+	ut.AssertTrue(ok)
+	ut.AssertEquals("00", mismatch.Expected)
+}
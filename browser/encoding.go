@@ -0,0 +1,167 @@
+package browser
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/haruyama/surf/agent"
+	"github.com/haruyama/surf/errors"
+)
+
+// Emulate configures the browser to send the same headers as the browser
+// described by the profile.
+//
+// The user agent is replaced, the profile headers are set in the headers
+// jar, replacing any values with the same names, and the headers are sent in
+// the order of the profile. The Sec-Fetch-Site and Sec-Fetch-User headers are
+// set for each navigation from what started it. Responses compressed using an
+// encoding advertised by the profile are decompressed transparently.
+func (bow *Browser) Emulate(p agent.Profile) {
+	bow.SetUserAgent(p.UserAgent)
+	if bow.headers == nil {
+		bow.headers = make(http.Header, len(p.Headers))
+	}
+	for _, h := range p.Headers {
+		bow.headers.Set(h.Name, h.Value)
+	}
+	bow.fetchSite = p.FetchSite
+	bow.fetchUser = p.FetchUser
+	bow.SetHeaderOrder(p.HeaderOrder...)
+}
+
+// decodeResponse replaces the response body with a reader which decompresses
+// it, when the Content-Encoding header names a supported encoding.
+//
+// The Go transport only decompresses responses when it set the
+// Accept-Encoding header itself, which it does not when the header was
+// set explicitly, eg by Emulate(). Responses which have no body, such as the
+// responses to HEAD requests and 304 Not Modified responses, are left alone,
+// and an empty body is read as empty instead of failing.
+func decodeResponse(resp *http.Response) error {
+	var open func(r *bufio.Reader) (io.Reader, error)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		open = func(r *bufio.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = openDeflate
+	case "br":
+		open = func(r *bufio.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+	default:
+		return nil
+	}
+	if !hasBody(resp) {
+		return nil
+	}
+
+	resp.Body = &decodedBody{Reader: &lazyDecoder{r: bufio.NewReader(resp.Body), open: open}, Closer: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// hasBody returns whether the response may have a body.
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == "HEAD" {
+		return false
+	}
+	switch {
+	case resp.StatusCode >= 100 && resp.StatusCode < 200:
+		return false
+	case resp.StatusCode == http.StatusNoContent, resp.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+// openDeflate returns a reader decompressing a deflate body, which is zlib
+// wrapped according to the HTTP specification, although some servers send
+// raw deflate data.
+func openDeflate(r *bufio.Reader) (io.Reader, error) {
+	header, err := r.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+	return flate.NewReader(r), nil
+}
+
+// lazyDecoder opens the decompressing reader on the first read, so an empty
+// body reads as empty.
+type lazyDecoder struct {
+	r    *bufio.Reader
+	open func(r *bufio.Reader) (io.Reader, error)
+	dec  io.Reader
+	err  error
+}
+
+// Read reads decompressed data.
+func (ld *lazyDecoder) Read(p []byte) (int, error) {
+	if ld.dec == nil && ld.err == nil {
+		if _, err := ld.r.Peek(1); err != nil {
+			ld.err = err
+		} else {
+			ld.dec, ld.err = ld.open(ld.r)
+		}
+	}
+	if ld.err != nil {
+		return 0, ld.err
+	}
+	return ld.dec.Read(p)
+}
+
+// acceptEncoding asks for a gzip compressed response, unless the request
+// already names the encodings it accepts.
+//
+// The Go transport would ask for gzip itself, but then it decompresses the
+// response before the compressed bytes can be counted. The response is
+// decompressed by decodeResponse(), which leaves bodiless responses, such as
+// 204 and 304 responses, alone.
+func acceptEncoding(req *http.Request) {
+	if req.Method == "HEAD" || req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// verifyLength returns an errors.TruncatedResponse when fewer bytes than the
+// expected length were received, and err otherwise. Expected is -1 when the
+// length is unknown.
+func verifyLength(req *http.Request, expected, received int64, err error) error {
+	if expected < 0 {
+		return err
+	}
+	if err != nil && !stderrors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	if err != nil || received < expected {
+		return errors.NewTruncatedResponse(req.URL.String(), expected, received)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the reader, and counts the bytes read.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// decodedBody reads decompressed data and closes the original body.
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,404 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+)
+
+// EventType identifies an event fired by the browser.
+type EventType int
+
+const (
+	// CookieSet is fired when a response sets a cookie the browser did not
+	// have. Returning an error from a handler blocks the cookie.
+	CookieSet EventType = iota
+
+	// CookieUpdated is fired when a response changes the value of a cookie.
+	// Returning an error from a handler blocks the cookie.
+	CookieUpdated
+
+	// CookieExpired is fired when a response expires a cookie.
+	CookieExpired
+
+	// CookieBlocked is fired when a cookie is not stored, either because a
+	// handler returned an error, or because its domain does not match the
+	// host which set it.
+	CookieBlocked
+
+	// AssetDownloadStart is fired before an asset found by the browser is
+	// downloaded. Returning an error from a handler cancels the download.
+	AssetDownloadStart
+
+	// AssetDownloadComplete is fired after an asset has been downloaded.
+	AssetDownloadComplete
+
+	// AssetDownloadError is fired when downloading an asset fails.
+	AssetDownloadError
+
+	// BeforeNavigate is fired before the browser requests a page. Returning an
+	// error from a handler cancels the navigation, and the error is returned
+	// by the method which started it.
+	BeforeNavigate
+
+	// PageLoaded is fired after a page has been loaded and its document has
+	// been parsed.
+	PageLoaded
+
+	// Error is fired when an event handler panics. The panic is recovered,
+	// and Err holds an errors.HandlerPanic, which is also treated as the
+	// error returned by the handler.
+	Error
+
+	// BeforeResubmit is fired before Reload(), a meta refresh, or going back
+	// sends a request which is not GET or HEAD again, such as a submitted
+	// form. The request is only sent again when a handler is registered, and
+	// none return an error.
+	BeforeResubmit
+
+	// EarlyHints is fired when the server sends a 103 Early Hints response
+	// before the page, so the hinted assets may be fetched while waiting for
+	// it. Handlers are called while the request is in flight, and cannot
+	// cancel it.
+	EarlyHints
+
+	// ParseError is fired when a page cannot be read, decoded, or parsed the
+	// way its response declares, and the browser recovers from the problem,
+	// eg by keeping the raw body. Err holds the problem. Most problems are
+	// only recovered from when the LenientParsing attribute is set.
+	ParseError
+
+	// KeepAliveComplete is fired after a request sent by the keep-alive task.
+	KeepAliveComplete
+
+	// KeepAliveError is fired when a request sent by the keep-alive task
+	// fails, or is answered with a status of 400 or above.
+	KeepAliveError
+
+	// Challenge is fired when a page matches the signature of an anti-bot
+	// challenge, before the page is loaded. Returning an error from a handler
+	// cancels the navigation.
+	Challenge
+
+	// HistoryPushed is fired after the page which was current is pushed onto
+	// the history, because another page was loaded. State holds the page.
+	HistoryPushed
+
+	// HistoryPopped is fired after a page is popped from the history by going
+	// back. State holds the page.
+	HistoryPopped
+
+	// BookmarkSaved is fired after a bookmark is saved by Bookmark(), or by
+	// RestoreSession().
+	BookmarkSaved
+
+	// BookmarkRemoved is fired after a bookmark is removed by RemoveBookmark().
+	BookmarkRemoved
+)
+
+// NavigationTrigger describes what caused the browser to navigate.
+type NavigationTrigger int
+
+const (
+	// TriggerOpen is a page opened or posted to by one of the Open or Post methods.
+	TriggerOpen NavigationTrigger = iota
+
+	// TriggerClick is a link followed by Click().
+	TriggerClick
+
+	// TriggerSubmit is a submitted form.
+	TriggerSubmit
+
+	// TriggerRefresh is a page reloaded by Reload() or a meta refresh.
+	TriggerRefresh
+
+	// TriggerBack is a page requested again by going back in the history.
+	TriggerBack
+)
+
+// String returns the name of the trigger.
+func (t NavigationTrigger) String() string {
+	switch t {
+	case TriggerOpen:
+		return "open"
+	case TriggerClick:
+		return "click"
+	case TriggerSubmit:
+		return "submit"
+	case TriggerRefresh:
+		return "refresh"
+	case TriggerBack:
+		return "back"
+	}
+	return "unknown"
+}
+
+// Event is passed to each handler of an event.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+
+	// URL is the URL of the page or asset which caused the event.
+	URL *url.URL
+
+	// Cookie is the cookie for cookie events.
+	Cookie *http.Cookie
+
+	// Asset is the asset for asset download events.
+	Asset Assetable
+
+	// Size is the number of bytes downloaded for asset download events.
+	Size int64
+
+	// Duration is how long the download took for asset download events, and
+	// how long the request took for keep-alive events.
+	Duration time.Duration
+
+	// Err is the error which caused an error event.
+	Err error
+
+	// Trigger is what caused the navigation for navigation events.
+	Trigger NavigationTrigger
+
+	// Hints are the preload and prefetch links for EarlyHints events.
+	Hints []*ResourceHint
+
+	// Labels are the labels of the request for navigation and EarlyHints events.
+	Labels map[string]string
+
+	// Challenge is the name of the matched signature for Challenge events.
+	Challenge string
+
+	// State is the page pushed onto or popped from the history for history events.
+	State *jar.State
+
+	// Bookmark is the name of the bookmark for bookmark events.
+	Bookmark string
+}
+
+// EventHandler handles an event fired by the browser.
+//
+// Returning an error cancels the action which fired the event, when the event
+// may be cancelled, and stops the remaining handlers from being called.
+type EventHandler func(e *Event) error
+
+// eventHandler is a registered event handler.
+type eventHandler struct {
+	handle EventHandler
+	async  bool
+}
+
+// On registers a handler which is called each time an event of the given type
+// is fired. Handlers are called in the order they were registered.
+//
+// Asynchronous downloads fire events from their own goroutine, so handlers of
+// asset download events must be safe for concurrent use.
+func (bow *Browser) On(t EventType, h EventHandler) {
+	bow.addHandler(t, eventHandler{handle: h})
+}
+
+// OnAsync registers a handler which is called on its own goroutine each time
+// an event of the given type is fired, so slow handlers do not hold up the
+// browser.
+//
+// Asynchronous handlers cannot cancel the action which fired the event. The
+// errors they return are collected, and returned by Wait(). Handlers receive
+// a copy of the event.
+func (bow *Browser) OnAsync(t EventType, h EventHandler) {
+	bow.addHandler(t, eventHandler{handle: h, async: true})
+}
+
+// Wait blocks until every asynchronous handler has returned, and returns the
+// errors they returned since the last call to Wait().
+func (bow *Browser) Wait() []error {
+	bow.asyncHandlers.Wait()
+	bow.asyncMutex.Lock()
+	defer bow.asyncMutex.Unlock()
+	errs := bow.asyncErrors
+	bow.asyncErrors = nil
+	return errs
+}
+
+// addHandler registers the handler for the given event type.
+func (bow *Browser) addHandler(t EventType, h eventHandler) {
+	if bow.handlers == nil {
+		bow.handlers = make(map[EventType][]eventHandler)
+	}
+	bow.handlers[t] = append(bow.handlers[t], h)
+}
+
+// fire calls the handlers of the event, and returns the first error returned
+// by a synchronous handler. Asynchronous handlers are started, but not waited for.
+func (bow *Browser) fire(e *Event) error {
+	for _, h := range bow.handlers[e.Type] {
+		if h.async {
+			bow.fireAsync(h.handle, *e)
+			continue
+		}
+		if err := bow.callHandler(h.handle, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callHandler calls the handler, recovering from a panic and firing the Error
+// event in its place.
+func (bow *Browser) callHandler(h EventHandler, e *Event) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errors.NewHandlerPanic(v)
+			if e.Type != Error {
+				bow.fire(&Event{Type: Error, URL: e.URL, Err: err})
+			}
+		}
+	}()
+	return h(e)
+}
+
+// fireAsync calls the handler on a new goroutine, and collects its error.
+func (bow *Browser) fireAsync(h EventHandler, e Event) {
+	bow.asyncHandlers.Add(1)
+	go func() {
+		defer bow.asyncHandlers.Done()
+		if err := bow.callHandler(h, &e); err != nil {
+			bow.asyncMutex.Lock()
+			bow.asyncErrors = append(bow.asyncErrors, err)
+			bow.asyncMutex.Unlock()
+		}
+	}()
+}
+
+// trackDownload calls download, firing the download events of the asset when
+// it was found by a browser.
+func trackDownload(asset Assetable, download func() (int64, error)) (int64, error) {
+	bow := ownerOf(asset)
+	if bow == nil {
+		return download()
+	}
+
+	err := bow.fire(&Event{Type: AssetDownloadStart, URL: asset.Url(), Asset: asset})
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	size, err := download()
+	e := &Event{
+		Type:     AssetDownloadComplete,
+		URL:      asset.Url(),
+		Asset:    asset,
+		Size:     size,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		e.Type = AssetDownloadError
+		e.Err = err
+	}
+	bow.fire(e)
+	return size, err
+}
+
+// eventCookieJar wraps the browser cookie jar to fire cookie events.
+type eventCookieJar struct {
+	bow *Browser
+}
+
+// SetCookies fires the cookie events for each cookie, and stores the cookies
+// which are not blocked.
+func (j *eventCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	bow := j.bow
+	if bow.cookiesFrozen || bow.cookiePolicy(u) != CookiesAccept {
+		return
+	}
+	if len(bow.handlers) == 0 {
+		bow.cookies.SetCookies(u, cookies)
+		return
+	}
+
+	existing, key := j.stored(u)
+	accepted := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		e := &Event{URL: u, Cookie: c}
+		value, found := existing[key(c)]
+		switch {
+		case !cookieDomainMatch(u, c):
+			e.Type = CookieBlocked
+			bow.fire(e)
+			continue
+		case cookieExpired(c):
+			if found {
+				e.Type = CookieExpired
+				bow.fire(e)
+			}
+			accepted = append(accepted, c)
+			continue
+		case !found:
+			e.Type = CookieSet
+		case value != c.Value:
+			e.Type = CookieUpdated
+		default:
+			accepted = append(accepted, c)
+			continue
+		}
+		if bow.fire(e) != nil {
+			e.Type = CookieBlocked
+			bow.fire(e)
+			continue
+		}
+		accepted = append(accepted, c)
+	}
+	bow.cookies.SetCookies(u, accepted)
+}
+
+// stored returns the values of the stored cookies, and the function returning
+// the key a cookie set by a response from the URL replaces. Cookies are told
+// apart by their domain, path, and name when the jar exports them, and by
+// their name among the cookies sent to the URL otherwise.
+func (j *eventCookieJar) stored(u *url.URL) (map[string]string, func(c *http.Cookie) string) {
+	existing := make(map[string]string)
+	if exporter, ok := j.bow.cookies.(jar.CookieExporter); ok {
+		for _, e := range exporter.Export() {
+			if eu, err := url.Parse(e.URL); err == nil {
+				existing[jar.CookieKey(eu, e.Cookie)] = e.Cookie.Value
+			}
+		}
+		return existing, func(c *http.Cookie) string { return jar.CookieKey(u, c) }
+	}
+	for _, c := range j.bow.cookies.Cookies(u) {
+		existing[c.Name] = c.Value
+	}
+	return existing, func(c *http.Cookie) string { return c.Name }
+}
+
+// Cookies returns the cookies to send in a request for the given URL.
+func (j *eventCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if _, ok := j.bow.pinnedCookie(u); ok {
+		return nil
+	}
+	if j.bow.cookiePolicy(u) == CookiesBlock {
+		return nil
+	}
+	return j.bow.cookies.Cookies(u)
+}
+
+// cookieExpired returns whether the cookie deletes a stored cookie.
+func cookieExpired(c *http.Cookie) bool {
+	if c.MaxAge < 0 {
+		return true
+	}
+	return c.MaxAge == 0 && !c.Expires.IsZero() && c.Expires.Before(time.Now())
+}
+
+// cookieDomainMatch returns whether the cookie may be set by the given URL.
+func cookieDomainMatch(u *url.URL, c *http.Cookie) bool {
+	if c.Domain == "" {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,70 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fetchSites are the values of the Sec-Fetch-Site header, from the closest
+// to the furthest relation between the page and the request.
+var fetchSites = []string{"same-origin", "same-site", "cross-site"}
+
+// setFetchMetadata sets the Sec-Fetch-Site header of a navigation from what
+// started it, when the emulated browser sends it. Pages opened directly are
+// "none", and the other pages are compared with the current page. The
+// Sec-Fetch-User header is sent with the navigations started by the user,
+// which are the ones not caused by a reload or by going back.
+func (bow *Browser) setFetchMetadata(req *http.Request, trigger NavigationTrigger) {
+	if !bow.fetchSite {
+		return
+	}
+	site := "none"
+	if trigger != TriggerOpen {
+		site = fetchSite(bow.Url(), req.URL)
+	}
+	req.Header.Set("Sec-Fetch-Site", site)
+	req.Header.Del("Sec-Fetch-User")
+	if bow.fetchUser && trigger != TriggerRefresh && trigger != TriggerBack {
+		req.Header.Set("Sec-Fetch-User", "?1")
+	}
+}
+
+// redirectFetchMetadata sets the Sec-Fetch-Site header of a redirected
+// navigation to the furthest relation between the current page and the
+// requests of the redirect chain. Navigations opened directly stay "none".
+func (bow *Browser) redirectFetchMetadata(req *http.Request, via []*http.Request) {
+	site := via[0].Header.Get("Sec-Fetch-Site")
+	if site == "" || site == "none" {
+		return
+	}
+	from := bow.Url()
+	for _, r := range via[1:] {
+		site = furthestSite(site, fetchSite(from, r.URL))
+	}
+	req.Header.Set("Sec-Fetch-Site", furthestSite(site, fetchSite(from, req.URL)))
+}
+
+// fetchSite returns the Sec-Fetch-Site value of a request to u sent from the
+// page at from.
+func fetchSite(from, u *url.URL) string {
+	switch {
+	case from == nil || from.Scheme != u.Scheme:
+		return "cross-site"
+	case strings.EqualFold(originAddr(from.Scheme, from.Host), originAddr(u.Scheme, u.Host)):
+		return "same-origin"
+	case registrableDomain(from.Host) == registrableDomain(u.Host):
+		return "same-site"
+	}
+	return "cross-site"
+}
+
+// furthestSite returns the furthest of two Sec-Fetch-Site values.
+func furthestSite(a, b string) string {
+	for i := len(fetchSites) - 1; i >= 0; i-- {
+		if a == fetchSites[i] || b == fetchSites[i] {
+			return fetchSites[i]
+		}
+	}
+	return a
+}
@@ -0,0 +1,110 @@
+package browser
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// MaxLinkCandidates is the number of near-miss links reported when no link
+// matches.
+var MaxLinkCandidates = 5
+
+// LinkMatcher selects the links followed by FollowLink().
+type LinkMatcher interface {
+	// MatchLink returns whether the link is selected.
+	MatchLink(link *Link) bool
+}
+
+// LinkFunc is a predicate over links which implements LinkMatcher.
+type LinkFunc func(link *Link) bool
+
+// MatchLink returns fn(link).
+func (fn LinkFunc) MatchLink(link *Link) bool {
+	return fn(link)
+}
+
+// HrefMatches returns a LinkMatcher which selects the links whose absolute
+// URL matches the regular expression.
+func HrefMatches(re *regexp.Regexp) LinkMatcher {
+	return hrefMatcher{re: re}
+}
+
+// hrefMatcher matches the URL of a link against a regular expression.
+type hrefMatcher struct {
+	re *regexp.Regexp
+}
+
+// MatchLink returns whether the URL of the link matches.
+func (m hrefMatcher) MatchLink(link *Link) bool {
+	return m.re.MatchString(link.URL.String())
+}
+
+// String returns the regular expression.
+func (m hrefMatcher) String() string {
+	return m.re.String()
+}
+
+// FollowLink follows the first link in the page selected by the matcher.
+//
+// When no link matches, an errors.NoMatchingLink is returned, listing the
+// links which came closest to matching an href pattern, or the first links in
+// the page for other matchers.
+func (bow *Browser) FollowLink(m LinkMatcher) error {
+	links := bow.Links()
+	for _, link := range links {
+		if m.MatchLink(link) {
+			return bow.httpGET(link.URL, bow.Url(), TriggerClick)
+		}
+	}
+
+	desc := "the predicate"
+	if hm, ok := m.(hrefMatcher); ok {
+		desc = "'" + hm.String() + "'"
+		sort.SliceStable(links, func(i, j int) bool {
+			return distance(hm.String(), links[i].URL.String()) <
+				distance(hm.String(), links[j].URL.String())
+		})
+	}
+	candidates := make([]string, 0, MaxLinkCandidates)
+	for _, link := range links {
+		if len(candidates) == MaxLinkCandidates {
+			break
+		}
+		candidates = append(candidates, link.URL.String())
+	}
+	return errors.NewNoMatchingLink(desc, candidates)
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// min3 returns the smallest of the three ints.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
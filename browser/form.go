@@ -1,8 +1,13 @@
 package browser
 
 import (
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/haruyama/surf/errors"
@@ -14,12 +19,24 @@ type Submittable interface {
 	Action() string
 	SetAction(string)
 	Field(name string) (string, bool)
+	Options(name string) ([]SelectOption, error)
 	Input(name, value string) error
 	DeleteField(name string) error
 	InputSlice(name string, values []string) error
 	CheckBox(name string, values []string) error
+	SetNumber(name string, n float64) error
+	SetDate(name string, t time.Time) error
+	SetBool(name string, checked bool) error
+	File(name, fileName string, r io.Reader) error
+	AddFile(name, fileName string, r io.Reader) error
 	Click(button string) error
+	ClickButtonByText(text string) error
+	ClickButtonByPartialText(text string) error
 	Submit() error
+	SubmitPreview() (*Page, error)
+	Validate() error
+	Reset()
+	Clone() Submittable
 	Dom() *goquery.Selection
 }
 
@@ -29,24 +46,38 @@ type Form struct {
 	selection     *goquery.Selection
 	method        string
 	action        string
+	defaultAction string
 	definedFields map[string]bool
 	fields        url.Values
+	defaults      url.Values
 	buttons       url.Values
+	fileFields    map[string]bool
+	files         multipartFiles
 }
 
 // NewForm creates and returns a *Form type.
 func NewForm(bow Browsable, s *goquery.Selection) *Form {
-	definedFields, fields, buttons := serializeForm(s)
-	method, action := formAttributes(bow, s)
+	return newForm(bow, bow, s)
+}
+
+// newForm creates and returns a *Form type, whose action is resolved against
+// the URL of the given page.
+func newForm(bow Browsable, page resolver, s *goquery.Selection) *Form {
+	definedFields, fields, buttons, fileFields := serializeForm(s)
+	method, action := formAttributes(page, s)
 
 	return &Form{
 		bow:           bow,
 		selection:     s,
 		method:        method,
 		action:        action,
+		defaultAction: action,
 		definedFields: definedFields,
 		fields:        fields,
+		defaults:      copyValues(fields),
 		buttons:       buttons,
+		fileFields:    fileFields,
+		files:         make(multipartFiles),
 	}
 }
 
@@ -76,6 +107,54 @@ func (f *Form) Field(name string) (string, bool) {
 	}
 }
 
+// SelectOption is an option of a select field.
+type SelectOption struct {
+	// Value is the value submitted when the option is selected. Options
+	// without a value attribute submit their text.
+	Value string
+
+	// Label is the text shown for the option.
+	Label string
+
+	// Selected is whether the option is currently selected.
+	Selected bool
+
+	// Disabled is whether the option, or its group, is disabled.
+	Disabled bool
+}
+
+// Options returns the options of the select field with the given name, in
+// document order, which allows enumerating the valid choices of a dropdown.
+func (f *Form) Options(name string) ([]SelectOption, error) {
+	sel := f.selection.Find("select").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.AttrOr("name", "") == name
+	}).First()
+	if sel.Length() == 0 {
+		return nil, errors.NewElementNotFound(
+			"No select found with name '%s'.", name)
+	}
+
+	selected := map[string]bool{}
+	for _, v := range f.fields[name] {
+		selected[v] = true
+	}
+	var options []SelectOption
+	sel.Find("option").Each(func(_ int, s *goquery.Selection) {
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		o := SelectOption{
+			Value: s.AttrOr("value", text),
+			Label: s.AttrOr("label", text),
+		}
+		o.Selected = selected[o.Value]
+		_, o.Disabled = s.Attr("disabled")
+		if !o.Disabled {
+			_, o.Disabled = s.ParentsFiltered("optgroup").Attr("disabled")
+		}
+		options = append(options, o)
+	})
+	return options, nil
+}
+
 // Input sets the value of a form field.
 func (f *Form) Input(name, value string) error {
 	if f.definedFields[name] {
@@ -114,6 +193,102 @@ func (f *Form) CheckBox(name string, values []string) error {
 	return f.InputSlice(name, values)
 }
 
+// SetNumber sets the value of a number field, formatted the way browsers
+// submit numbers, eg "1.5" or "42", without an exponent.
+func (f *Form) SetNumber(name string, n float64) error {
+	return f.Input(name, strconv.FormatFloat(n, 'f', -1, 64))
+}
+
+// SetDate sets the value of a date field, formatted for the type of its
+// input the way browsers submit dates:
+//
+//	date            2006-01-02
+//	datetime-local  2006-01-02T15:04, with seconds when they're not zero
+//	time            15:04, with seconds when they're not zero
+//	month           2006-01
+//	week            2006-W01
+//
+// Inputs of any other type are set using the date format. The time is
+// formatted in its own location.
+func (f *Form) SetDate(name string, t time.Time) error {
+	clock := "15:04"
+	if t.Second() != 0 {
+		clock = "15:04:05"
+	}
+	var value string
+	switch strings.ToLower(f.input(name).AttrOr("type", "")) {
+	case "datetime-local":
+		value = t.Format("2006-01-02T" + clock)
+	case "time":
+		value = t.Format(clock)
+	case "month":
+		value = t.Format("2006-01")
+	case "week":
+		year, week := t.ISOWeek()
+		value = fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		value = t.Format("2006-01-02")
+	}
+	return f.Input(name, value)
+}
+
+// SetBool checks or unchecks a single checkbox. A checked checkbox submits
+// its value, or "on" when it has no value, and an unchecked checkbox is not
+// submitted at all.
+func (f *Form) SetBool(name string, checked bool) error {
+	if !f.definedFields[name] {
+		return errors.NewElementNotFound(
+			"No input found with name '%s'.", name)
+	}
+	if !checked {
+		f.fields.Del(name)
+		return nil
+	}
+	f.fields.Set(name, f.input(name).AttrOr("value", "on"))
+	return nil
+}
+
+// input returns the first input element with the given name.
+func (f *Form) input(name string) *goquery.Selection {
+	return f.selection.Find("input").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.AttrOr("name", "") == name
+	}).First()
+}
+
+// File attaches a file to the file input with the given name.
+//
+// The contents are read from r while the form is being submitted, which allows
+// uploading large files without buffering them in memory. When r is also an
+// io.Closer it's closed after being sent. Files are only sent when the form
+// uses the multipart/form-data enctype.
+func (f *Form) File(name, fileName string, r io.Reader) error {
+	if f.fileFields[name] {
+		f.files[name] = []multipartFile{{fileName: fileName, reader: r}}
+		return nil
+	}
+	return errors.NewElementNotFound(
+		"No file input found with name '%s'.", name)
+}
+
+// AddFile attaches another file to the file input with the given name, which
+// must have the multiple attribute. The files are sent in the order they
+// were attached.
+//
+// Returns an error when the input already has a file and does not accept
+// multiple files.
+func (f *Form) AddFile(name, fileName string, r io.Reader) error {
+	if !f.fileFields[name] {
+		return errors.NewElementNotFound(
+			"No file input found with name '%s'.", name)
+	}
+	if _, multiple := f.input(name).Attr("multiple"); !multiple && len(f.files[name]) > 0 {
+		return errors.NewInvalidFormValue(
+			"File input '%s' does not accept multiple files.", name)
+	}
+	f.files[name] = append(f.files[name], multipartFile{fileName: fileName, reader: r})
+	return nil
+}
+
 // Submit submits the form.
 // Clicks the first button in the form, or submits the form without using
 // any button when the form does not contain any buttons.
@@ -126,6 +301,33 @@ func (f *Form) Submit() error {
 	return f.send("", "")
 }
 
+// SubmitPreview submits the form like Submit(), and returns the resulting page
+// without replacing the current page of the browser or adding to its history.
+func (f *Form) SubmitPreview() (*Page, error) {
+	name, value := "", ""
+	for n := range f.buttons {
+		name, value = n, f.buttons[n][0]
+		break
+	}
+	sub, err := f.submission(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	bow, ok := f.bow.(*Browser)
+	if !ok {
+		if sub.method == "GET" {
+			return f.bow.Peek(sub.url.String())
+		}
+		return f.bow.PeekPost(sub.url.String(), sub.contentType, sub.body)
+	}
+	req, err := bow.submissionRequest(sub)
+	if err != nil {
+		return nil, err
+	}
+	return bow.peek(req)
+}
+
 // Click submits the form by clicking the button with the given name.
 func (f *Form) Click(button string) error {
 	if _, ok := f.buttons[button]; !ok {
@@ -140,8 +342,121 @@ func (f *Form) Dom() *goquery.Selection {
 	return f.selection
 }
 
+// Reset restores the values the fields had when the form was parsed, detaches
+// every file, and restores the action.
+func (f *Form) Reset() {
+	f.fields = copyValues(f.defaults)
+	f.files = make(multipartFiles)
+	f.action = f.defaultAction
+}
+
+// Clone returns a copy of the form, including its current values and files,
+// which may be changed and submitted without affecting the original.
+//
+// The copy submits through the same browser. Use NewForm() with the Dom() of
+// the form to parse a fresh copy for another browser.
+func (f *Form) Clone() Submittable {
+	files := make(multipartFiles, len(f.files))
+	for name, fs := range f.files {
+		files[name] = append([]multipartFile(nil), fs...)
+	}
+	c := *f
+	c.fields = copyValues(f.fields)
+	c.files = files
+	return &c
+}
+
+// copyValues returns a deep copy of the values.
+func copyValues(values url.Values) url.Values {
+	c := make(url.Values, len(values))
+	for name, vs := range values {
+		c[name] = append([]string(nil), vs...)
+	}
+	return c
+}
+
+// MethodOverride selects how forms with a _method field are submitted.
+type MethodOverride int
+
+const (
+	// OverrideNone submits the _method field like any other field.
+	OverrideNone MethodOverride = iota
+
+	// OverrideHeader submits the form using POST with the
+	// X-HTTP-Method-Override header set to the value of the _method field.
+	OverrideHeader
+
+	// OverrideMethod submits the form using the value of the _method field
+	// as the request method.
+	OverrideMethod
+)
+
+// SetMethodOverride sets how forms with a _method field, such as the PUT and
+// DELETE forms of Rails and Laravel, are submitted. The default,
+// OverrideNone, submits them using POST.
+func (bow *Browser) SetMethodOverride(m MethodOverride) {
+	bow.methodOverride = m
+}
+
+// submission is a form submission ready to be sent.
+type submission struct {
+	method      string
+	override    string
+	url         *url.URL
+	contentType string
+	body        io.Reader
+}
+
+// submissionRequest creates and returns the request sending the submission.
+func (bow *Browser) submissionRequest(sub *submission) (*http.Request, error) {
+	if sub.method == "GET" {
+		return bow.buildRequest("GET", sub.url.String(), nil, nil)
+	}
+	req, err := bow.buildPOST(sub.url, nil, sub.contentType, sub.body)
+	if err != nil {
+		return nil, err
+	}
+	if sub.override != "" {
+		switch bow.methodOverride {
+		case OverrideHeader:
+			req.Header.Set("X-HTTP-Method-Override", sub.override)
+		case OverrideMethod:
+			req.Method = sub.override
+		}
+	}
+	return req, nil
+}
+
 // send submits the form.
 func (f *Form) send(buttonName, buttonValue string) error {
+	sub, err := f.submission(buttonName, buttonValue)
+	if err != nil {
+		return err
+	}
+
+	bow, ok := f.bow.(*Browser)
+	if !ok {
+		if sub.method == "GET" {
+			return f.bow.Open(sub.url.String())
+		}
+		return f.bow.Post(sub.url.String(), sub.contentType, sub.body)
+	}
+	req, err := bow.submissionRequest(sub)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req, TriggerSubmit)
+}
+
+// submission returns the submission of the form using the given button.
+// The form is validated first when the browser has the ValidateForms
+// attribute set.
+func (f *Form) submission(buttonName, buttonValue string) (*submission, error) {
+	if f.shouldValidate(buttonName) {
+		if err := f.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	method, ok := f.selection.Attr("method")
 	if !ok {
 		method = "GET"
@@ -155,7 +470,7 @@ func (f *Form) send(buttonName, buttonValue string) error {
 	}
 	aurl, err := url.Parse(action)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	aurl = f.bow.ResolveUrl(aurl)
 
@@ -168,26 +483,34 @@ func (f *Form) send(buttonName, buttonValue string) error {
 	}
 
 	if strings.ToUpper(method) == "GET" {
-		return f.bow.OpenForm(aurl.String(), values)
+		aurl.RawQuery = values.Encode()
+		return &submission{method: "GET", url: aurl}, nil
+	}
+	sub := &submission{
+		method:   "POST",
+		override: strings.ToUpper(values.Get("_method")),
+		url:      aurl,
+	}
+	enctype, _ := f.selection.Attr("enctype")
+	if enctype == "multipart/form-data" {
+		sub.contentType, sub.body = newMultipartReader(values, f.files)
 	} else {
-		enctype, _ := f.selection.Attr("enctype")
-		if enctype == "multipart/form-data" {
-			return f.bow.PostMultipart(aurl.String(), values)
-		}
-		return f.bow.PostForm(aurl.String(), values)
+		sub.contentType = "application/x-www-form-urlencoded"
+		sub.body = strings.NewReader(values.Encode())
 	}
-
-	return nil
+	return sub, nil
 }
 
 // Serialize converts the form fields into a url.Values type.
-// Returns two url.Value types. The first is the form field values, and the
-// second is the form button values.
-func serializeForm(sel *goquery.Selection) (map[string]bool, url.Values, url.Values) {
+// Returns the defined field names, two url.Value types, and the file field
+// names. The first url.Values is the form field values, and the second is the
+// form button values.
+func serializeForm(sel *goquery.Selection) (map[string]bool, url.Values, url.Values, map[string]bool) {
 	input := sel.Find("input,button")
 	definedFields := map[string]bool{}
 	fields := make(url.Values)
 	buttons := make(url.Values)
+	fileFields := map[string]bool{}
 
 	input.Each(func(_ int, s *goquery.Selection) {
 		name, ok := s.Attr("name")
@@ -210,6 +533,8 @@ func serializeForm(sel *goquery.Selection) (map[string]bool, url.Values, url.Val
 							fields.Add(name, val)
 						}
 					}
+				} else if typ == "file" {
+					fileFields[name] = true
 				} else {
 					definedFields[name] = true
 					val, ok := s.Attr("value")
@@ -247,23 +572,32 @@ func serializeForm(sel *goquery.Selection) (map[string]bool, url.Values, url.Val
 		fields.Add(name, s.Text())
 	})
 
-	return definedFields, fields, buttons
+	return definedFields, fields, buttons, fileFields
+}
+
+// resolver resolves the relative URLs of a page.
+type resolver interface {
+	// Url returns the page URL.
+	Url() *url.URL
+
+	// ResolveUrl returns an absolute URL for a possibly relative URL.
+	ResolveUrl(u *url.URL) *url.URL
 }
 
-func formAttributes(bow Browsable, s *goquery.Selection) (string, string) {
+func formAttributes(page resolver, s *goquery.Selection) (string, string) {
 	method, ok := s.Attr("method")
 	if !ok {
 		method = "GET"
 	}
 	action, ok := s.Attr("action")
 	if !ok {
-		action = bow.Url().String()
+		action = page.Url().String()
 	}
 	aurl, err := url.Parse(action)
 	if err != nil {
 		return "", ""
 	}
-	aurl = bow.ResolveUrl(aurl)
+	aurl = page.ResolveUrl(aurl)
 
 	return strings.ToUpper(method), aurl.String()
 }
@@ -2,11 +2,15 @@ package browser
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/haruyama/surf/errors"
 	"github.com/haruyama/surf/jar"
 	"github.com/headzoo/ut"
 )
@@ -55,6 +59,377 @@ func TestBrowserForm(t *testing.T) {
 	ut.AssertContains("submit2=submitted2", bow.Body())
 }
 
+func TestBrowserFormSubmitPreview(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlForm)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.Form.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	f, err := bow.Form("[name='default']")
+	ut.AssertNil(err)
+	f.Input("age", "55")
+	page, err := f.SubmitPreview()
+	ut.AssertNil(err)
+	ut.AssertEquals(200, page.StatusCode())
+	ut.AssertContains("age=55", page.Body())
+	ut.AssertEquals("Echo Form", bow.Title())
+	ut.AssertEquals(1, bow.history.Len())
+
+	page, err = bow.Peek(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Echo Form", page.Title())
+	ut.AssertEquals(1, page.Find("form").Length())
+	ut.AssertEquals(1, bow.history.Len())
+}
+
+func TestBrowserFormMethodOverride(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormMethodOverride)
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			values, _ := url.ParseQuery(string(body))
+			fmt.Fprint(w, r.Method+" "+r.Header.Get("X-HTTP-Method-Override")+" "+values.Get("_method"))
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	for _, tc := range []struct {
+		override MethodOverride
+		body     string
+	}{
+		{OverrideNone, "POST  delete"},
+		{OverrideHeader, "POST DELETE delete"},
+		{OverrideMethod, "DELETE  delete"},
+	} {
+		bow.SetMethodOverride(tc.override)
+		ut.AssertNil(bow.Open(ts.URL))
+		f, err := bow.Form("form")
+		ut.AssertNil(err)
+		ut.AssertNil(f.Submit())
+		ut.AssertEquals(tc.body, bow.Body())
+	}
+}
+
+func TestBrowserFormResetClone(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlForm)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.URL.Path+"?"+r.PostForm.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("[name='default']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Input("age", "55"))
+
+	c := f.Clone()
+	ut.AssertNil(c.Input("age", "66"))
+	ut.AssertNil(c.Input("gender", "female"))
+	v, _ := f.Field("age")
+	ut.AssertEquals("55", v)
+	v, _ = c.Field("age")
+	ut.AssertEquals("66", v)
+
+	f.SetAction(ts.URL + "/elsewhere")
+	f.Reset()
+	v, _ = f.Field("age")
+	ut.AssertEquals("", v)
+	ut.AssertEquals(ts.URL+"/", f.Action())
+	v, _ = f.Field("gender")
+	ut.AssertEquals("", v)
+
+	ut.AssertNil(c.Click("submit2"))
+	ut.AssertContains("age=66", bow.Body())
+	ut.AssertContains("gender=female", bow.Body())
+}
+
+func TestBrowserClickByText(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			r.ParseForm()
+			fmt.Fprint(w, r.URL.Path+"?"+r.PostForm.Encode())
+		} else if r.URL.Path == "/login" {
+			fmt.Fprint(w, "logged in")
+		} else {
+			fmt.Fprint(w, htmlFormText)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.ClickByText("Log in"))
+	ut.AssertEquals("logged in", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNotNil(bow.ClickByText("Log"))
+	ut.AssertNil(bow.ClickByPartialText("Sign"))
+	ut.AssertContains("/text?go=signup", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Input("q", "surf"))
+	ut.AssertNil(f.ClickButtonByText("Search"))
+	ut.AssertContains("go=Search", bow.Body())
+	ut.AssertContains("q=surf", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err = bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(f.ClickButtonByPartialText("Lucky"))
+	ut.AssertContains("go=lucky", bow.Body())
+	ut.AssertNotNil(f.ClickButtonByText("Missing"))
+}
+
+func TestBrowserFindByText(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlFormText)
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	sel := bow.FindByText("a", "Log in")
+	ut.AssertEquals(1, sel.Length())
+	ut.AssertEquals("/login", sel.AttrOr("href", ""))
+	ut.AssertEquals(0, bow.FindByText("a", "Log").Length())
+	ut.AssertEquals(1, bow.FindByText("button", "I'm Feeling Lucky").Length())
+
+	sel = bow.FindByAttr("button", "value", "signup")
+	ut.AssertEquals(1, sel.Length())
+	ut.AssertEquals("Sign up", sel.Text())
+	ut.AssertEquals(3, bow.FindByAttr("input,button", "name", "go").Length())
+	ut.AssertEquals(0, bow.FindByAttr("button", "type", "reset").Length())
+}
+
+var htmlFormText = `<!doctype html>
+<html>
+	<head>
+		<title>Text Form</title>
+	</head>
+	<body>
+		<a href="/login">
+			Log   in
+		</a>
+		<form method="post" action="/text">
+			<input type="text" name="q" value="" />
+			<input type="submit" name="go" value="Search" />
+			<button name="go" value="lucky">I'm Feeling <b>Lucky</b></button>
+			<button type="submit" name="go" value="signup">Sign up</button>
+		</form>
+	</body>
+</html>
+`
+
+func TestBrowserFormOptions(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlForm2)
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("[name='default']")
+	ut.AssertNil(err)
+
+	options, err := f.Options("country")
+	ut.AssertNil(err)
+	ut.AssertEquals([]SelectOption{
+		{Value: "us", Label: "United States", Selected: true},
+		{Value: "jp", Label: "Japan"},
+		{Value: "Prussia", Label: "Prussia", Disabled: true},
+	}, options)
+
+	ut.AssertNil(f.Input("country", "jp"))
+	options, _ = f.Options("country")
+	ut.AssertFalse(options[0].Selected)
+	ut.AssertTrue(options[1].Selected)
+
+	_, err = f.Options("age")
+	ut.AssertNotNil(err)
+}
+
+func TestBrowserFormTypedSetters(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormTyped)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.PostForm.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	when := time.Date(2021, 1, 3, 9, 5, 0, 0, time.UTC)
+	ut.AssertNil(f.SetNumber("qty", 1e6))
+	ut.AssertNil(f.SetNumber("price", 1.5))
+	ut.AssertNil(f.SetDate("day", when))
+	ut.AssertNil(f.SetDate("at", when))
+	ut.AssertNil(f.SetDate("clock", when.Add(7*time.Second)))
+	ut.AssertNil(f.SetDate("month", when))
+	ut.AssertNil(f.SetDate("week", when))
+	ut.AssertNil(f.SetBool("agree", true))
+	ut.AssertNil(f.SetBool("news", false))
+	ut.AssertNotNil(f.SetBool("missing", true))
+	ut.AssertNil(f.Submit())
+	ut.AssertEquals(
+		"agree=on&at=2021-01-03T09%3A05&clock=09%3A05%3A07&day=2021-01-03&month=2021-01&price=1.5&qty=1000000&week=2020-W53",
+		bow.Body())
+}
+
+var htmlFormTyped = `<!doctype html>
+<html>
+	<head>
+		<title>Echo Typed</title>
+	</head>
+	<body>
+		<form method="post" action="/">
+			<input type="number" name="qty" />
+			<input type="number" name="price" step="0.01" />
+			<input type="date" name="day" />
+			<input type="datetime-local" name="at" />
+			<input type="time" name="clock" />
+			<input type="month" name="month" />
+			<input type="week" name="week" />
+			<input type="checkbox" name="agree" />
+			<input type="checkbox" name="news" value="weekly" checked />
+		</form>
+	</body>
+</html>
+`
+
+func TestBrowserFormValidate(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormValidate)
+		} else {
+			fmt.Fprint(w, "submitted")
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+	bow.attributes = AttributeMap{ValidateForms: true}
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Input("code", "abc"))
+	ut.AssertNil(f.Input("email", "joe@"))
+	ut.AssertNil(f.Input("age", "150"))
+	ut.AssertNil(f.Input("site", "example.com"))
+
+	err = f.Click("save")
+	verr, ok := err.(errors.Validation)
+	ut.AssertTrue(ok)
+	fields := make([]string, len(verr.Fields))
+	for i, v := range verr.Fields {
+		fields[i] = v.Field + ":" + v.Constraint
+	}
+	ut.AssertEquals(
+		[]string{"name:required", "code:pattern", "email:type", "age:max", "site:type", "terms:required"},
+		fields)
+	ut.AssertEquals("Echo Validate", bow.Title())
+
+	ut.AssertNil(f.Click("draft"))
+	ut.AssertEquals("submitted", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, _ = bow.Form("form")
+	ut.AssertNil(f.Input("name", "Joe"))
+	ut.AssertNil(f.Input("code", "AB12"))
+	ut.AssertNil(f.Input("email", "joe@example.com"))
+	ut.AssertNil(f.Input("age", "42"))
+	ut.AssertNil(f.Input("site", "https://example.com"))
+	ut.AssertNil(f.CheckBox("terms", []string{"yes"}))
+	ut.AssertNil(f.Validate())
+	ut.AssertNil(f.Click("save"))
+	ut.AssertEquals("submitted", bow.Body())
+}
+
+var htmlFormValidate = `<!doctype html>
+<html>
+	<head>
+		<title>Echo Validate</title>
+	</head>
+	<body>
+		<form method="post" action="/">
+			<input type="text" name="name" required />
+			<input type="text" name="code" pattern="[A-Z]{2}[0-9]+" />
+			<input type="email" name="email" />
+			<input type="number" name="age" min="0" max="120" />
+			<input type="url" name="site" />
+			<input type="text" name="nickname" disabled required />
+			<input type="checkbox" name="terms" value="yes" required />
+			<input type="submit" name="save" value="Save" />
+			<input type="submit" name="draft" value="Draft" formnovalidate />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormMethodOverride = `<!doctype html>
+<html>
+	<body>
+		<form method="post" action="/">
+			<input type="hidden" name="_method" value="delete" />
+		</form>
+	</body>
+</html>
+`
+
 var htmlForm = `<!doctype html>
 <html>
 	<head>
@@ -140,9 +515,103 @@ var htmlForm2 = `<!doctype html>
 				<option value="NY" selected>
 				<option value="Tokyo">
 			</select>
+			<select name="country">
+				<option value="us" selected>United  States</option>
+				<option value="jp" label="Japan">Nihon</option>
+				<optgroup label="Former" disabled>
+					<option>Prussia</option>
+				</optgroup>
+			</select>
 			<textarea name="hobby">Dance</textarea>
 			<input type="submit" name="submit2" value="submitted2">
 		</form>
 	</body>
 </html>
 `
+
+func TestBrowserFormFile(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormFile)
+		} else {
+			file, header, err := r.FormFile("upload")
+			if err != nil {
+				fmt.Fprint(w, err)
+				return
+			}
+			defer file.Close()
+			contents, _ := ioutil.ReadAll(file)
+			fmt.Fprintf(w, "name=%s file=%s contents=%s", r.FormValue("name"), header.Filename, contents)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	f, err := bow.Form("[name='upload']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Input("name", "surf"))
+	ut.AssertNotNil(f.File("name", "a.txt", strings.NewReader("")))
+	ut.AssertNil(f.File("upload", "a.txt", strings.NewReader("file contents")))
+
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertContains("name=surf", bow.Body())
+	ut.AssertContains("file=a.txt", bow.Body())
+	ut.AssertContains("contents=file contents", bow.Body())
+}
+
+func TestBrowserFormMultipleFiles(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormFile)
+			return
+		}
+		r.ParseMultipartForm(1024)
+		for _, header := range r.MultipartForm.File["photos"] {
+			file, _ := header.Open()
+			contents, _ := ioutil.ReadAll(file)
+			file.Close()
+			fmt.Fprintf(w, "%s=%s;", header.Filename, contents)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err := bow.Form("[name='upload']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.AddFile("upload", "a.txt", strings.NewReader("a")))
+	ut.AssertNotNil(f.AddFile("upload", "b.txt", strings.NewReader("b")))
+	for _, name := range []string{"c", "a", "b"} {
+		ut.AssertNil(f.AddFile("photos", name+".jpg", strings.NewReader(name)))
+	}
+
+	ut.AssertNil(f.Submit())
+	ut.AssertEquals("c.jpg=c;a.jpg=a;b.jpg=b;", bow.Body())
+}
+
+var htmlFormFile = `<!doctype html>
+<html>
+	<head>
+		<title>Upload Form</title>
+	</head>
+	<body>
+		<form method="post" action="/" name="upload" enctype="multipart/form-data">
+			<input type="text" name="name" value="" />
+			<input type="file" name="upload" />
+			<input type="file" name="photos" multiple />
+		</form>
+	</body>
+</html>
+`
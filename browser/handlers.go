@@ -0,0 +1,110 @@
+package browser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ContentHandler handles a page loaded by the browser. Returning an error
+// makes the method which loaded the page return it, although the page stays
+// loaded.
+type ContentHandler func(p *Page) error
+
+// HandleContentType registers the handler called each time the browser loads
+// a page of the given media type, so mixed-content crawls may route each
+// response by its type.
+//
+// The media type may be exact, eg "application/json", a wildcard subtype, eg
+// "image/*", or "*/*", which handles the pages of the types without a
+// handler. The most specific handler is called. Pages without a Content-Type
+// header are handled as "text/html". Passing a nil handler removes it.
+func (bow *Browser) HandleContentType(mediaType string, h ContentHandler) {
+	mediaType = strings.ToLower(mediaType)
+	if h == nil {
+		delete(bow.contentHandlers, mediaType)
+		return
+	}
+	if bow.contentHandlers == nil {
+		bow.contentHandlers = make(map[string]ContentHandler)
+	}
+	bow.contentHandlers[mediaType] = h
+}
+
+// handleContent calls the content handler of the page, if it has one.
+func (bow *Browser) handleContent(p *Page) error {
+	if len(bow.contentHandlers) == 0 {
+		return nil
+	}
+	mt := "text/html"
+	if ct := p.ResponseHeaders().Get("Content-Type"); ct != "" {
+		if parsed, _, err := mime.ParseMediaType(ct); err == nil {
+			mt = strings.ToLower(parsed)
+		}
+	}
+	candidates := []string{mt, "*/*"}
+	if i := strings.IndexByte(mt, '/'); i > 0 {
+		candidates = []string{mt, mt[:i] + "/*", "*/*"}
+	}
+	for _, c := range candidates {
+		if h, ok := bow.contentHandlers[c]; ok {
+			return h(p)
+		}
+	}
+	return nil
+}
+
+// DecodeJSON decodes the body of the page as JSON into the value pointed to by v.
+func (p *Page) DecodeJSON(v interface{}) error {
+	r, err := p.state.BodyReader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(v)
+}
+
+// SaveToDir returns a ContentHandler which saves the body of each page to
+// a directory named after the host of the page within the directory, naming
+// the file after the last segment of the URL path, or "index" when the path is
+// empty. Separators and other characters which are not allowed in file
+// names are replaced by underscores, so pages are never written outside of
+// the directory. Existing files are replaced.
+func SaveToDir(dir string) ContentHandler {
+	return func(p *Page) error {
+		name := path.Base(p.Url().Path)
+		if name == "/" {
+			name = ""
+		}
+		body, err := p.RawBody()
+		if err != nil {
+			return err
+		}
+		hostDir := filepath.Join(dir, fileName(p.Url().Host, "local"))
+		if err = os.MkdirAll(hostDir, 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(hostDir, fileName(name, "index")), body, 0644)
+	}
+}
+
+// fileName returns the string with the separators and other characters which
+// are not allowed in file names replaced by underscores, or fallback when it
+// is empty or names a directory, eg "..".
+func fileName(s, fallback string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', 0:
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return fallback
+	}
+	return s
+}
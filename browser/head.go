@@ -0,0 +1,95 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// HeadState is the lightweight state recorded by a HEAD request.
+type HeadState struct {
+	// URL is the final URL after any redirects were followed.
+	URL *url.URL
+
+	// StatusCode is the response status code.
+	StatusCode int
+
+	// Header contains the response headers.
+	Header http.Header
+}
+
+// Head requests the given URL using the HEAD method.
+//
+// The status, headers, and final URL of the response are available from
+// HeadState(). The DOM is not parsed, and the current page and history are
+// left untouched, which makes Head() useful for existence checks and
+// metadata probing.
+func (bow *Browser) Head(u string) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	resp, err := bow.httpProbe("HEAD", ur)
+	if err != nil {
+		return err
+	}
+	bow.head = newHeadState(resp)
+
+	return nil
+}
+
+// Exists requests the given URL using the HEAD method, and returns the status,
+// headers, and final URL of the response.
+//
+// The request is sent again using the GET method when the server does not
+// allow HEAD requests, in which case the body is not read. Cookies are sent
+// and stored as usual, but neither the current page nor HeadState() are
+// changed, so Exists() may be used to validate many URLs mid-session.
+func (bow *Browser) Exists(u string) (*HeadState, error) {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := bow.httpProbe("HEAD", ur)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		resp, err = bow.httpProbe("GET", ur)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newHeadState(resp), nil
+}
+
+// HeadState returns the state recorded by the last successful call to Head(),
+// or nil when Head() has not been called.
+func (bow *Browser) HeadState() *HeadState {
+	return bow.head
+}
+
+// newHeadState returns the state recorded from the given response.
+func newHeadState(resp *http.Response) *HeadState {
+	return &HeadState{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+}
+
+// httpProbe makes an HTTP request for the given URL using the given method.
+// The response body is closed before returning, without being read.
+func (bow *Browser) httpProbe(method string, u *url.URL) (*http.Response, error) {
+	req, err := bow.buildRequest(method, u.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := bow.send(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return resp, nil
+}
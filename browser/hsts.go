@@ -0,0 +1,81 @@
+package browser
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// SetHSTSJar sets the jar storing the Strict-Transport-Security policies of
+// the hosts the browser visits.
+//
+// Requests for http:// URLs of hosts with a policy are upgraded to https://
+// before they are sent, including redirects. Passing nil disables HSTS.
+func (bow *Browser) SetHSTSJar(hj jar.HSTSJar) {
+	bow.hsts = hj
+}
+
+// HSTSJar returns the jar storing the Strict-Transport-Security policies.
+func (bow *Browser) HSTSJar() jar.HSTSJar {
+	return bow.hsts
+}
+
+// upgradeHSTS changes the request to use HTTPS when the host has a policy.
+func (bow *Browser) upgradeHSTS(req *http.Request) {
+	if bow.hsts == nil || req.URL.Scheme != "http" || !bow.hsts.Secure(req.URL.Hostname()) {
+		return
+	}
+	req.URL.Scheme = "https"
+	if req.URL.Port() == "80" {
+		req.URL.Host = req.URL.Hostname()
+	}
+	req.Host = ""
+}
+
+// recordHSTS saves the policy sent with the response. Policies sent over
+// plain HTTP, or by IP addresses, are ignored.
+func (bow *Browser) recordHSTS(resp *http.Response) {
+	if bow.hsts == nil || resp == nil || resp.Request == nil || resp.Request.URL.Scheme != "https" {
+		return
+	}
+	header := resp.Header.Get("Strict-Transport-Security")
+	host := resp.Request.URL.Hostname()
+	if header == "" || net.ParseIP(host) != nil {
+		return
+	}
+	policy, ok := parseHSTS(header)
+	if ok {
+		bow.hsts.Save(host, policy)
+	}
+}
+
+// parseHSTS parses the value of a Strict-Transport-Security header. Returns
+// false when the header has no valid max-age directive.
+func parseHSTS(header string) (jar.HSTSPolicy, bool) {
+	var policy jar.HSTSPolicy
+	found := false
+	for _, directive := range strings.Split(header, ";") {
+		name, value := directive, ""
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, value = directive[:i], directive[i+1:]
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch name {
+		case "max-age":
+			age, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || age < 0 {
+				return policy, false
+			}
+			policy.Expires = time.Now().Add(time.Duration(age) * time.Second)
+			found = true
+		case "includesubdomains":
+			policy.IncludeSubdomains = true
+		}
+	}
+	return policy, found
+}
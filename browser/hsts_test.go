@@ -0,0 +1,68 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+func TestHSTS(t *testing.T) {
+	ut.Run(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secure" {
+			w.Header().Set("Strict-Transport-Security", "max-age=3600; includeSubDomains")
+		}
+		if r.URL.Path == "/insecure" {
+			w.Header().Set("Strict-Transport-Security", "max-age=3600")
+		}
+		fmt.Fprint(w, "ok")
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	tls := httptest.NewTLSServer(handler)
+	defer tls.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+	bow.attributes = AttributeMap{FollowRedirects: true}
+	bow.SetHSTSJar(jar.NewMemoryHSTS())
+	transport := bow.buildTransport()
+	transport.TLSClientConfig = tls.Client().Transport.(*http.Transport).TLSClientConfig
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, port, _ := net.SplitHostPort(addr); port == "443" {
+			return net.Dial(network, tls.Listener.Addr().String())
+		}
+		return net.Dial(network, plain.Listener.Addr().String())
+	}
+
+	ut.AssertNil(bow.Open("http://www.example.com/insecure"))
+	ut.AssertNil(bow.Open("http://www.example.com/"))
+	ut.AssertEquals("http", bow.Url().Scheme)
+
+	ut.AssertNil(bow.Open("https://example.com/secure"))
+	ut.AssertTrue(bow.HSTSJar().Secure("www.example.com"))
+	ut.AssertNil(bow.Open("http://www.example.com/"))
+	ut.AssertEquals("https://www.example.com/", bow.Url().String())
+	ut.AssertEquals("ok", bow.Body())
+
+	ut.AssertNil(bow.Open("http://www.example.org/"))
+	ut.AssertEquals("http", bow.Url().Scheme)
+}
+
+func TestParseHSTS(t *testing.T) {
+	ut.Run(t)
+	p, ok := parseHSTS(`max-age="31536000"; includeSubDomains; preload`)
+	ut.AssertTrue(ok)
+	ut.AssertTrue(p.IncludeSubdomains)
+	_, ok = parseHSTS("includeSubDomains")
+	ut.AssertFalse(ok)
+	_, ok = parseHSTS("max-age=abc")
+	ut.AssertFalse(ok)
+}
@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// Trailer returns the trailers sent by the server after the page body, or
+// nil when there are none.
+func (bow *Browser) Trailer() http.Header {
+	return bow.Page().Trailer()
+}
+
+// EarlyHints returns the preload and prefetch links of the 103 Early Hints
+// responses the server sent before the page.
+func (bow *Browser) EarlyHints() []*ResourceHint {
+	return bow.Page().EarlyHints()
+}
+
+// Trailer returns the trailers sent by the server after the page body, or
+// nil when there are none.
+func (p *Page) Trailer() http.Header {
+	return p.state.Response.Trailer
+}
+
+// EarlyHints returns the preload and prefetch links of the 103 Early Hints
+// responses the server sent before the page.
+func (p *Page) EarlyHints() []*ResourceHint {
+	hints := make([]*ResourceHint, 0, InitialAssetsSliceSize)
+	for _, h := range p.state.EarlyHints {
+		hints = append(hints, p.bow.linkHints(p.state.Request.URL, h)...)
+	}
+	return hints
+}
+
+// traceEarlyHints returns a copy of the request which stores the headers of
+// each 103 Early Hints response in hints, and fires the EarlyHints event.
+func (bow *Browser) traceEarlyHints(req *http.Request, hints *[]http.Header) *http.Request {
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != http.StatusEarlyHints {
+				return nil
+			}
+			h := http.Header(header).Clone()
+			*hints = append(*hints, h)
+			bow.fire(&Event{
+				Type:   EarlyHints,
+				URL:    req.URL,
+				Hints:  bow.linkHints(req.URL, h),
+				Labels: Labels(req),
+			})
+			return nil
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// linkHints returns the preload, prefetch, and modulepreload links of the Link
+// headers, resolved against the given URL.
+func (bow *Browser) linkHints(base *url.URL, header http.Header) []*ResourceHint {
+	var hints []*ResourceHint
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params := parseLink(link)
+			if target == "" {
+				continue
+			}
+			rel := ""
+			for _, token := range strings.Fields(strings.ToLower(params["rel"])) {
+				if token == "preload" || token == "prefetch" || token == "modulepreload" {
+					rel = token
+					break
+				}
+			}
+			if rel == "" {
+				continue
+			}
+			u, err := base.Parse(target)
+			if err != nil {
+				continue
+			}
+			hint := NewResourceHintAsset(u, "", rel, params["as"])
+			hint.bow = bow
+			hints = append(hints, hint)
+		}
+	}
+	return hints
+}
+
+// parseLink returns the target and the parameters of a single link of a Link
+// header, eg `</style.css>; rel=preload; as=style`.
+func parseLink(link string) (string, map[string]string) {
+	parts := strings.Split(link, ";")
+	target := strings.TrimSpace(parts[0])
+	if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+		return "", nil
+	}
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			params[name] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			params[name] = ""
+		}
+	}
+	return target[1 : len(target)-1], params
+}
@@ -0,0 +1,79 @@
+package browser
+
+import (
+	"time"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// KeepAlive requests the URL every interval until StopKeepAlive() or Close()
+// is called, to keep a session on the server from expiring during long jobs.
+//
+// The requests do not change the current page or the history, but cookies
+// set by their responses are stored. When the URL is empty, a HEAD request
+// for the current page is sent instead. The KeepAliveComplete event is fired
+// after each request, and KeepAliveError when it fails or the response status
+// is 400 or above. The events are fired from their own goroutine, so their
+// handlers must be safe for concurrent use. Calling KeepAlive again replaces
+// the previous task.
+func (bow *Browser) KeepAlive(u string, interval time.Duration) error {
+	method := "GET"
+	if u == "" {
+		if bow.state() == nil || bow.state().Request == nil {
+			return errors.NewPageNotLoaded("Cannot keep alive, no page has been loaded.")
+		}
+		method, u = "HEAD", bow.Url().String()
+	}
+	if interval <= 0 {
+		return errors.New("Keep-alive interval must be positive.")
+	}
+
+	bow.StopKeepAlive()
+	stop := make(chan struct{})
+	bow.keepAliveMutex.Lock()
+	bow.keepAlive = stop
+	bow.keepAliveMutex.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				bow.ping(method, u)
+			}
+		}
+	}()
+	return nil
+}
+
+// StopKeepAlive stops the task started by KeepAlive().
+func (bow *Browser) StopKeepAlive() {
+	bow.keepAliveMutex.Lock()
+	defer bow.keepAliveMutex.Unlock()
+	if bow.keepAlive != nil {
+		close(bow.keepAlive)
+		bow.keepAlive = nil
+	}
+}
+
+// ping sends a keep-alive request, and fires its event.
+func (bow *Browser) ping(method, u string) {
+	start := time.Now()
+	req, err := bow.buildRequest(method, u, nil, nil)
+	if err == nil {
+		var p *Page
+		if p, err = bow.peek(req); err == nil && p.StatusCode() >= 400 {
+			err = errors.New("Keep-alive request to '%s' returned %d.", u, p.StatusCode())
+		}
+	}
+	e := &Event{Type: KeepAliveComplete, Duration: time.Since(start)}
+	if req != nil {
+		e.URL, e.Labels = req.URL, Labels(req)
+	}
+	if err != nil {
+		e.Type, e.Err = KeepAliveError, err
+	}
+	bow.fire(e)
+}
@@ -0,0 +1,43 @@
+package browser
+
+import (
+	"context"
+	"net/http"
+)
+
+// labelsKey is the context key of the request labels.
+type labelsKey struct{}
+
+// SetLabels sets the labels of every request sent by the browser, eg the ID
+// of the crawl job using it.
+//
+// Labels are arbitrary key/value pairs which attribute traffic. They are
+// carried by the context of each request, so middlewares may read them with
+// Labels(), and they are passed to the handlers of navigation events. The
+// labels of RequestOptions are added to the labels of the browser.
+func (bow *Browser) SetLabels(labels map[string]string) {
+	bow.labels = labels
+}
+
+// Labels returns the labels of the request, or nil when it has none.
+func Labels(req *http.Request) map[string]string {
+	labels, _ := req.Context().Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// withLabels returns a copy of the request carrying its labels along with
+// the given labels, which replace the labels with the same names.
+func withLabels(req *http.Request, labels map[string]string) *http.Request {
+	if len(labels) == 0 {
+		return req
+	}
+	prev := Labels(req)
+	merged := make(map[string]string, len(prev)+len(labels))
+	for k, v := range prev {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return req.WithContext(context.WithValue(req.Context(), labelsKey{}, merged))
+}
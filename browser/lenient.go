@@ -0,0 +1,100 @@
+package browser
+
+import (
+	"bytes"
+	stderrors "errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/haruyama/surf/errors"
+	"golang.org/x/net/html/charset"
+)
+
+// RawBody returns the original body of the page, after removing the
+// Content-Encoding, without parsing or decoding its charset.
+func (bow *Browser) RawBody() ([]byte, error) {
+	return bow.Page().RawBody()
+}
+
+// RawBody returns the original body of the page, after removing the
+// Content-Encoding, without parsing or decoding its charset.
+func (p *Page) RawBody() ([]byte, error) {
+	if !p.state.HasBody() {
+		return nil, errors.NewPageNotLoaded("The page has no body.")
+	}
+	r, err := p.state.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// decodeLeniently reads the whole body, and returns a copy of the response
+// whose body is decoded when the Content-Encoding header names a supported
+// encoding. The response itself is left to the transport unchanged.
+//
+// A body which cannot be decoded is kept as it was received, and a truncated
+// body is kept as far as it was received. The ParseError event is fired in
+// place of returning an error.
+func (bow *Browser) decodeLeniently(req *http.Request, resp *http.Response) (*http.Response, error) {
+	closer := resp.Body
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil && !stderrors.Is(err, io.ErrUnexpectedEOF) {
+		return resp, err
+	}
+	expected := resp.ContentLength
+	if req.Method == "HEAD" {
+		expected = -1
+	}
+	if err != nil || (expected >= 0 && int64(len(raw)) < expected) {
+		bow.parseError(req.URL, errors.NewTruncatedResponse(req.URL.String(), expected, int64(len(raw))))
+	}
+
+	r := *resp
+	r.Header = resp.Header.Clone()
+	body := raw
+	encoding := r.Header.Get("Content-Encoding")
+	r.Body = &decodedBody{Reader: bytes.NewReader(raw), Closer: closer}
+	err = decodeResponse(&r)
+	if err == nil && r.Uncompressed {
+		var decoded []byte
+		decoded, err = ioutil.ReadAll(r.Body)
+		if err == nil || len(decoded) > 0 {
+			body = decoded
+		}
+	}
+	if err != nil {
+		bow.parseError(req.URL, errors.New(
+			"Cannot decode the %s body of '%s': %s", encoding, req.URL, err))
+		r.Header.Del("Content-Encoding")
+	}
+	r.Body = &decodedBody{Reader: bytes.NewReader(body), Closer: closer}
+	r.ContentLength = int64(len(body))
+	return &r, nil
+}
+
+// sniffCharset returns a reader which decodes r using the charset found in
+// the body, when the body is not valid UTF-8, and passes the error describing
+// it to report.
+func (bow *Browser) sniffCharset(r io.Reader, u *url.URL, report func(error)) (io.Reader, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.Valid(body) {
+		return bytes.NewReader(body), nil
+	}
+	enc, name, _ := charset.DetermineEncoding(body, "")
+	report(errors.New(
+		"The body of '%s' is not valid UTF-8, decoded as %s.", u, name))
+	return enc.NewDecoder().Reader(bytes.NewReader(body)), nil
+}
+
+// parseError fires the ParseError event.
+func (bow *Browser) parseError(u *url.URL, err error) {
+	bow.fire(&Event{Type: ParseError, URL: u, Err: err})
+}
@@ -0,0 +1,71 @@
+package browser
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SetMaxConcurrentRequests limits the number of requests the browser sends at
+// the same time, including asset downloads and requests made by asynchronous
+// event handlers. Requests over the limit wait for a slot.
+//
+// A slot is held until the response body has been closed. Zero or a negative
+// number removes the limit.
+func (bow *Browser) SetMaxConcurrentRequests(n int) {
+	bow.slotsMutex.Lock()
+	defer bow.slotsMutex.Unlock()
+	if n <= 0 {
+		bow.slots = nil
+		return
+	}
+	bow.slots = make(chan struct{}, n)
+}
+
+// acquire waits for a request slot, and returns the function which releases
+// it. The function may be called more than once.
+func (bow *Browser) acquire() func() {
+	bow.slotsMutex.Lock()
+	slots := bow.slots
+	bow.slotsMutex.Unlock()
+	if slots == nil {
+		return func() {}
+	}
+
+	slots <- struct{}{}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-slots })
+	}
+}
+
+// releasingBody releases a request slot when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body, and releases the request slot.
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// limitTransport holds a request slot for each request sent through the
+// transport until its response body has been closed.
+type limitTransport struct {
+	bow       *Browser
+	transport http.RoundTripper
+}
+
+// RoundTrip waits for a request slot, and sends the request.
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release := t.bow.acquire()
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
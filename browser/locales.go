@@ -0,0 +1,126 @@
+package browser
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// Alternate is a locale variant of a page, declared by an alternate link with
+// an hreflang attribute.
+type Alternate struct {
+	// Lang is the language of the variant in lower case, eg "en-gb", or
+	// "x-default" for the page shown when no language matches.
+	Lang string
+
+	// URL is the absolute URL of the variant.
+	URL *url.URL
+}
+
+// Alternates returns the locale variants of the page, declared by the
+// <link rel="alternate" hreflang="..."> elements of the page and by the Link
+// headers of the response. Only the first variant of each language is
+// returned.
+func (p *Page) Alternates() []*Alternate {
+	var alts []*Alternate
+	seen := make(map[string]bool)
+	add := func(lang string, u *url.URL) {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || seen[lang] {
+			return
+		}
+		seen[lang] = true
+		alts = append(alts, &Alternate{Lang: lang, URL: u})
+	}
+
+	rc := p.ResolutionContext()
+	p.Find("link[hreflang]").Each(func(_ int, s *goquery.Selection) {
+		if matchRel(s, "alternate") == "" {
+			return
+		}
+		if u, err := rc.resolveAttr("href", s); err == nil {
+			add(s.AttrOr("hreflang", ""), u)
+		}
+	})
+	for _, value := range p.ResponseHeaders().Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params := parseLink(link)
+			if target == "" || params["hreflang"] == "" {
+				continue
+			}
+			isAlternate := false
+			for _, token := range strings.Fields(strings.ToLower(params["rel"])) {
+				isAlternate = isAlternate || token == "alternate"
+			}
+			if !isAlternate {
+				continue
+			}
+			if u, err := p.Url().Parse(target); err == nil {
+				add(params["hreflang"], u)
+			}
+		}
+	}
+	return alts
+}
+
+// Alternates returns the locale variants of the current page.
+func (bow *Browser) Alternates() []*Alternate {
+	return bow.Page().Alternates()
+}
+
+// FetchLocales fetches each locale variant of the current page, up to
+// concurrency variants at the same time, and returns the pages keyed by
+// their language.
+//
+// The variants are fetched like Peek(), so the current page and the history
+// are unchanged. A concurrency below one fetches the variants one at a time.
+// When a variant can't be fetched, the pages of the other variants are
+// returned along with the error of the first failed variant in the order of
+// Alternates().
+func (bow *Browser) FetchLocales(concurrency int) (map[string]*Page, error) {
+	if bow.state() == nil || bow.state().Request == nil {
+		return nil, errors.NewPageNotLoaded("Cannot fetch locales, no page has been loaded.")
+	}
+	alts := bow.Alternates()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(alts) {
+		concurrency = len(alts)
+	}
+
+	pages := make([]*Page, len(alts))
+	errs := make([]error, len(alts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pages[i], errs[i] = bow.Peek(alts[i].URL.String())
+			}
+		}()
+	}
+	for i := range alts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	locales := make(map[string]*Page, len(alts))
+	var err error
+	for i, alt := range alts {
+		if errs[i] != nil {
+			if err == nil {
+				err = errs[i]
+			}
+			continue
+		}
+		locales[alt.Lang] = pages[i]
+	}
+	return locales, err
+}
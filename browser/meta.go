@@ -0,0 +1,154 @@
+package browser
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// LanguageDetector guesses the language of the given text, returning a
+// language tag such as "en" or an empty string when unsure.
+type LanguageDetector func(text string) string
+
+// Robots holds the directives found in the robots meta tag and the
+// X-Robots-Tag response header.
+type Robots struct {
+	// NoIndex is true when the page should not be indexed.
+	NoIndex bool
+
+	// NoFollow is true when the links on the page should not be followed.
+	NoFollow bool
+
+	// NoArchive is true when the page should not be archived or cached.
+	NoArchive bool
+
+	// Directives contains every directive found, lower cased.
+	Directives []string
+}
+
+// RobotsMeta returns the directives from the robots meta tag and the
+// X-Robots-Tag header of the current page.
+//
+// Header values prefixed with a user agent name, eg "googlebot: noindex",
+// are ignored. The "none" directive implies both noindex and nofollow.
+func (bow *Browser) RobotsMeta() *Robots {
+	robots := &Robots{}
+	bow.Find("meta[name]").Each(func(_ int, s *goquery.Selection) {
+		if strings.EqualFold(s.AttrOr("name", ""), "robots") {
+			robots.add(s.AttrOr("content", ""))
+		}
+	})
+	if bow.state().Response != nil {
+		for _, h := range bow.state().Response.Header["X-Robots-Tag"] {
+			if i := strings.Index(h, ":"); i != -1 && !strings.ContainsAny(h[:i], ",") {
+				continue
+			}
+			robots.add(h)
+		}
+	}
+
+	return robots
+}
+
+// add parses a comma separated list of directives.
+func (r *Robots) add(value string) {
+	for _, d := range strings.Split(value, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" {
+			continue
+		}
+		r.Directives = append(r.Directives, d)
+		switch d {
+		case "noindex":
+			r.NoIndex = true
+		case "nofollow":
+			r.NoFollow = true
+		case "noarchive":
+			r.NoArchive = true
+		case "none":
+			r.NoIndex = true
+			r.NoFollow = true
+		}
+	}
+}
+
+// Language returns the declared language of the current page.
+//
+// The lang attribute of the html element is preferred, followed by the
+// Content-Language header and the content-language meta tag. When none are
+// present and a LanguageDetector has been set, the detected language of the
+// body text is returned.
+func (bow *Browser) Language() string {
+	if lang := strings.TrimSpace(bow.Find("html").AttrOr("lang", "")); lang != "" {
+		return lang
+	}
+	if bow.state().Response != nil {
+		if lang := bow.state().Response.Header.Get("Content-Language"); lang != "" {
+			return strings.TrimSpace(strings.Split(lang, ",")[0])
+		}
+	}
+	lang := ""
+	bow.Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if strings.EqualFold(s.AttrOr("http-equiv", ""), "content-language") {
+			lang = strings.TrimSpace(strings.Split(s.AttrOr("content", ""), ",")[0])
+		}
+		return lang == ""
+	})
+	if lang == "" && bow.languageDetector != nil {
+		lang = bow.languageDetector(bow.Find("body").Text())
+	}
+
+	return lang
+}
+
+// SetLanguageDetector sets the function used by Language() to detect the
+// language of pages which do not declare one.
+func (bow *Browser) SetLanguageDetector(d LanguageDetector) {
+	bow.languageDetector = d
+}
+
+// Description returns the page description from the description meta tag,
+// falling back to the og:description and twitter:description properties.
+func (bow *Browser) Description() string {
+	if d := bow.metaContent("name", "description"); len(d) > 0 {
+		return d[0]
+	}
+	if d := bow.metaContent("property", "og:description"); len(d) > 0 {
+		return d[0]
+	}
+	if d := bow.metaContent("name", "twitter:description"); len(d) > 0 {
+		return d[0]
+	}
+	return ""
+}
+
+// Keywords returns the page keywords from the keywords meta tag, falling back
+// to the article:tag properties used by Open Graph.
+func (bow *Browser) Keywords() []string {
+	var keywords []string
+	for _, c := range bow.metaContent("name", "keywords") {
+		for _, k := range strings.Split(c, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keywords = append(keywords, k)
+			}
+		}
+	}
+	if len(keywords) == 0 {
+		keywords = bow.metaContent("property", "article:tag")
+	}
+	return keywords
+}
+
+// metaContent returns the trimmed, non-empty content of every meta tag whose
+// attr attribute matches the name, ignoring case.
+func (bow *Browser) metaContent(attr, name string) []string {
+	var values []string
+	bow.Find("meta[" + attr + "]").Each(func(_ int, s *goquery.Selection) {
+		if strings.EqualFold(s.AttrOr(attr, ""), name) {
+			if c := strings.TrimSpace(s.AttrOr("content", "")); c != "" {
+				values = append(values, c)
+			}
+		}
+	})
+	return values
+}
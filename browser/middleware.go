@@ -0,0 +1,63 @@
+package browser
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// RoundTripFunc sends a request and returns its response.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the function which sends a request. The returned function
+// may change the request, the response, call next more than once, or return
+// a response without calling next at all.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds a middleware to the chain wrapped around every request the browser
+// sends.
+//
+// The first middleware added is the outermost, so it sees the request first
+// and the response last. The innermost function signs the request and sends
+// it, which means retried requests are signed again.
+func (bow *Browser) Use(m Middleware) {
+	bow.middleware = append(bow.middleware, m)
+}
+
+// do sends the request through the middleware chain.
+func (bow *Browser) do(req *http.Request) (*http.Response, error) {
+	rt := RoundTripFunc(bow.roundTrip)
+	for i := len(bow.middleware) - 1; i >= 0; i-- {
+		rt = bow.middleware[i](rt)
+	}
+
+	req, done, err := bow.track(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = bow.waitProfile(req); err != nil {
+		done()
+		return nil, err
+	}
+	slot := bow.acquire()
+	release := func() {
+		slot()
+		done()
+	}
+	atomic.AddInt64(&bow.counters.requests, 1)
+	atomic.AddInt64(&bow.counters.inFlight, 1)
+	defer atomic.AddInt64(&bow.counters.inFlight, -1)
+	bow.counters.countRequest(req.URL.Host)
+	resp, err := rt(req)
+	if err != nil {
+		release()
+		atomic.AddInt64(&bow.counters.errors, 1)
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	host := req.URL.Host
+	if resp.Request != nil {
+		host = resp.Request.URL.Host
+	}
+	resp.Body = &trafficBody{ReadCloser: resp.Body, counters: &bow.counters, host: host}
+	return resp, nil
+}
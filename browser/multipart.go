@@ -0,0 +1,119 @@
+package browser
+
+import (
+	"io"
+	"mime/multipart"
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+// multipartFile is a file attached to a multipart/form-data field.
+type multipartFile struct {
+	// fileName is the file name sent to the server.
+	fileName string
+
+	// reader supplies the contents of the file.
+	reader io.Reader
+}
+
+// multipartFiles maps field names to the files attached to them, in the order
+// they were attached.
+type multipartFiles map[string][]multipartFile
+
+// newMultipartReader returns the content type and a reader which streams the
+// fields and files in multipart/form-data format.
+//
+// The body is produced by a goroutine writing to a pipe while the request is
+// being sent, so the files are never buffered in memory. The goroutine is
+// started by the first read, so bodies of requests which are never sent, eg
+// because a handler of the BeforeNavigate event vetoed them, hold nothing.
+// Readers which are also io.Closer are closed once they have been copied, or
+// when the body is closed before it has been read.
+func newMultipartReader(fields url.Values, files multipartFiles) (string, io.ReadCloser) {
+	pr, pw := io.Pipe()
+	r := &multipartReader{
+		pr:     pr,
+		pw:     pw,
+		writer: multipart.NewWriter(pw),
+		fields: fields,
+		files:  files,
+	}
+	return r.writer.FormDataContentType(), r
+}
+
+// multipartReader reads the multipart body written by a goroutine to a pipe.
+type multipartReader struct {
+	pr     *io.PipeReader
+	pw     *io.PipeWriter
+	writer *multipart.Writer
+	fields url.Values
+	files  multipartFiles
+
+	// once starts the goroutine writing the body, or prevents it from being
+	// started once the body has been closed.
+	once sync.Once
+}
+
+// Read starts the goroutine writing the body when needed, and reads from the
+// pipe.
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		go func() {
+			r.pw.CloseWithError(writeMultipart(r.writer, r.fields, r.files))
+		}()
+	})
+	return r.pr.Read(p)
+}
+
+// Close closes the pipe, and closes the files when the body has not been read.
+func (r *multipartReader) Close() error {
+	r.once.Do(func() {
+		for _, fs := range r.files {
+			for _, f := range fs {
+				if c, ok := f.reader.(io.Closer); ok {
+					c.Close()
+				}
+			}
+		}
+	})
+	return r.pr.Close()
+}
+
+// writeMultipart writes the fields and files to the multipart writer and
+// closes it.
+func writeMultipart(writer *multipart.Writer, fields url.Values, files multipartFiles) error {
+	for k, vs := range fields {
+		for _, v := range vs {
+			if err := writer.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for k, fs := range files {
+		for _, f := range fs {
+			part, err := writer.CreateFormFile(k, f.fileName)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(part, f.reader)
+			if c, ok := f.reader.(io.Closer); ok {
+				c.Close()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return writer.Close()
+}
+
+// readerFileName returns the file name to send for the reader attached to
+// the given field.
+func readerFileName(field string, r io.Reader) string {
+	if n, ok := r.(interface{ Name() string }); ok {
+		return filepath.Base(n.Name())
+	}
+	return field
+}
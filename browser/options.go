@@ -0,0 +1,152 @@
+package browser
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// RequestOptions change how a single request is sent, without changing the
+// settings of the browser.
+type RequestOptions struct {
+	// Header contains headers sent with the request, replacing the browser
+	// headers with the same names.
+	Header http.Header
+
+	// Timeout limits the time taken by the request, including following
+	// redirects and reading the body. Zero means no limit.
+	Timeout time.Duration
+
+	// Referer is sent as the Referer header when not empty, regardless of the
+	// SendReferer attribute.
+	Referer string
+
+	// NoFollowRedirects makes a redirect response the page, instead of
+	// following its Location header.
+	NoFollowRedirects bool
+
+	// MaxBodySize is the size in bytes above which the response body is
+	// rejected with an error. Zero means no limit.
+	MaxBodySize int64
+
+	// Labels are added to the labels of the browser for the request, and the
+	// requests which follow from it, eg redirects and meta refreshes.
+	Labels map[string]string
+
+	// deadline is when the page load timeout of the browser expires, or zero.
+	deadline time.Time
+}
+
+// requestOptionsKey is the context key of the request options.
+type requestOptionsKey struct{}
+
+// OpenWithOptions requests the given URL using the GET method and the given options.
+func (bow *Browser) OpenWithOptions(u string, opts RequestOptions) error {
+	req, err := bow.buildRequest("GET", u, nil, nil)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(opts.apply(req), TriggerOpen)
+}
+
+// PostWithOptions requests the given URL using the POST method and the given options.
+func (bow *Browser) PostWithOptions(u string, contentType string, body io.Reader, opts RequestOptions) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	req, err := bow.buildPOST(ur, nil, contentType, body)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(opts.apply(req), TriggerOpen)
+}
+
+// apply sets the headers of the request, and returns a copy of the request
+// carrying the options in its context.
+func (opts RequestOptions) apply(req *http.Request) *http.Request {
+	for name, values := range opts.Header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if opts.Referer != "" {
+		req.Header.Set("Referer", opts.Referer)
+	}
+	req = withLabels(req, opts.Labels)
+	return req.WithContext(context.WithValue(req.Context(), requestOptionsKey{}, &opts))
+}
+
+// withoutOptions returns the request without its options, and without the
+// headers they set, so that requests duplicating it, eg by Reload(), are sent
+// with the settings of the browser. The options apply to a single request.
+func (bow *Browser) withoutOptions(req *http.Request) *http.Request {
+	opts := requestOptions(req)
+	if opts == nil {
+		return req
+	}
+	for name := range opts.Header {
+		name = http.CanonicalHeaderKey(name)
+		req.Header.Del(name)
+		if values, ok := bow.headers[name]; ok {
+			req.Header[name] = append([]string(nil), values...)
+		}
+	}
+	if opts.Referer != "" {
+		req.Header.Del("Referer")
+	}
+	return req.WithContext(context.WithValue(req.Context(), requestOptionsKey{}, (*RequestOptions)(nil)))
+}
+
+// timeout returns the time the request may take, which is the shorter of the
+// timeout and the time left until the deadline.
+func (opts *RequestOptions) timeout() time.Duration {
+	if opts.deadline.IsZero() {
+		return opts.Timeout
+	}
+	left := time.Until(opts.deadline)
+	if left <= 0 {
+		left = time.Nanosecond
+	}
+	if opts.Timeout > 0 && opts.Timeout < left {
+		return opts.Timeout
+	}
+	return left
+}
+
+// requestOptions returns the options of the request, or nil when it has none.
+func requestOptions(req *http.Request) *RequestOptions {
+	opts, _ := req.Context().Value(requestOptionsKey{}).(*RequestOptions)
+	return opts
+}
+
+// limitBody returns a reader which fails once more than the maximum body size
+// of the request options has been read from r.
+func limitBody(req *http.Request, r io.Reader) io.Reader {
+	opts := requestOptions(req)
+	if opts == nil || opts.MaxBodySize <= 0 {
+		return r
+	}
+	return &maxBodyReader{r: r, remaining: opts.MaxBodySize}
+}
+
+// maxBodyReader is a reader which fails when too many bytes are read.
+type maxBodyReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read reads from the underlying reader.
+func (mr *maxBodyReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > mr.remaining+1 {
+		p = p[:mr.remaining+1]
+	}
+	n, err := mr.r.Read(p)
+	mr.remaining -= int64(n)
+	if mr.remaining < 0 {
+		return 0, errors.New("Response body is larger than the maximum size.")
+	}
+	return n, err
+}
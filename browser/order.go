@@ -0,0 +1,288 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetHeaderOrder sets the order the request headers are sent in.
+//
+// Headers which are not named are sent after the named ones, in the order
+// chosen by the Go transport. Calling it without names restores the order of
+// the transport. The order applies to HTTP/1.1 requests, and HTTPS requests
+// sent with an order are limited to HTTP/1.1, since HTTP/2 compresses the
+// headers itself. HTTPS requests sent through a proxy keep the order of the
+// transport.
+func (bow *Browser) SetHeaderOrder(names ...string) {
+	bow.transportMutex.Lock()
+	bow.headerOrder = nil
+	if len(names) > 0 {
+		bow.headerOrder = append([]string(nil), names...)
+	}
+	bow.transportMutex.Unlock()
+
+	// Idle connections were opened with the previous order.
+	bow.buildTransport().CloseIdleConnections()
+}
+
+// orderOf returns the header order the next connection is opened with.
+func (bow *Browser) orderOf() []string {
+	bow.transportMutex.Lock()
+	defer bow.transportMutex.Unlock()
+	return bow.headerOrder
+}
+
+// orderedDialer wraps the dial function of the transport so the headers of the
+// requests sent over plain connections are written in the header order.
+func (bow *Browser) orderedDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if order := bow.orderOf(); len(order) > 0 {
+			return newOrderedConn(conn, order), nil
+		}
+		return conn, nil
+	}
+}
+
+// dialTLSContext opens the TLS connections of HTTPS requests, using the
+// function set by SetDialTLSContext() when there is one, and writes the
+// headers of the requests in the header order.
+func (bow *Browser) dialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	bow.transportMutex.Lock()
+	dial, order, transport := bow.dialTLS, bow.headerOrder, bow.transport
+	bow.transportMutex.Unlock()
+
+	var conn net.Conn
+	var err error
+	if dial != nil {
+		conn, err = dial(ctx, network, addr)
+	} else {
+		conn, err = dialTLS(ctx, transport, network, addr)
+	}
+	if err != nil || len(order) == 0 {
+		return conn, err
+	}
+	if tc, ok := conn.(*tls.Conn); ok && tc.ConnectionState().NegotiatedProtocol == "h2" {
+		return conn, nil
+	}
+	return newOrderedConn(conn, order), nil
+}
+
+// dialTLS opens a TLS connection using the dial function and the TLS settings
+// of the transport, offering only HTTP/1.1.
+func dialTLS(ctx context.Context, transport *http.Transport, network, addr string) (net.Conn, error) {
+	conn, err := transport.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		config = transport.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	config.NextProtos = []string{"http/1.1"}
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// orderedConn is a connection which writes the headers of the HTTP/1.1
+// requests sent over it in a given order.
+//
+// The request heads are buffered until they are complete and their header
+// lines are sorted. Bodies are passed through, following Content-Length and
+// chunked framing to find the start of the next request. Connections which
+// don't start with a request, such as TLS connections, and connections which
+// are tunneled or upgraded are passed through once detected.
+type orderedConn struct {
+	net.Conn
+
+	// rank maps the lower case header names to their position in the order.
+	rank map[string]int
+
+	// state is what the next bytes written are part of.
+	state orderedState
+
+	// head is the request head or chunk line being buffered.
+	head []byte
+
+	// remaining is the number of bytes left in the body or the chunk.
+	remaining int64
+
+	// started is true once the first byte has been written.
+	started bool
+}
+
+// orderedState is what the bytes written to an orderedConn are part of.
+type orderedState int
+
+const (
+	// orderedHead is a request head.
+	orderedHead orderedState = iota
+
+	// orderedBody is a body with a Content-Length.
+	orderedBody
+
+	// orderedChunkLine is the size line of a chunk.
+	orderedChunkLine
+
+	// orderedChunk is the data of a chunk, followed by its line break.
+	orderedChunk
+
+	// orderedTrailer is the trailer of a chunked body.
+	orderedTrailer
+
+	// orderedRaw is anything which is not HTTP/1.1.
+	orderedRaw
+)
+
+// newOrderedConn returns a connection writing the request headers to conn in
+// the given order.
+func newOrderedConn(conn net.Conn, order []string) *orderedConn {
+	rank := make(map[string]int, len(order))
+	for _, name := range order {
+		name = strings.ToLower(name)
+		if _, ok := rank[name]; !ok {
+			rank[name] = len(rank)
+		}
+	}
+	return &orderedConn{Conn: conn, rank: rank}
+}
+
+// Write writes the bytes to the connection, sorting the headers of the
+// request heads.
+func (c *orderedConn) Write(p []byte) (int, error) {
+	n := len(p)
+	if !c.started && n > 0 {
+		c.started = true
+		if p[0] < 'A' || p[0] > 'Z' {
+			c.state = orderedRaw
+		}
+	}
+	for len(p) > 0 {
+		var out []byte
+		switch c.state {
+		case orderedRaw:
+			out, p = p, nil
+		case orderedBody, orderedChunk:
+			k := int64(len(p))
+			if k > c.remaining {
+				k = c.remaining
+			}
+			out, p = p[:k], p[k:]
+			c.remaining -= k
+			if c.remaining == 0 && c.state == orderedBody {
+				c.state = orderedHead
+			} else if c.remaining == 0 {
+				c.state = orderedChunkLine
+			}
+		case orderedChunkLine, orderedTrailer:
+			i := bytes.IndexByte(p, '\n')
+			if i < 0 {
+				c.head = append(c.head, p...)
+				out, p = p, nil
+				break
+			}
+			c.head = append(c.head, p[:i+1]...)
+			out, p = p[:i+1], p[i+1:]
+			c.endLine()
+		case orderedHead:
+			c.head = append(c.head, p...)
+			p = nil
+			i := bytes.Index(c.head, []byte("\r\n\r\n"))
+			if i < 0 {
+				continue
+			}
+			head := c.head[:i+4]
+			p = append([]byte(nil), c.head[i+4:]...)
+			c.head = nil
+			out = c.sortHead(head)
+		}
+		if _, err := c.Conn.Write(out); err != nil {
+			return n - len(p) - len(out), err
+		}
+	}
+	return n, nil
+}
+
+// endLine handles the chunk size or trailer line which was just buffered.
+func (c *orderedConn) endLine() {
+	line := strings.TrimSpace(string(c.head))
+	c.head = c.head[:0]
+	if c.state == orderedTrailer {
+		if line == "" {
+			c.state = orderedHead
+		}
+		return
+	}
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	switch {
+	case err != nil:
+		c.state = orderedRaw
+	case size == 0:
+		c.state = orderedTrailer
+	default:
+		c.state = orderedChunk
+		c.remaining = size + 2
+	}
+}
+
+// sortHead returns the request head with its header lines in order, and
+// prepares the connection for the body which follows it.
+func (c *orderedConn) sortHead(head []byte) []byte {
+	lines := strings.Split(string(head[:len(head)-4]), "\r\n")
+	headers := lines[1:]
+	sort.SliceStable(headers, func(i, j int) bool {
+		return c.rankOf(headers[i]) < c.rankOf(headers[j])
+	})
+
+	c.state = orderedHead
+	chunked := false
+	for _, line := range headers {
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "content-length":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+				c.state, c.remaining = orderedBody, n
+			}
+		case "transfer-encoding":
+			chunked = strings.EqualFold(value, "chunked")
+		case "upgrade":
+			c.state = orderedRaw
+		}
+	}
+	if strings.HasPrefix(lines[0], "CONNECT ") {
+		c.state = orderedRaw
+	} else if chunked && c.state != orderedRaw {
+		c.state = orderedChunkLine
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n\r\n")
+}
+
+// rankOf returns the position of the header line in the order, placing the
+// headers which are not in the order last.
+func (c *orderedConn) rankOf(line string) int {
+	name, _, _ := strings.Cut(line, ":")
+	if rank, ok := c.rank[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return rank
+	}
+	return len(c.rank)
+}
@@ -0,0 +1,462 @@
+package browser
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+	"golang.org/x/net/html"
+)
+
+// Page is a page loaded by a browser: the current page returned by Page(),
+// a page returned by a navigation method such as OpenPage(), a page of the
+// history returned by HistoryPages(), or a page requested without replacing
+// the current page, eg by Peek().
+//
+// A page keeps its URL, status, headers and document after the browser
+// navigates elsewhere, so it may be retained, or passed to worker goroutines
+// which extract its assets and forms. The document is parsed once, the first
+// time it's needed, even when the page is shared by several goroutines.
+type Page struct {
+	bow   *Browser
+	state *jar.State
+}
+
+// Url returns the page URL.
+func (p *Page) Url() *url.URL {
+	return p.state.Request.URL
+}
+
+// StatusCode returns the response status code.
+func (p *Page) StatusCode() int {
+	return p.state.Response.StatusCode
+}
+
+// ResponseHeaders returns the page headers.
+func (p *Page) ResponseHeaders() http.Header {
+	return p.state.Response.Header
+}
+
+// Title returns the page title.
+func (p *Page) Title() string {
+	return p.bow.documentOf(p.state).Find("title").Text()
+}
+
+// Body returns the page body as a string of html.
+//
+// The original body is returned unchanged when the page is not HTML.
+func (p *Page) Body() string {
+	return p.bow.bodyOf(p.state)
+}
+
+// Dom returns the inner *goquery.Selection.
+func (p *Page) Dom() *goquery.Selection {
+	return p.bow.documentOf(p.state).First()
+}
+
+// Document returns the parsed *goquery.Document of the page.
+func (p *Page) Document() *goquery.Document {
+	return p.bow.documentOf(p.state)
+}
+
+// Node returns the root *html.Node of the page.
+func (p *Page) Node() *html.Node {
+	return p.bow.documentOf(p.state).Get(0)
+}
+
+// Find returns the dom selections matching the given expression.
+func (p *Page) Find(expr string) *goquery.Selection {
+	return p.bow.documentOf(p.state).Find(expr)
+}
+
+// ResolutionContext returns the resolution context of the page.
+func (p *Page) ResolutionContext() *ResolutionContext {
+	return NewResolutionContext(p.Url(), p.Document())
+}
+
+// ResolveUrl returns an absolute URL for a possibly relative URL in the page.
+func (p *Page) ResolveUrl(u *url.URL) *url.URL {
+	return p.ResolutionContext().Resolve(u)
+}
+
+// Form returns the form in the page that matches the given expr.
+func (p *Page) Form(expr string) (Submittable, error) {
+	sel := p.Find(expr)
+	if sel.Length() == 0 {
+		return nil, errors.NewElementNotFound(
+			"Form not found matching expr '%s'.", expr)
+	}
+	if !sel.Is("form") {
+		return nil, errors.NewElementNotFound(
+			"Expr '%s' does not match a form tag.", expr)
+	}
+
+	return newForm(p.bow, p, sel), nil
+}
+
+// Forms returns an array of every form in the page.
+func (p *Page) Forms() []Submittable {
+	sel := p.Find("form")
+	len := sel.Length()
+	if len == 0 {
+		return nil
+	}
+
+	forms := make([]Submittable, 0, len)
+	sel.Each(func(_ int, s *goquery.Selection) {
+		forms = append(forms, newForm(p.bow, p, s))
+	})
+	return forms
+}
+
+// Links returns an array of every link found in the page.
+//
+// Each link carries its context, such as the heading of the section it's in,
+// which crawlers may use to rank the links worth following. The heading and
+// the list item are only found when asked for.
+func (p *Page) Links() []*Link {
+	rc := p.ResolutionContext()
+	links := make([]*Link, 0, InitialAssetsSliceSize)
+	p.Find("a").Each(func(_ int, s *goquery.Selection) {
+		href, err := rc.resolveAttr("href", s)
+		if err == nil {
+			link := NewLinkAsset(
+				href,
+				p.bow.attrOrDefault("id", "", s),
+				s.Text(),
+			)
+			link.Rel = strings.Fields(strings.ToLower(s.AttrOr("rel", "")))
+			link.node = s.Get(0)
+			link.Position = len(links)
+			links = append(links, link)
+		}
+	})
+
+	return links
+}
+
+// Images returns an array of every image found in the page.
+func (p *Page) Images() []*Image {
+	rc := p.ResolutionContext()
+	images := make([]*Image, 0, InitialAssetsSliceSize)
+	p.Find("img").Each(func(_ int, s *goquery.Selection) {
+		src, err := rc.resolveAttr("src", s)
+		if err == nil {
+			image := NewImageAsset(
+				src,
+				p.bow.attrOrDefault("id", "", s),
+				p.bow.attrOrDefault("alt", "", s),
+				p.bow.attrOrDefault("title", "", s),
+			)
+			image.Width, _ = strconv.Atoi(strings.TrimSpace(s.AttrOr("width", "")))
+			image.Height, _ = strconv.Atoi(strings.TrimSpace(s.AttrOr("height", "")))
+			image.Loading = s.AttrOr("loading", "")
+			image.Decoding = s.AttrOr("decoding", "")
+			_, image.HasSrcset = s.Attr("srcset")
+			image.bow = p.bow
+			images = append(images, image)
+		}
+	})
+
+	return images
+}
+
+// Stylesheets returns an array of every stylesheet linked to the document.
+func (p *Page) Stylesheets() []*Stylesheet {
+	rc := p.ResolutionContext()
+	stylesheets := make([]*Stylesheet, 0, InitialAssetsSliceSize)
+	p.Find("link").Each(func(_ int, s *goquery.Selection) {
+		rel, ok := s.Attr("rel")
+		if ok && rel == "stylesheet" {
+			href, err := rc.resolveAttr("href", s)
+			if err == nil {
+				stylesheet := NewStylesheetAsset(
+					href,
+					p.bow.attrOrDefault("id", "", s),
+					p.bow.attrOrDefault("media", "all", s),
+					p.bow.attrOrDefault("type", "text/css", s),
+				)
+				stylesheet.bow = p.bow
+				stylesheets = append(stylesheets, stylesheet)
+			}
+		}
+	})
+
+	return stylesheets
+}
+
+// Scripts returns an array of every script linked to the document.
+func (p *Page) Scripts() []*Script {
+	rc := p.ResolutionContext()
+	scripts := make([]*Script, 0, InitialAssetsSliceSize)
+	p.Find("script").Each(func(_ int, s *goquery.Selection) {
+		src, err := rc.resolveAttr("src", s)
+		if err == nil {
+			script := NewScriptAsset(
+				src,
+				p.bow.attrOrDefault("id", "", s),
+				p.bow.attrOrDefault("type", "text/javascript", s),
+			)
+			script.bow = p.bow
+			scripts = append(scripts, script)
+		}
+	})
+
+	return scripts
+}
+
+// ResourceHints returns an array of every preload, prefetch, and modulepreload
+// link in the document.
+//
+// The hints are downloadable, so archivers may fetch them along with the
+// other page assets to keep the resources critical to rendering the page.
+func (p *Page) ResourceHints() []*ResourceHint {
+	rc := p.ResolutionContext()
+	hints := make([]*ResourceHint, 0, InitialAssetsSliceSize)
+	p.Find("link[rel]").Each(func(_ int, s *goquery.Selection) {
+		rel := matchRel(s, "preload", "prefetch", "modulepreload")
+		if rel == "" {
+			return
+		}
+		href, err := rc.resolveAttr("href", s)
+		if err == nil {
+			hint := NewResourceHintAsset(
+				href,
+				p.bow.attrOrDefault("id", "", s),
+				rel,
+				p.bow.attrOrDefault("as", "", s),
+			)
+			hint.bow = p.bow
+			hints = append(hints, hint)
+		}
+	})
+
+	return hints
+}
+
+// Media returns an array of every audio and video source in the document.
+//
+// The sources are read from the src attribute of audio and video elements,
+// and from their source children.
+func (p *Page) Media() []*Media {
+	rc := p.ResolutionContext()
+	media := make([]*Media, 0, InitialAssetsSliceSize)
+	p.Find("audio[src], video[src], audio > source[src], video > source[src]").Each(func(_ int, s *goquery.Selection) {
+		src, err := rc.resolveAttr("src", s)
+		if err == nil {
+			tag := goquery.NodeName(s)
+			if tag == "source" {
+				tag = goquery.NodeName(s.Parent())
+			}
+			m := NewMediaAsset(
+				src,
+				p.bow.attrOrDefault("id", "", s),
+				tag,
+				p.bow.attrOrDefault("type", "", s),
+			)
+			m.bow = p.bow
+			media = append(media, m)
+		}
+	})
+
+	return media
+}
+
+// Favicons returns an array of every icon and apple-touch-icon link in the
+// document.
+func (p *Page) Favicons() []*Favicon {
+	rc := p.ResolutionContext()
+	icons := make([]*Favicon, 0, InitialAssetsSliceSize)
+	p.Find("link[rel]").Each(func(_ int, s *goquery.Selection) {
+		if matchRel(s, "icon", "apple-touch-icon") == "" {
+			return
+		}
+		href, err := rc.resolveAttr("href", s)
+		if err == nil {
+			icon := NewFaviconAsset(
+				href,
+				p.bow.attrOrDefault("id", "", s),
+				p.bow.attrOrDefault("sizes", "", s),
+				p.bow.attrOrDefault("type", "", s),
+			)
+			icon.bow = p.bow
+			icons = append(icons, icon)
+		}
+	})
+
+	return icons
+}
+
+// Assets returns every asset in the page of the given types, or of every
+// type when none are given.
+//
+// The assets are grouped by type, in the order the types are given, which
+// lets downloaders and archivers handle every kind of asset in one loop.
+func (p *Page) Assets(types ...AssetType) []Assetable {
+	if len(types) == 0 {
+		types = []AssetType{
+			LinkAsset,
+			ImageAsset,
+			StylesheetAsset,
+			ScriptAsset,
+			ResourceHintAsset,
+			MediaAsset,
+			FaviconAsset,
+		}
+	}
+	assets := make([]Assetable, 0, InitialAssetsSliceSize)
+	for _, typ := range types {
+		switch typ {
+		case LinkAsset:
+			for _, a := range p.Links() {
+				assets = append(assets, a)
+			}
+		case ImageAsset:
+			for _, a := range p.Images() {
+				assets = append(assets, a)
+			}
+		case StylesheetAsset:
+			for _, a := range p.Stylesheets() {
+				assets = append(assets, a)
+			}
+		case ScriptAsset:
+			for _, a := range p.Scripts() {
+				assets = append(assets, a)
+			}
+		case ResourceHintAsset:
+			for _, a := range p.ResourceHints() {
+				assets = append(assets, a)
+			}
+		case MediaAsset:
+			for _, a := range p.Media() {
+				assets = append(assets, a)
+			}
+		case FaviconAsset:
+			for _, a := range p.Favicons() {
+				assets = append(assets, a)
+			}
+		}
+	}
+
+	return assets
+}
+
+// ConnectionHints returns an array of every dns-prefetch and preconnect link
+// in the document.
+//
+// The origins are the third-party hosts the page expects to contact, which
+// crawlers may use to pre-warm connections or to discover dependencies.
+func (p *Page) ConnectionHints() []*ConnectionHint {
+	rc := p.ResolutionContext()
+	hints := make([]*ConnectionHint, 0, InitialAssetsSliceSize)
+	p.Find("link[rel]").Each(func(_ int, s *goquery.Selection) {
+		rel := matchRel(s, "dns-prefetch", "preconnect")
+		if rel == "" {
+			return
+		}
+		href, err := rc.resolveAttr("href", s)
+		if err == nil && href.Host != "" {
+			hints = append(hints, &ConnectionHint{
+				Rel:    rel,
+				Origin: &url.URL{Scheme: href.Scheme, Host: href.Host},
+			})
+		}
+	})
+
+	return hints
+}
+
+// Peek requests the given URL using the GET method, and returns the page
+// without replacing the current page or adding to the history.
+//
+// Cookies set by the response are stored as usual, and no events are fired.
+func (bow *Browser) Peek(u string) (*Page, error) {
+	req, err := bow.buildRequest("GET", u, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bow.peek(req)
+}
+
+// PeekPost requests the given URL using the POST method, and returns the page
+// without replacing the current page or adding to the history.
+func (bow *Browser) PeekPost(u string, contentType string, body io.Reader) (*Page, error) {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	req, err := bow.buildPOST(ur, nil, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	return bow.peek(req)
+}
+
+// peek sends the request and returns the page it responds with.
+func (bow *Browser) peek(req *http.Request) (*Page, error) {
+	state, err := bow.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{bow: bow, state: state}, nil
+}
+
+// nearestHeading returns the text of the heading containing the node, or of
+// the nearest heading before it in document order.
+func nearestHeading(n *html.Node) string {
+	for a := n; a != nil; a = a.Parent {
+		if isHeading(a) {
+			return nodeText(a)
+		}
+	}
+	for cur := n; cur != nil; cur = cur.Parent {
+		for sib := cur.PrevSibling; sib != nil; sib = sib.PrevSibling {
+			if h := lastHeading(sib); h != nil {
+				return nodeText(h)
+			}
+		}
+	}
+	return ""
+}
+
+// lastHeading returns the last heading in the subtree of the node, or nil
+// when there is none.
+func lastHeading(n *html.Node) *html.Node {
+	if n.Type != html.ElementNode {
+		return nil
+	}
+	if isHeading(n) {
+		return n
+	}
+	for c := n.LastChild; c != nil; c = c.PrevSibling {
+		if h := lastHeading(c); h != nil {
+			return h
+		}
+	}
+	return nil
+}
+
+// isHeading returns whether the node is a h1 to h6 element.
+func isHeading(n *html.Node) bool {
+	if n.Type != html.ElementNode || len(n.Data) != 2 || n.Data[0] != 'h' {
+		return false
+	}
+	return n.Data[1] >= '1' && n.Data[1] <= '6'
+}
+
+// nodeText returns the text of the node and its descendants.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookiePolicy controls which cookies are stored and sent for a site.
+type CookiePolicy int
+
+const (
+	// CookiesAccept stores and sends cookies as usual.
+	CookiesAccept CookiePolicy = iota
+
+	// CookiesReadOnly sends the stored cookies, but stores no new cookies.
+	CookiesReadOnly
+
+	// CookiesBlock neither stores nor sends cookies.
+	CookiesBlock
+)
+
+// Profile holds the settings applied to the requests sent to a site, in place
+// of the settings of the browser.
+type Profile struct {
+	// Headers are sent with each request, replacing the browser headers with
+	// the same names.
+	Headers http.Header
+
+	// UserAgent replaces the user agent of the browser when not empty.
+	UserAgent string
+
+	// Delay is the minimum time between the requests sent to the hosts
+	// matching the profile. Zero means no limit.
+	Delay time.Duration
+
+	// Proxy is the proxy requests are sent through, replacing the proxy of
+	// the browser when not nil. Credentials in its user info are sent to
+	// the proxy.
+	Proxy *url.URL
+
+	// Cookies controls which cookies are stored and sent.
+	Cookies CookiePolicy
+}
+
+// siteProfile is a profile registered for a host pattern.
+type siteProfile struct {
+	// pattern is the pattern of the host names the profile is applied to.
+	pattern string
+
+	// profile is the profile applied to the matching hosts.
+	profile Profile
+
+	// mutex protects next.
+	mutex sync.Mutex
+
+	// next is the earliest time the next request may be sent.
+	next time.Time
+}
+
+// SiteProfile applies the profile to the requests sent to the hosts matching
+// the pattern, so different sites get different settings from one browser.
+//
+// The pattern is matched against the host name, without the port, using the
+// syntax of path.Match, eg "*.example.com" matches the subdomains of
+// example.com but not example.com itself. When more than one pattern matches,
+// the profile registered first is applied. Registering a pattern again
+// replaces its profile.
+func (bow *Browser) SiteProfile(pattern string, p Profile) {
+	bow.profilesMutex.Lock()
+	defer bow.profilesMutex.Unlock()
+	pattern = strings.ToLower(pattern)
+	sp := &siteProfile{pattern: pattern, profile: p}
+	for i, prev := range bow.profiles {
+		if prev.pattern == pattern {
+			bow.profiles[i] = sp
+			return
+		}
+	}
+	bow.profiles = append(bow.profiles, sp)
+}
+
+// RemoveSiteProfile removes the profile registered for the pattern.
+func (bow *Browser) RemoveSiteProfile(pattern string) {
+	bow.profilesMutex.Lock()
+	defer bow.profilesMutex.Unlock()
+	pattern = strings.ToLower(pattern)
+	for i, sp := range bow.profiles {
+		if sp.pattern == pattern {
+			bow.profiles = append(bow.profiles[:i], bow.profiles[i+1:]...)
+			return
+		}
+	}
+}
+
+// siteProfile returns the profile applied to the URL, or nil when there is none.
+func (bow *Browser) siteProfile(u *url.URL) *siteProfile {
+	bow.profilesMutex.Lock()
+	defer bow.profilesMutex.Unlock()
+	host := strings.ToLower(u.Hostname())
+	for _, sp := range bow.profiles {
+		if ok, _ := path.Match(sp.pattern, host); ok {
+			return sp
+		}
+	}
+	return nil
+}
+
+// applyProfile sets the user agent and headers of the profile of the request.
+func (bow *Browser) applyProfile(req *http.Request) {
+	sp := bow.siteProfile(req.URL)
+	if sp == nil {
+		return
+	}
+	if sp.profile.UserAgent != "" {
+		req.Header.Set("User-Agent", sp.profile.UserAgent)
+	}
+	for name, values := range sp.profile.Headers {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+}
+
+// waitProfile waits until the delay of the profile of the request has passed
+// since the previous request to the site, or until the request is cancelled.
+func (bow *Browser) waitProfile(req *http.Request) error {
+	sp := bow.siteProfile(req.URL)
+	if sp == nil || sp.profile.Delay <= 0 {
+		return nil
+	}
+	sp.mutex.Lock()
+	now := time.Now()
+	at := sp.next
+	if at.Before(now) {
+		at = now
+	}
+	sp.next = at.Add(sp.profile.Delay)
+	sp.mutex.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+	return nil
+}
+
+// profileProxy returns the proxy function of the transport, which sends the
+// requests to sites with a proxy profile through that proxy, and the other
+// requests through the proxy returned by fallback.
+func (bow *Browser) profileProxy(fallback func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if sp := bow.siteProfile(req.URL); sp != nil && sp.profile.Proxy != nil {
+			return sp.profile.Proxy, nil
+		}
+		if fallback == nil {
+			return nil, nil
+		}
+		return fallback(req)
+	}
+}
+
+// cookiePolicy returns the cookie policy of the site of the URL.
+func (bow *Browser) cookiePolicy(u *url.URL) CookiePolicy {
+	if sp := bow.siteProfile(u); sp != nil {
+		return sp.profile.Cookies
+	}
+	return CookiesAccept
+}
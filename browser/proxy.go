@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// SetProxy sets the proxy every request is sent through. Passing nil restores
+// the default, which reads the proxy from the environment when the
+// ProxyFromEnvironment attribute is set.
+//
+// Credentials in the user info of the URL are used as if they were passed to
+// SetProxyCredentials.
+func (bow *Browser) SetProxy(u *url.URL) {
+	if u != nil && u.User != nil {
+		pass, _ := u.User.Password()
+		bow.proxyCredentials = &jar.Credentials{
+			Username: u.User.Username(),
+			Password: pass,
+		}
+		p := *u
+		p.User = nil
+		u = &p
+	}
+	bow.proxy = u
+	bow.proxyChallenged = false
+}
+
+// SetProxyCredentials sets the credentials sent to the proxy set by SetProxy.
+//
+// The credentials are sent with the CONNECT request opening HTTPS tunnels, and
+// with plain HTTP requests once the proxy has answered with 407 Proxy
+// Authentication Required. Passing nil stops sending credentials.
+func (bow *Browser) SetProxyCredentials(c *jar.Credentials) {
+	bow.proxyCredentials = c
+	bow.proxyChallenged = false
+}
+
+// proxyAuthorize sets the Proxy-Authorization header of plain HTTP requests
+// sent through a proxy which has challenged the browser, and removes it from
+// HTTPS requests, which would otherwise send it through the tunnel.
+func (bow *Browser) proxyAuthorize(req *http.Request) {
+	if bow.proxy == nil || bow.proxyCredentials == nil {
+		return
+	}
+	req.Header.Del("Proxy-Authorization")
+	if bow.proxyChallenged && req.URL.Scheme == "http" {
+		req.Header.Set("Proxy-Authorization", bow.proxyCredentials.Authorization())
+	}
+}
+
+// proxyChallengeRequest returns a copy of the request which received the 407
+// response, authorized using the proxy credentials.
+//
+// Returns nil when there are no proxy credentials, when they were already
+// sent, or when the request body cannot be sent again.
+func (bow *Browser) proxyChallengeRequest(resp *http.Response) *http.Request {
+	if bow.proxy == nil || bow.proxyCredentials == nil {
+		return nil
+	}
+	auth := bow.proxyCredentials.Authorization()
+	if resp.Request.Header.Get("Proxy-Authorization") == auth {
+		return nil
+	}
+	retry := replayRequest(resp.Request)
+	if retry == nil {
+		return nil
+	}
+	bow.proxyChallenged = true
+	retry.Header.Set("Proxy-Authorization", auth)
+	return retry
+}
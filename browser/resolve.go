@@ -0,0 +1,65 @@
+package browser
+
+import (
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// ResolutionContext resolves the relative URLs found in a document.
+//
+// Links, assets and form actions are resolved against the base URL of the
+// document which contains them, rather than the URL of the page.
+type ResolutionContext struct {
+	// URL is the URL of the document.
+	URL *url.URL
+
+	// Base is the URL relative URLs are resolved against. It's the href of
+	// the first base element in the document, resolved against URL, or URL
+	// when the document has no base element.
+	Base *url.URL
+}
+
+// NewResolutionContext creates and returns the resolution context of the
+// given document, which was found at u.
+func NewResolutionContext(u *url.URL, doc *goquery.Document) *ResolutionContext {
+	rc := &ResolutionContext{URL: u, Base: u}
+	if doc == nil {
+		return rc
+	}
+	href, ok := doc.Find("base[href]").First().Attr("href")
+	if !ok {
+		return rc
+	}
+	if bu, err := url.Parse(href); err == nil {
+		rc.Base = u.ResolveReference(bu)
+	}
+	return rc
+}
+
+// Resolve returns an absolute URL for a possibly relative URL.
+func (rc *ResolutionContext) Resolve(u *url.URL) *url.URL {
+	return rc.Base.ResolveReference(u)
+}
+
+// resolveAttr returns the value of the named attribute of the element,
+// resolved to an absolute URL.
+func (rc *ResolutionContext) resolveAttr(name string, sel *goquery.Selection) (*url.URL, error) {
+	src, ok := sel.Attr(name)
+	if !ok {
+		return nil, errors.NewAttributeNotFound(
+			"Attribute '%s' not found.", name)
+	}
+	ur, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.Resolve(ur), nil
+}
+
+// ResolutionContext returns the resolution context of the current page.
+func (bow *Browser) ResolutionContext() *ResolutionContext {
+	return NewResolutionContext(bow.Url(), bow.document())
+}
@@ -0,0 +1,47 @@
+package browser
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+	"syscall"
+)
+
+// doRetry sends the request through the middleware chain, and sends it once
+// more when a GET or HEAD request fails because the connection was reset or
+// closed before the response headers were received. Retrying is enabled by
+// the RetryTransportErrors attribute, which is set by default.
+func (bow *Browser) doRetry(req *http.Request) (*http.Response, error) {
+	resp, err := bow.do(req)
+	if err != nil && bow.mayRetry(req, err) {
+		resp, err = bow.do(req)
+	}
+	return resp, err
+}
+
+// mayRetry returns whether the failed request may be sent again.
+func (bow *Browser) mayRetry(req *http.Request, err error) bool {
+	if !bow.attributes[RetryTransportErrors] {
+		return false
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	return isTransportError(err)
+}
+
+// isTransportError returns whether the error was caused by the connection
+// being reset or closed, as opposed to a timeout or an unreachable host.
+func isTransportError(err error) bool {
+	return stderrors.Is(err, io.EOF) ||
+		stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNRESET) ||
+		stderrors.Is(err, syscall.ECONNABORTED) ||
+		stderrors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,339 @@
+package browser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// SanitizePolicy describes what is removed from a document when sanitizing.
+type SanitizePolicy struct {
+	// Elements are removed from the document along with their contents.
+	Elements []string
+
+	// Attributes are removed from every element.
+	Attributes []string
+
+	// EventHandlers removes every attribute beginning with "on", eg onclick.
+	EventHandlers bool
+
+	// JavaScriptURLs removes URL attributes, such as href, src, formaction,
+	// and srcset, using the javascript: scheme.
+	JavaScriptURLs bool
+
+	// URLSchemes are the schemes allowed in URL attributes. Attributes holding
+	// a URL with any other scheme are removed, while relative URLs are kept.
+	// Every scheme is allowed when empty.
+	URLSchemes []string
+}
+
+// DefaultSanitizePolicy removes scripts, styles, embedded content, event
+// handlers, and URLs using schemes other than http, https, and mailto.
+var DefaultSanitizePolicy = &SanitizePolicy{
+	Elements:       []string{"script", "style", "iframe", "object", "embed"},
+	Attributes:     []string{"style"},
+	EventHandlers:  true,
+	JavaScriptURLs: true,
+	URLSchemes:     []string{"http", "https", "mailto"},
+}
+
+// urlAttributes are the attributes whose value is a URL, or a list of URLs
+// separated by whitespace.
+var urlAttributes = map[string]bool{
+	"action": true, "archive": true, "background": true, "cite": true,
+	"codebase": true, "data": true, "dynsrc": true, "formaction": true,
+	"href": true, "icon": true, "longdesc": true, "lowsrc": true,
+	"manifest": true, "ping": true, "poster": true, "profile": true,
+	"src": true, "usemap": true, "xlink:href": true,
+}
+
+// Format describes the whitespace of a written document.
+type Format int
+
+const (
+	// FormatAsIs writes the document with its original whitespace.
+	FormatAsIs Format = iota
+
+	// FormatIndent writes each element on its own line, indented by depth,
+	// for diff friendly archives.
+	FormatIndent
+
+	// FormatMinify collapses whitespace and removes comments for compact storage.
+	FormatMinify
+)
+
+// DownloadOptions changes how the document is written by DownloadWithOptions().
+type DownloadOptions struct {
+	// Sanitize is the policy applied to a copy of the document before it's
+	// written. The document is written as-is when nil.
+	Sanitize *SanitizePolicy
+
+	// Format controls the whitespace of the written document.
+	Format Format
+
+	// Indent is the string written for each level of depth by FormatIndent.
+	// Defaults to two spaces.
+	Indent string
+}
+
+// DownloadWithOptions writes the contents of the document to the given writer
+// using the given options.
+//
+// The options are applied to a copy of the document, so the current page is
+// never modified.
+func (bow *Browser) DownloadWithOptions(o io.Writer, opts DownloadOptions) (int64, error) {
+	sel := bow.document().Selection
+	if opts.Sanitize != nil {
+		sel = sel.Clone()
+		opts.Sanitize.apply(sel)
+	}
+	if opts.Format == FormatAsIs {
+		h, err := sel.Html()
+		if err != nil {
+			return 0, err
+		}
+		l, err := io.WriteString(o, h)
+		return int64(l), err
+	}
+
+	f := &formatter{format: opts.Format, indent: opts.Indent}
+	if f.indent == "" {
+		f.indent = "  "
+	}
+	for _, n := range sel.Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := f.render(c, 0); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return f.buf.WriteTo(o)
+}
+
+// apply removes the elements and attributes described by the policy from the
+// selection.
+func (p *SanitizePolicy) apply(sel *goquery.Selection) {
+	if len(p.Elements) > 0 {
+		sel.Find(strings.Join(p.Elements, ",")).Remove()
+	}
+	for _, n := range sel.Nodes {
+		p.sanitizeNode(n)
+	}
+}
+
+// sanitizeNode removes the attributes described by the policy from the node
+// and its descendants.
+func (p *SanitizePolicy) sanitizeNode(n *html.Node) {
+	if n.Type == html.ElementNode {
+		attrs := n.Attr[:0]
+		for _, a := range n.Attr {
+			if !p.removesAttr(a) {
+				attrs = append(attrs, a)
+			}
+		}
+		n.Attr = attrs
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.sanitizeNode(c)
+	}
+}
+
+// removesAttr returns whether the policy removes the given attribute.
+func (p *SanitizePolicy) removesAttr(a html.Attribute) bool {
+	key := strings.ToLower(a.Key)
+	if a.Namespace != "" {
+		key = strings.ToLower(a.Namespace) + ":" + key
+	}
+	if p.EventHandlers && strings.HasPrefix(key, "on") {
+		return true
+	}
+	var urls []string
+	switch {
+	case key == "srcset" || key == "imagesrcset":
+		for _, candidate := range strings.Split(a.Val, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				urls = append(urls, fields[0])
+			}
+		}
+	case urlAttributes[key]:
+		urls = strings.Fields(a.Val)
+		if len(urls) == 0 {
+			urls = []string{a.Val}
+		}
+	}
+	for _, u := range urls {
+		if !p.allowsScheme(urlScheme(u)) {
+			return true
+		}
+	}
+	for _, name := range p.Attributes {
+		if key == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsScheme returns whether the policy keeps URLs using the scheme. The
+// scheme of relative URLs is empty.
+func (p *SanitizePolicy) allowsScheme(scheme string) bool {
+	if scheme == "" {
+		return true
+	}
+	if p.JavaScriptURLs && scheme == "javascript" {
+		return false
+	}
+	if len(p.URLSchemes) == 0 {
+		return true
+	}
+	for _, s := range p.URLSchemes {
+		if scheme == strings.ToLower(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlScheme returns the scheme of the URL in lower case, or an empty string
+// when the URL is relative. Whitespace and control characters are dropped
+// first, since browsers ignore them in URLs, eg "java\tscript:".
+func urlScheme(u string) string {
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	i := strings.IndexAny(u, ":/?#")
+	if i <= 0 || u[i] != ':' {
+		return ""
+	}
+	return strings.ToLower(u[:i])
+}
+
+// voidElements are the elements which never have an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// verbatimElements are the elements whose contents are written unchanged
+// because their whitespace is significant.
+var verbatimElements = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true,
+}
+
+// blockContainers are the elements in which whitespace between children is
+// never rendered, and may be dropped when minifying.
+var blockContainers = map[string]bool{
+	"html": true, "head": true, "ul": true, "ol": true, "table": true,
+	"thead": true, "tbody": true, "tfoot": true, "tr": true, "select": true,
+}
+
+// formatter writes nodes using the indent or minify format.
+type formatter struct {
+	buf    bytes.Buffer
+	format Format
+	indent string
+}
+
+// render writes the node and its descendants at the given depth.
+func (f *formatter) render(n *html.Node, depth int) error {
+	switch n.Type {
+	case html.TextNode:
+		f.text(n, depth)
+	case html.CommentNode:
+		if f.format == FormatIndent {
+			f.newline(depth)
+			f.buf.WriteString("<!--" + n.Data + "-->")
+		}
+	case html.ElementNode:
+		f.newline(depth)
+		if verbatimElements[n.Data] {
+			return html.Render(&f.buf, n)
+		}
+		f.startTag(n)
+		if voidElements[n.Data] {
+			return nil
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := f.render(c, depth+1); err != nil {
+				return err
+			}
+		}
+		if n.FirstChild != nil {
+			f.newline(depth)
+		}
+		f.buf.WriteString("</" + n.Data + ">")
+	default:
+		f.newline(depth)
+		return html.Render(&f.buf, n)
+	}
+	return nil
+}
+
+// text writes a text node with its whitespace collapsed.
+func (f *formatter) text(n *html.Node, depth int) {
+	collapsed := strings.Join(strings.Fields(n.Data), " ")
+	if f.format == FormatIndent {
+		if collapsed != "" {
+			f.newline(depth)
+			f.buf.WriteString(html.EscapeString(collapsed))
+		}
+		return
+	}
+	if collapsed == "" {
+		if n.Data == "" || (n.Parent != nil && blockContainers[n.Parent.Data]) {
+			return
+		}
+		f.space()
+		return
+	}
+	if strings.TrimLeft(n.Data, " \t\r\n\f") != n.Data {
+		f.space()
+	}
+	if strings.TrimRight(n.Data, " \t\r\n\f") != n.Data {
+		collapsed += " "
+	}
+	f.buf.WriteString(html.EscapeString(collapsed))
+}
+
+// space writes a single space unless the output already ends with one.
+func (f *formatter) space() {
+	b := f.buf.Bytes()
+	if len(b) == 0 || b[len(b)-1] != ' ' {
+		f.buf.WriteByte(' ')
+	}
+}
+
+// startTag writes the start tag of an element.
+func (f *formatter) startTag(n *html.Node) {
+	f.buf.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		f.buf.WriteString(" ")
+		if a.Namespace != "" {
+			f.buf.WriteString(a.Namespace + ":")
+		}
+		f.buf.WriteString(a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if voidElements[n.Data] {
+		f.buf.WriteString("/>")
+		return
+	}
+	f.buf.WriteString(">")
+}
+
+// newline starts a new indented line when using the indent format.
+func (f *formatter) newline(depth int) {
+	if f.format != FormatIndent {
+		return
+	}
+	if f.buf.Len() > 0 {
+		f.buf.WriteString("\n")
+	}
+	f.buf.WriteString(strings.Repeat(f.indent, depth))
+}
@@ -0,0 +1,120 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+)
+
+// Session is the configuration and session state of a browser.
+//
+// A session may be encoded using encoding/json or encoding/gob, which allows
+// a coordinator to ship the state of a browser to a worker process, and the
+// worker to carry on with the same cookies, headers, and settings.
+type Session struct {
+	// UserAgent is the User-Agent header value sent with requests.
+	UserAgent string `json:"user_agent"`
+
+	// Attributes are the browser attributes.
+	Attributes AttributeMap `json:"attributes"`
+
+	// Headers are the additional headers sent with each request.
+	Headers http.Header `json:"headers"`
+
+	// Cookies are the unexpired cookies stored by the browser.
+	Cookies []jar.CookieEntry `json:"cookies"`
+
+	// Bookmarks are the saved bookmarks.
+	Bookmarks jar.BookmarksMap `json:"bookmarks"`
+
+	// URL is the URL of the current page, or empty when no page was loaded.
+	// The page itself is not part of the session.
+	URL string `json:"url"`
+
+	// Proxy is the URL of the proxy every request is sent through.
+	Proxy string `json:"proxy"`
+
+	// ProxyCredentials are the credentials sent to the proxy.
+	ProxyCredentials *jar.Credentials `json:"proxy_credentials"`
+
+	// MethodOverride is how forms with a _method field are submitted.
+	MethodOverride MethodOverride `json:"method_override"`
+}
+
+// Session returns the configuration and session state of the browser.
+//
+// Returns an error when the cookie jar does not implement jar.CookieExporter,
+// because the session would be missing its cookies.
+func (bow *Browser) Session() (*Session, error) {
+	s := &Session{
+		UserAgent:        bow.userAgent,
+		Attributes:       make(AttributeMap, len(bow.attributes)),
+		Headers:          bow.headers.Clone(),
+		ProxyCredentials: bow.proxyCredentials,
+		MethodOverride:   bow.methodOverride,
+	}
+	for a, v := range bow.attributes {
+		s.Attributes[a] = v
+	}
+	if bow.cookies != nil {
+		ce, ok := bow.cookies.(jar.CookieExporter)
+		if !ok {
+			return nil, errors.New("The cookie jar cannot be exported.")
+		}
+		s.Cookies = ce.Export()
+	}
+	if bow.bookmarks != nil {
+		s.Bookmarks = make(jar.BookmarksMap)
+		for name, u := range bow.bookmarks.All() {
+			s.Bookmarks[name] = u
+		}
+	}
+	if bow.state() != nil {
+		s.URL = bow.Url().String()
+	}
+	if bow.proxy != nil {
+		s.Proxy = bow.proxy.String()
+	}
+	return s, nil
+}
+
+// RestoreSession applies the configuration and session state to the browser.
+//
+// The cookies and bookmarks are added to the jars already set, which are
+// usually empty in a new browser. The current page is not loaded; call
+// Open() with the URL of the session to carry on where it left off.
+func (bow *Browser) RestoreSession(s *Session) error {
+	if bow.cookies != nil {
+		if err := jar.ImportCookies(bow.cookies, s.Cookies); err != nil {
+			return err
+		}
+	}
+	if s.Proxy != "" {
+		u, err := url.Parse(s.Proxy)
+		if err != nil {
+			return err
+		}
+		bow.SetProxy(u)
+	}
+	if s.ProxyCredentials != nil {
+		bow.SetProxyCredentials(s.ProxyCredentials)
+	}
+	for name, u := range s.Bookmarks {
+		if bow.bookmarks != nil && !bow.bookmarks.Has(name) {
+			if err := bow.saveBookmark(name, u); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Attributes != nil {
+		bow.SetAttributes(s.Attributes)
+	}
+	if s.Headers != nil {
+		bow.SetHeadersJar(s.Headers)
+	}
+	bow.SetUserAgent(s.UserAgent)
+	bow.SetMethodOverride(s.MethodOverride)
+	return nil
+}
@@ -0,0 +1,73 @@
+package browser
+
+import (
+	"net/http"
+)
+
+// Signer signs requests before they are sent.
+//
+// Sign is called after every header has been set on the request, and may add
+// headers, such as Authorization, computed from the final request.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc is an adapter which allows using an ordinary function as a Signer.
+type SignerFunc func(req *http.Request) error
+
+// Sign calls f(req).
+func (f SignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+// SetSigner sets the signer used to sign each request made by the browser.
+// Passing nil disables signing.
+func (bow *Browser) SetSigner(s Signer) {
+	bow.signer = s
+}
+
+// send sends the request, and sends it again with the credentials for the
+// challenged realm when the server responds with 401 Unauthorized, or with
+// the proxy credentials when the proxy responds with 407.
+func (bow *Browser) send(req *http.Request) (*http.Response, error) {
+	resp, err := bow.doRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		if retry := bow.proxyChallengeRequest(resp); retry != nil {
+			resp.Body.Close()
+			if resp, err = bow.do(retry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		if retry := bow.challengeRequest(resp); retry != nil {
+			resp.Body.Close()
+			return bow.do(retry)
+		}
+	}
+	return resp, nil
+}
+
+// roundTrip signs the request and sends it using a new client. Proxy
+// credentials are added after signing, since they are not seen by the server.
+func (bow *Browser) roundTrip(req *http.Request) (*http.Response, error) {
+	if bow.signer != nil {
+		if err := bow.signer.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	bow.proxyAuthorize(req)
+	client := bow.buildClient()
+	if opts := requestOptions(req); opts != nil {
+		client.Timeout = opts.timeout()
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		bow.recordHSTS(resp)
+		bow.recordAltSvc(resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,90 @@
+package browser
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// SetSpoolThreshold sets the body size above which response bodies are kept
+// on disk instead of in memory.
+//
+// Bodies larger than n bytes are written to a temporary file in dir, or in the
+// default temporary directory when dir is empty. A file is removed when its
+// page leaves the history, eg by going back, and the remaining files are
+// removed by Close(), after which the raw bodies of retained pages can no
+// longer be read. Zero, the default, keeps every body in memory.
+func (bow *Browser) SetSpoolThreshold(n int64, dir string) {
+	bow.spoolThreshold = n
+	bow.spoolDir = dir
+}
+
+// readBody reads the response body into the state, spooling it to a file
+// when it's larger than the spool threshold.
+func (bow *Browser) readBody(state *jar.State, r io.Reader) error {
+	if bow.spoolThreshold <= 0 {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		state.Body = body
+		return nil
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0, 512))
+	n, err := io.CopyN(buff, r, bow.spoolThreshold+1)
+	if err == io.EOF || (err == nil && n <= bow.spoolThreshold) {
+		state.Body = buff.Bytes()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fout, err := ioutil.TempFile(bow.spoolDir, "surf-body-")
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+	if _, err = io.Copy(fout, io.MultiReader(buff, r)); err != nil {
+		fout.Close()
+		os.Remove(fout.Name())
+		return err
+	}
+	state.BodyFile = fout.Name()
+
+	bow.spoolMutex.Lock()
+	defer bow.spoolMutex.Unlock()
+	if bow.spooled == nil {
+		bow.spooled = make(map[string]bool)
+	}
+	bow.spooled[state.BodyFile] = true
+	return nil
+}
+
+// dropState removes the spooled body of a page which has been dropped from
+// the history.
+func (bow *Browser) dropState(s *jar.State) {
+	if s == nil || s.BodyFile == "" {
+		return
+	}
+	bow.spoolMutex.Lock()
+	defer bow.spoolMutex.Unlock()
+	if bow.spooled[s.BodyFile] {
+		delete(bow.spooled, s.BodyFile)
+		os.Remove(s.BodyFile)
+	}
+}
+
+// removeSpooled removes every spooled body.
+func (bow *Browser) removeSpooled() {
+	bow.spoolMutex.Lock()
+	defer bow.spoolMutex.Unlock()
+	for name := range bow.spooled {
+		os.Remove(name)
+	}
+	bow.spooled = nil
+}
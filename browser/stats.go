@@ -0,0 +1,195 @@
+package browser
+
+import (
+	"expvar"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Stats are the live counters of a browser.
+type Stats struct {
+	// Requests is the number of requests sent, including retries made by the
+	// browser and requests which failed.
+	Requests int64 `json:"requests"`
+
+	// InFlight is the number of requests waiting for a response.
+	InFlight int64 `json:"in_flight"`
+
+	// Pages is the number of pages loaded.
+	Pages int64 `json:"pages"`
+
+	// Errors is the number of requests which failed without a response.
+	Errors int64 `json:"errors"`
+
+	// ErrorRate is the fraction of requests which failed.
+	ErrorRate float64 `json:"error_rate"`
+
+	// CompressedBytes is the number of bytes of compressed pages received.
+	CompressedBytes int64 `json:"compressed_bytes"`
+
+	// DecompressedBytes is the number of bytes the compressed pages were
+	// decompressed to.
+	DecompressedBytes int64 `json:"decompressed_bytes"`
+}
+
+// DomainTraffic is the traffic exchanged with a registrable domain.
+type DomainTraffic struct {
+	// Requests is the number of requests sent to the domain.
+	Requests int64 `json:"requests"`
+
+	// Bytes is the number of response body bytes read from the domain, as
+	// they were received before being decompressed by the browser.
+	Bytes int64 `json:"bytes"`
+}
+
+// counters holds the live counters of a browser, which are updated atomically.
+type counters struct {
+	requests int64
+	inFlight int64
+	pages    int64
+	errors   int64
+
+	compressed   int64
+	decompressed int64
+
+	// trafficMutex guards traffic.
+	trafficMutex sync.Mutex
+
+	// traffic is the traffic keyed by registrable domain.
+	traffic map[string]*DomainTraffic
+}
+
+// Stats returns the current values of the counters of the browser.
+func (bow *Browser) Stats() Stats {
+	s := Stats{
+		Requests: atomic.LoadInt64(&bow.counters.requests),
+		InFlight: atomic.LoadInt64(&bow.counters.inFlight),
+		Pages:    atomic.LoadInt64(&bow.counters.pages),
+		Errors:   atomic.LoadInt64(&bow.counters.errors),
+
+		CompressedBytes:   atomic.LoadInt64(&bow.counters.compressed),
+		DecompressedBytes: atomic.LoadInt64(&bow.counters.decompressed),
+	}
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+	}
+	return s
+}
+
+// PublishStats publishes the counters of the browser with expvar under the
+// given name, so they are served by the /debug/vars handler.
+//
+// Like expvar.Publish, PublishStats panics when the name is already in use.
+func (bow *Browser) PublishStats(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return bow.Stats()
+	}))
+}
+
+// Traffic returns a copy of the traffic exchanged with each registrable
+// domain, eg "example.co.uk" for requests to "www.example.co.uk". Hosts
+// without a registrable domain, like IP addresses, are keyed by themselves.
+//
+// The map may be encoded as JSON to export the traffic, or used to enforce
+// bandwidth budgets per site.
+func (bow *Browser) Traffic() map[string]DomainTraffic {
+	bow.counters.trafficMutex.Lock()
+	defer bow.counters.trafficMutex.Unlock()
+	traffic := make(map[string]DomainTraffic, len(bow.counters.traffic))
+	for domain, t := range bow.counters.traffic {
+		traffic[domain] = *t
+	}
+	return traffic
+}
+
+// DomainTraffic returns the traffic exchanged with the registrable domain of
+// the given host.
+func (bow *Browser) DomainTraffic(host string) DomainTraffic {
+	bow.counters.trafficMutex.Lock()
+	defer bow.counters.trafficMutex.Unlock()
+	if t, ok := bow.counters.traffic[registrableDomain(host)]; ok {
+		return *t
+	}
+	return DomainTraffic{}
+}
+
+// ResetTraffic clears the traffic of every domain.
+func (bow *Browser) ResetTraffic() {
+	bow.counters.trafficMutex.Lock()
+	defer bow.counters.trafficMutex.Unlock()
+	bow.counters.traffic = nil
+}
+
+// domainTraffic returns the traffic of the registrable domain of the host,
+// creating it when needed. The caller must hold trafficMutex.
+func (c *counters) domainTraffic(host string) *DomainTraffic {
+	domain := registrableDomain(host)
+	if c.traffic == nil {
+		c.traffic = make(map[string]*DomainTraffic)
+	}
+	t, ok := c.traffic[domain]
+	if !ok {
+		t = &DomainTraffic{}
+		c.traffic[domain] = t
+	}
+	return t
+}
+
+// countRequest adds a request to the traffic of the host.
+func (c *counters) countRequest(host string) {
+	c.trafficMutex.Lock()
+	c.domainTraffic(host).Requests++
+	c.trafficMutex.Unlock()
+}
+
+// countCompression adds the sizes of a compressed page, before and after it
+// was decompressed.
+func (c *counters) countCompression(compressed, decompressed int64) {
+	atomic.AddInt64(&c.compressed, compressed)
+	atomic.AddInt64(&c.decompressed, decompressed)
+}
+
+// countBytes adds the bytes to the traffic of the host.
+func (c *counters) countBytes(host string, n int64) {
+	c.trafficMutex.Lock()
+	c.domainTraffic(host).Bytes += n
+	c.trafficMutex.Unlock()
+}
+
+// registrableDomain returns the registrable domain of the host, or the host
+// itself when it has none.
+func registrableDomain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// trafficBody counts the bytes read from a response body.
+type trafficBody struct {
+	io.ReadCloser
+	counters *counters
+	host     string
+}
+
+// Read reads from the body, and counts the bytes read.
+func (b *trafficBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.counters.countBytes(b.host, int64(n))
+	}
+	return n, err
+}
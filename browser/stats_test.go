@@ -0,0 +1,16 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	ut.Run(t)
+	ut.AssertEquals("example.co.uk", registrableDomain("www.Example.co.uk"))
+	ut.AssertEquals("example.com", registrableDomain("a.b.example.com:8080"))
+	ut.AssertEquals("127.0.0.1", registrableDomain("127.0.0.1:8080"))
+	ut.AssertEquals("::1", registrableDomain("[::1]:443"))
+	ut.AssertEquals("localhost", registrableDomain("localhost"))
+}
@@ -0,0 +1,61 @@
+package browser
+
+// IsSuccess returns whether the response status code is in the 2xx range.
+func (bow *Browser) IsSuccess() bool {
+	return isSuccess(bow.StatusCode())
+}
+
+// IsRedirect returns whether the response status code is in the 3xx range.
+func (bow *Browser) IsRedirect() bool {
+	return isRedirect(bow.StatusCode())
+}
+
+// IsClientError returns whether the response status code is in the 4xx range.
+func (bow *Browser) IsClientError() bool {
+	return isClientError(bow.StatusCode())
+}
+
+// IsServerError returns whether the response status code is in the 5xx range.
+func (bow *Browser) IsServerError() bool {
+	return isServerError(bow.StatusCode())
+}
+
+// IsSuccess returns whether the status code is in the 2xx range.
+func (hs *HeadState) IsSuccess() bool {
+	return isSuccess(hs.StatusCode)
+}
+
+// IsRedirect returns whether the status code is in the 3xx range.
+func (hs *HeadState) IsRedirect() bool {
+	return isRedirect(hs.StatusCode)
+}
+
+// IsClientError returns whether the status code is in the 4xx range.
+func (hs *HeadState) IsClientError() bool {
+	return isClientError(hs.StatusCode)
+}
+
+// IsServerError returns whether the status code is in the 5xx range.
+func (hs *HeadState) IsServerError() bool {
+	return isServerError(hs.StatusCode)
+}
+
+// isSuccess returns whether the status code is in the 2xx range.
+func isSuccess(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// isRedirect returns whether the status code is in the 3xx range.
+func isRedirect(code int) bool {
+	return code >= 300 && code < 400
+}
+
+// isClientError returns whether the status code is in the 4xx range.
+func isClientError(code int) bool {
+	return code >= 400 && code < 500
+}
+
+// isServerError returns whether the status code is in the 5xx range.
+func isServerError(code int) bool {
+	return code >= 500 && code < 600
+}
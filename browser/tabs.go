@@ -0,0 +1,103 @@
+package browser
+
+import (
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/jar"
+)
+
+// tab stores the pages of a browser tab.
+type tab struct {
+	// states stores the state of the current page of the tab.
+	states jar.StateJar
+
+	// history stores the pages visited in the tab.
+	history jar.History
+}
+
+// OpenInNewTab opens a new tab, makes it the active tab, and requests the
+// given URL in it using the GET method.
+//
+// Every tab shares the cookies, headers and settings of the browser, but has
+// its own page and history. The new tab remains open when the request fails.
+func (bow *Browser) OpenInNewTab(u string) error {
+	bow.saveTab()
+	bow.tabs = append(bow.tabs, &tab{
+		states:  jar.NewMemoryState(),
+		history: jar.NewMemoryHistory(),
+	})
+	bow.loadTab(len(bow.tabs) - 1)
+	return bow.Open(u)
+}
+
+// SwitchTab makes the tab with the given index the active tab.
+func (bow *Browser) SwitchTab(i int) error {
+	bow.saveTab()
+	if i < 0 || i >= len(bow.tabs) {
+		return errors.New("Tab %d does not exist.", i)
+	}
+	bow.loadTab(i)
+	return nil
+}
+
+// CloseTab closes the tab with the given index.
+//
+// The next tab becomes the active tab when the active tab is closed, or the
+// previous tab when it was the last one. The only open tab cannot be closed.
+// The spooled bodies of the pages of the closed tab are removed.
+func (bow *Browser) CloseTab(i int) error {
+	bow.saveTab()
+	if i < 0 || i >= len(bow.tabs) {
+		return errors.New("Tab %d does not exist.", i)
+	}
+	if len(bow.tabs) == 1 {
+		return errors.New("Cannot close the only open tab.")
+	}
+	closed := bow.tabs[i]
+	bow.tabs = append(bow.tabs[:i], bow.tabs[i+1:]...)
+	active := bow.activeTab
+	if active > i || active == len(bow.tabs) {
+		active--
+	}
+	bow.loadTab(active)
+	bow.dropState(closed.states.Current())
+	for closed.history.Len() > 0 {
+		bow.dropState(closed.history.Pop())
+	}
+	return nil
+}
+
+// TabCount returns the number of open tabs.
+func (bow *Browser) TabCount() int {
+	if len(bow.tabs) == 0 {
+		return 1
+	}
+	return len(bow.tabs)
+}
+
+// ActiveTab returns the index of the active tab.
+func (bow *Browser) ActiveTab() int {
+	return bow.activeTab
+}
+
+// saveTab stores the page and history of the active tab in the tab list,
+// creating the list with a single tab when needed.
+func (bow *Browser) saveTab() {
+	t := &tab{states: bow.states, history: bow.history}
+	if len(bow.tabs) == 0 {
+		bow.tabs = []*tab{t}
+		bow.activeTab = 0
+		return
+	}
+	bow.tabs[bow.activeTab] = t
+}
+
+// loadTab makes the tab with the given index the active tab. Pending meta
+// refreshes are cancelled, since they belong to the page being left.
+func (bow *Browser) loadTab(i int) {
+	if bow.refresh != nil {
+		bow.refresh.Stop()
+	}
+	bow.activeTab = i
+	bow.states = bow.tabs[i].states
+	bow.history = bow.tabs[i].history
+}
@@ -0,0 +1,67 @@
+package browser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// ExpandURL replaces each {name} variable in the URL template with its value
+// from vars, eg "https://example.com/user/{id}".
+//
+// Values are escaped for the part of the URL they appear in, so a value in the
+// path is escaped as a path segment, and a value in the query string is
+// escaped as a query value. Returns an error when a variable has no value.
+func ExpandURL(tmpl string, vars map[string]string) (string, error) {
+	var buf strings.Builder
+	query, fragment := false, false
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch c {
+		case '?':
+			query = !fragment
+		case '#':
+			query, fragment = false, true
+		case '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				break
+			}
+			name := tmpl[i+1 : i+end]
+			value, ok := vars[name]
+			if !ok {
+				return "", errors.New("Missing value for URL variable '%s'.", name)
+			}
+			if query {
+				buf.WriteString(url.QueryEscape(value))
+			} else {
+				buf.WriteString(url.PathEscape(value))
+			}
+			i += end
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), nil
+}
+
+// OpenTemplate expands the URL template with the given variables, and
+// requests the URL using the GET method.
+func (bow *Browser) OpenTemplate(tmpl string, vars map[string]string) error {
+	u, err := ExpandURL(tmpl, vars)
+	if err != nil {
+		return err
+	}
+	return bow.Open(u)
+}
+
+// OpenBookmarkTemplate expands the URL template saved as the bookmark with the
+// given name, and requests the URL using the GET method.
+func (bow *Browser) OpenBookmarkTemplate(name string, vars map[string]string) error {
+	tmpl, err := bow.bookmarks.Read(name)
+	if err != nil {
+		return err
+	}
+	return bow.OpenTemplate(tmpl, vars)
+}
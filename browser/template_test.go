@@ -0,0 +1,27 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestExpandURL(t *testing.T) {
+	ut.Run(t)
+	vars := map[string]string{"id": "42", "name": "joe smith/jr", "q": "a&b=c"}
+
+	u, err := ExpandURL("https://example.com/user/{id}", vars)
+	ut.AssertNil(err)
+	ut.AssertEquals("https://example.com/user/42", u)
+
+	u, err = ExpandURL("https://example.com/{name}?q={q}&id={id}#{name}", vars)
+	ut.AssertNil(err)
+	ut.AssertEquals("https://example.com/joe%20smith%2Fjr?q=a%26b%3Dc&id=42#joe%20smith%2Fjr", u)
+
+	u, err = ExpandURL("https://example.com/{id", vars)
+	ut.AssertNil(err)
+	ut.AssertEquals("https://example.com/{id", u)
+
+	_, err = ExpandURL("https://example.com/{missing}", vars)
+	ut.AssertNotNil(err)
+}
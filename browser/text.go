@@ -0,0 +1,123 @@
+package browser
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// ClickByText clicks the first link or submit button in the page whose
+// visible text equals the given text. Whitespace is trimmed and collapsed
+// before comparing, and the comparison is case sensitive.
+//
+// Links are followed like Click(), and buttons submit their form.
+func (bow *Browser) ClickByText(text string) error {
+	return bow.clickText(text, false)
+}
+
+// ClickByPartialText clicks the first link or submit button in the page whose
+// visible text contains the given text.
+func (bow *Browser) ClickByPartialText(text string) error {
+	return bow.clickText(text, true)
+}
+
+// ClickButtonByText submits the form by clicking the first submit button
+// whose visible text, or value for input buttons, equals the given text.
+func (f *Form) ClickButtonByText(text string) error {
+	return f.clickText(text, false)
+}
+
+// ClickButtonByPartialText submits the form by clicking the first submit
+// button whose visible text contains the given text.
+func (f *Form) ClickButtonByPartialText(text string) error {
+	return f.clickText(text, true)
+}
+
+// clickText clicks the first link or button matching the text.
+func (bow *Browser) clickText(text string, partial bool) error {
+	sel := findByText(bow.Find("a[href],"+submitButtons), text, partial)
+	if sel.Length() == 0 {
+		return errors.NewElementNotFound(
+			"No link or button found with text '%s'.", text)
+	}
+	if sel.Is("a") {
+		href, err := bow.ResolutionContext().resolveAttr("href", sel)
+		if err != nil {
+			return err
+		}
+		return bow.httpGET(href, bow.Url(), TriggerClick)
+	}
+
+	form := sel.Closest("form")
+	if form.Length() == 0 {
+		return errors.NewElementNotFound(
+			"Button with text '%s' does not belong to a form.", text)
+	}
+	return NewForm(bow, form).clickButton(sel)
+}
+
+// clickText submits the form using the first button matching the text.
+func (f *Form) clickText(text string, partial bool) error {
+	sel := findByText(f.selection.Find(submitButtons), text, partial)
+	if sel.Length() == 0 {
+		return errors.NewElementNotFound(
+			"No button found with text '%s'.", text)
+	}
+	return f.clickButton(sel)
+}
+
+// clickButton submits the form using the given button.
+func (f *Form) clickButton(sel *goquery.Selection) error {
+	name := sel.AttrOr("name", "")
+	if name == "" {
+		return f.send("", "")
+	}
+	return f.send(name, sel.AttrOr("value", ""))
+}
+
+// submitButtons selects the elements which submit a form.
+const submitButtons = "input[type=submit],button:not([type]),button[type=submit]"
+
+// FindByText returns the elements matching the selector whose visible text
+// equals the given text, after trimming and collapsing whitespace.
+func (bow *Browser) FindByText(selector, text string) *goquery.Selection {
+	return filterText(bow.Find(selector), text, false)
+}
+
+// FindByAttr returns the elements matching the selector whose attr attribute
+// equals the given value.
+func (bow *Browser) FindByAttr(selector, attr, value string) *goquery.Selection {
+	return bow.Find(selector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		v, ok := s.Attr(attr)
+		return ok && v == value
+	})
+}
+
+// findByText returns the first element of the selection whose visible text
+// equals, or contains when partial is true, the given text.
+func findByText(sel *goquery.Selection, text string, partial bool) *goquery.Selection {
+	return filterText(sel, text, partial).First()
+}
+
+// filterText returns the elements of the selection whose visible text, or
+// value for inputs, equals or contains the given text.
+func filterText(sel *goquery.Selection, text string, partial bool) *goquery.Selection {
+	text = normalizeText(text)
+	return sel.FilterFunction(func(_ int, s *goquery.Selection) bool {
+		t := normalizeText(s.Text())
+		if goquery.NodeName(s) == "input" {
+			t = normalizeText(s.AttrOr("value", ""))
+		}
+		if partial {
+			return strings.Contains(t, text)
+		}
+		return t == text
+	})
+}
+
+// normalizeText trims the text, and collapses runs of whitespace into a
+// single space.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
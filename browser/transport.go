@@ -0,0 +1,149 @@
+package browser
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SetMaxIdleConns sets the maximum number of idle connections kept across all hosts.
+//
+// Zero means no limit. Large crawls touching many hosts may want to lower this
+// to reduce the number of open sockets.
+func (bow *Browser) SetMaxIdleConns(n int) {
+	bow.setTransport(func(t *http.Transport) { t.MaxIdleConns = n })
+}
+
+// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept per host.
+//
+// Zero means http.DefaultMaxIdleConnsPerHost. Raising this value increases
+// throughput when many requests are sent to the same host.
+func (bow *Browser) SetMaxIdleConnsPerHost(n int) {
+	bow.setTransport(func(t *http.Transport) { t.MaxIdleConnsPerHost = n })
+}
+
+// SetIdleConnTimeout sets how long an idle connection is kept before closing.
+//
+// Zero means idle connections are never closed because of their age.
+func (bow *Browser) SetIdleConnTimeout(d time.Duration) {
+	bow.setTransport(func(t *http.Transport) { t.IdleConnTimeout = d })
+}
+
+// setTransport changes a setting of the transport while holding the
+// transport lock, so it doesn't race with the other settings.
+func (bow *Browser) setTransport(set func(t *http.Transport)) {
+	t := bow.buildTransport()
+	bow.transportMutex.Lock()
+	defer bow.transportMutex.Unlock()
+	set(t)
+}
+
+// SetDialTLSContext sets the function used to open TLS connections for HTTPS
+// requests which are not sent through a proxy.
+//
+// The fingerprint package provides functions which present the TLS
+// fingerprint of a well known browser. Passing nil restores the default.
+func (bow *Browser) SetDialTLSContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	bow.transportMutex.Lock()
+	bow.dialTLS = dial
+	bow.transportMutex.Unlock()
+	bow.buildTransport()
+}
+
+// buildTransport returns the browser transport, creating it when needed.
+// The DisableKeepAlives and ProxyFromEnvironment attributes, the proxy, the
+// proxies of the site profiles, the header order and the TLS dial function
+// are applied each time it's called. The
+// fields are only written when the settings change, so that requests sent
+// from other goroutines, eg by FetchLocales(), don't race with it. Proxies
+// are taken from the environment unless the ProxyFromEnvironment attribute is
+// set to false, as they are by the http package.
+func (bow *Browser) buildTransport() *http.Transport {
+	bow.transportMutex.Lock()
+	defer bow.transportMutex.Unlock()
+	created := bow.transport == nil
+	if created {
+		bow.transport = http.DefaultTransport.(*http.Transport).Clone()
+		bow.transport.DialContext = bow.orderedDialer(bow.altSvcDialer(bow.transport.DialContext))
+	}
+	if dial := len(bow.headerOrder) > 0 || bow.dialTLS != nil; dial != (bow.transport.DialTLSContext != nil) {
+		bow.transport.DialTLSContext = nil
+		if dial {
+			bow.transport.DialTLSContext = bow.dialTLSContext
+		}
+	}
+	if disable := bow.attributes[DisableKeepAlives]; bow.transport.DisableKeepAlives != disable {
+		bow.transport.DisableKeepAlives = disable
+	}
+
+	environment, ok := bow.attributes[ProxyFromEnvironment]
+	bow.profilesMutex.Lock()
+	settings := proxySettings{
+		proxy:       bow.proxy,
+		environment: environment || !ok,
+		profiles:    len(bow.profiles) > 0,
+	}
+	bow.profilesMutex.Unlock()
+	if created || settings != bow.proxySettings {
+		bow.proxySettings = settings
+		switch {
+		case settings.proxy != nil:
+			bow.transport.Proxy = http.ProxyURL(settings.proxy)
+		case settings.environment:
+			bow.transport.Proxy = http.ProxyFromEnvironment
+		default:
+			bow.transport.Proxy = nil
+		}
+		if settings.profiles {
+			bow.transport.Proxy = bow.profileProxy(bow.transport.Proxy)
+		}
+	}
+
+	auth := ""
+	if bow.proxy != nil && bow.proxyCredentials != nil {
+		auth = bow.proxyCredentials.Authorization()
+	}
+	if bow.transport.ProxyConnectHeader.Get("Proxy-Authorization") != auth {
+		bow.transport.ProxyConnectHeader = nil
+		if auth != "" {
+			bow.transport.ProxyConnectHeader = http.Header{"Proxy-Authorization": {auth}}
+		}
+	}
+	return bow.transport
+}
+
+// proxySettings are the settings the proxy of the transport was chosen by.
+type proxySettings struct {
+	// proxy is the proxy of the browser.
+	proxy *url.URL
+
+	// environment is the value of the ProxyFromEnvironment attribute.
+	environment bool
+
+	// profiles is whether site profiles have been registered.
+	profiles bool
+}
+
+// SetExpectContinueThreshold sets the body size at which uploads send Expect: 100-continue.
+//
+// Requests with a body of at least n bytes, or with a body of unknown length,
+// wait for the server to accept the headers before transmitting the body. This
+// lets servers reject unauthorized uploads without receiving them. Zero, the
+// default, never sends the header.
+func (bow *Browser) SetExpectContinueThreshold(n int64) {
+	bow.expectContinueThreshold = n
+}
+
+// shouldExpectContinue returns whether the Expect: 100-continue header should
+// be sent with the given request.
+func (bow *Browser) shouldExpectContinue(req *http.Request) bool {
+	if bow.expectContinueThreshold <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	if req.ContentLength <= 0 {
+		return true
+	}
+	return req.ContentLength >= bow.expectContinueThreshold
+}
@@ -0,0 +1,178 @@
+package browser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/errors"
+)
+
+// emailPattern matches a valid e-mail address, as defined by the HTML standard.
+var emailPattern = regexp.MustCompile(
+	"^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?" +
+		"(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+// Validate checks the values of the form fields against their required,
+// pattern, minlength, maxlength, min, and max attributes, and the email, url,
+// and number input types, the way a browser does before submitting.
+//
+// Returns an errors.Validation listing every field which failed, or nil when
+// the form is valid. Disabled fields are not validated.
+func (f *Form) Validate() error {
+	var violations []errors.FieldViolation
+	checked := map[string]bool{}
+	f.selection.Find("input,select,textarea").Each(func(_ int, s *goquery.Selection) {
+		name, ok := s.Attr("name")
+		if !ok || name == "" {
+			return
+		}
+		if _, disabled := s.Attr("disabled"); disabled {
+			return
+		}
+		typ := strings.ToLower(s.AttrOr("type", "text"))
+		if goquery.NodeName(s) != "input" {
+			typ = goquery.NodeName(s)
+		}
+		switch typ {
+		case "hidden", "submit", "button", "reset", "image":
+			return
+		case "checkbox", "radio":
+			// A group is checked once, no matter how many of its inputs are required.
+			if _, required := s.Attr("required"); required && !checked[name] {
+				checked[name] = true
+				if len(f.fields[name]) == 0 {
+					violations = append(violations, violation(name, "required", "Please select an option."))
+				}
+			}
+			return
+		case "file":
+			if _, required := s.Attr("required"); required {
+				if len(f.files[name]) == 0 {
+					violations = append(violations, violation(name, "required", "Please select a file."))
+				}
+			}
+			return
+		}
+		if !f.definedFields[name] {
+			return
+		}
+		if v, ok := validateField(s, typ, name, f.fields.Get(name)); !ok {
+			violations = append(violations, v)
+		}
+	})
+	if len(violations) > 0 {
+		return errors.NewValidation(violations)
+	}
+	return nil
+}
+
+// validateField returns the first constraint the value of the field fails,
+// and whether the value is valid.
+func validateField(s *goquery.Selection, typ, name, value string) (errors.FieldViolation, bool) {
+	if value == "" {
+		if _, required := s.Attr("required"); required {
+			return violation(name, "required", "Please fill out this field."), false
+		}
+		return errors.FieldViolation{}, true
+	}
+
+	if n, ok := intAttr(s, "minlength"); ok && utf8.RuneCountInString(value) < n {
+		return violation(name, "minlength", fmt.Sprintf("Please use at least %d characters.", n)), false
+	}
+	if n, ok := intAttr(s, "maxlength"); ok && utf8.RuneCountInString(value) > n {
+		return violation(name, "maxlength", fmt.Sprintf("Please use at most %d characters.", n)), false
+	}
+	if pattern, ok := s.Attr("pattern"); ok && typ != "select" && typ != "textarea" {
+		// Patterns which are not valid in Go are ignored, like browsers
+		// ignore invalid patterns.
+		if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil && !re.MatchString(value) {
+			return violation(name, "pattern", "Please match the requested format."), false
+		}
+	}
+
+	switch typ {
+	case "email":
+		addresses := []string{value}
+		if _, multiple := s.Attr("multiple"); multiple {
+			addresses = strings.Split(value, ",")
+		}
+		for _, a := range addresses {
+			if !emailPattern.MatchString(strings.TrimSpace(a)) {
+				return violation(name, "type", "Please enter an email address."), false
+			}
+		}
+	case "url":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			return violation(name, "type", "Please enter a URL."), false
+		}
+	case "number", "range":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return violation(name, "type", "Please enter a number."), false
+		}
+		if min, ok := floatAttr(s, "min"); ok && n < min {
+			return violation(name, "min", fmt.Sprintf("Value must be greater than or equal to %v.", min)), false
+		}
+		if max, ok := floatAttr(s, "max"); ok && n > max {
+			return violation(name, "max", fmt.Sprintf("Value must be less than or equal to %v.", max)), false
+		}
+	}
+	return errors.FieldViolation{}, true
+}
+
+// violation creates and returns an errors.FieldViolation type.
+func violation(name, constraint, msg string) errors.FieldViolation {
+	return errors.FieldViolation{Field: name, Constraint: constraint, Message: msg}
+}
+
+// intAttr returns the value of the attribute as an int, and whether the
+// attribute is set to a valid, non-negative number.
+func intAttr(s *goquery.Selection, name string) (int, bool) {
+	v, ok := s.Attr(name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	return n, err == nil && n >= 0
+}
+
+// floatAttr returns the value of the attribute as a float64, and whether the
+// attribute is set to a valid number.
+func floatAttr(s *goquery.Selection, name string) (float64, bool) {
+	v, ok := s.Attr(name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	return n, err == nil
+}
+
+// shouldValidate returns whether the form is validated before being submitted
+// using the given button, which is when the browser has the ValidateForms
+// attribute set, and neither the form nor the button turn validation off.
+func (f *Form) shouldValidate(buttonName string) bool {
+	bow, ok := f.bow.(*Browser)
+	if !ok || !bow.attributes[ValidateForms] {
+		return false
+	}
+	if _, ok := f.selection.Attr("novalidate"); ok {
+		return false
+	}
+	if buttonName != "" {
+		found := false
+		f.selection.Find("input[type=submit],button").Each(func(_ int, s *goquery.Selection) {
+			if s.AttrOr("name", "") == buttonName {
+				if _, ok := s.Attr("formnovalidate"); ok {
+					found = true
+				}
+			}
+		})
+		return !found
+	}
+	return true
+}
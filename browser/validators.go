@@ -0,0 +1,63 @@
+package browser
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/haruyama/surf/jar"
+)
+
+// SetValidatorJar sets the jar storing the ETag and Last-Modified validators
+// of visited pages.
+func (bow *Browser) SetValidatorJar(vj jar.ValidatorJar) {
+	bow.validators = vj
+}
+
+// ValidatorJar returns the jar storing the validators of visited pages.
+func (bow *Browser) ValidatorJar() jar.ValidatorJar {
+	return bow.validators
+}
+
+// NotModified returns whether the server responded to the last request with
+// 304 Not Modified, in which case the current page was left unchanged.
+func (bow *Browser) NotModified() bool {
+	return bow.notModified
+}
+
+// conditional adds the If-None-Match and If-Modified-Since headers to the
+// request when the ConditionalRequests attribute is set, and the validator of
+// the URL is known. Requests sent with Cache-Control: no-cache are never made
+// conditional. Returns whether a header was added.
+func (bow *Browser) conditional(req *http.Request) bool {
+	if !bow.attributes[ConditionalRequests] || bow.validators == nil || req.Method != "GET" {
+		return false
+	}
+	if strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		return false
+	}
+	v, ok := bow.validators.Read(req.URL.String())
+	if !ok {
+		return false
+	}
+	added := false
+	if v.ETag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", v.ETag)
+		added = true
+	}
+	if v.LastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+		added = true
+	}
+	return added
+}
+
+// saveValidator stores the validators of a successful GET response.
+func (bow *Browser) saveValidator(req *http.Request, resp *http.Response) {
+	if bow.validators == nil || req.Method != "GET" || resp.StatusCode != http.StatusOK {
+		return
+	}
+	bow.validators.Save(req.URL.String(), jar.Validator{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+}
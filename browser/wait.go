@@ -0,0 +1,37 @@
+package browser
+
+import (
+	"time"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// WaitFor reloads the current page every pollInterval until an element
+// matching the selector appears on it, for server-rendered pages which show
+// a "processing..." placeholder until the result is ready.
+//
+// The page is reloaded like Reload(). Returns nil at once when the element is
+// already on the page, and an errors.WaitTimeout when it has not appeared
+// before the timeout. An error reloading the page ends the wait, and is
+// returned.
+func (bow *Browser) WaitFor(selector string, timeout, pollInterval time.Duration) error {
+	if bow.state() == nil || bow.state().Request == nil {
+		return errors.NewPageNotLoaded("Cannot wait, no page has been loaded.")
+	}
+	if pollInterval <= 0 {
+		return errors.New("Poll interval must be positive.")
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if bow.Find(selector).Length() > 0 {
+			return nil
+		}
+		if time.Now().Add(pollInterval).After(deadline) {
+			return errors.NewWaitTimeout(bow.Url().String(), selector, timeout)
+		}
+		time.Sleep(pollInterval)
+		if err := bow.Reload(); err != nil {
+			return err
+		}
+	}
+}
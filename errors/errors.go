@@ -4,6 +4,8 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Error represents any generic error.
@@ -110,3 +112,194 @@ func NewInvalidFormValue(msg string, a ...interface{}) InvalidFormValue {
 		error: errors.New(msg),
 	}
 }
+
+// ChecksumMismatch represents a download whose digest does not match the
+// expected digest.
+type ChecksumMismatch struct {
+	error
+
+	// Expected is the expected digest, hex encoded.
+	Expected string
+
+	// Actual is the digest of the downloaded data, hex encoded.
+	Actual string
+}
+
+// NewChecksumMismatch creates and returns a ChecksumMismatch type.
+func NewChecksumMismatch(expected, actual string) ChecksumMismatch {
+	msg := fmt.Sprintf("Checksum Mismatch: expected '%s', got '%s'.", expected, actual)
+	return ChecksumMismatch{
+		error:    errors.New(msg),
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+// HandlerPanic represents an event handler which panicked.
+type HandlerPanic struct {
+	error
+
+	// Value is the value passed to panic().
+	Value interface{}
+}
+
+// NewHandlerPanic creates and returns a HandlerPanic type.
+func NewHandlerPanic(v interface{}) HandlerPanic {
+	msg := fmt.Sprintf("Handler Panic: %v", v)
+	return HandlerPanic{
+		error: errors.New(msg),
+		Value: v,
+	}
+}
+
+// FieldViolation describes a form field which failed validation.
+type FieldViolation struct {
+	// Field is the name of the field.
+	Field string
+
+	// Constraint is the constraint which failed, eg "required", "pattern",
+	// "minlength", "maxlength", "min", "max", or "type".
+	Constraint string
+
+	// Message describes the failure.
+	Message string
+}
+
+// Validation represents a form which failed client-side validation.
+type Validation struct {
+	error
+
+	// Fields are the fields which failed validation, in document order.
+	Fields []FieldViolation
+}
+
+// NewValidation creates and returns a Validation type.
+func NewValidation(fields []FieldViolation) Validation {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = fmt.Sprintf("%s (%s)", f.Field, f.Constraint)
+	}
+	msg := fmt.Sprintf("Validation Failed: %s", strings.Join(names, ", "))
+	return Validation{
+		error:  errors.New(msg),
+		Fields: fields,
+	}
+}
+
+// NoMatchingLink represents a failed attempt to follow a link matching a
+// pattern, because no link in the page matched.
+type NoMatchingLink struct {
+	error
+
+	// Candidates are the URLs of the links which came closest to matching,
+	// closest first.
+	Candidates []string
+}
+
+// NewNoMatchingLink creates and returns a NoMatchingLink type.
+func NewNoMatchingLink(matcher string, candidates []string) NoMatchingLink {
+	msg := fmt.Sprintf("Link Not Found: no link matches %s", matcher)
+	if len(candidates) > 0 {
+		msg += fmt.Sprintf("; candidates: %s", strings.Join(candidates, ", "))
+	}
+	return NoMatchingLink{
+		error:      errors.New(msg),
+		Candidates: candidates,
+	}
+}
+
+// PageLoadTimeout represents a page which took longer to load than the page
+// load timeout of the browser.
+type PageLoadTimeout struct {
+	error
+
+	// URL is the URL of the page.
+	URL string
+
+	// Timeout is the time allowed to load the page.
+	Timeout time.Duration
+}
+
+// NewPageLoadTimeout creates and returns a PageLoadTimeout type.
+func NewPageLoadTimeout(url string, timeout time.Duration) PageLoadTimeout {
+	msg := fmt.Sprintf("Page Load Timeout: '%s' did not load within %s.", url, timeout)
+	return PageLoadTimeout{
+		error:   errors.New(msg),
+		URL:     url,
+		Timeout: timeout,
+	}
+}
+
+// TruncatedResponse represents a response body which is shorter than its
+// Content-Length header.
+type TruncatedResponse struct {
+	error
+
+	// URL is the URL of the response.
+	URL string
+
+	// Expected is the length given by the Content-Length header.
+	Expected int64
+
+	// Received is the number of bytes received.
+	Received int64
+}
+
+// NewTruncatedResponse creates and returns a TruncatedResponse type.
+func NewTruncatedResponse(url string, expected, received int64) TruncatedResponse {
+	msg := fmt.Sprintf("Truncated Response: '%s' sent %d of %d bytes.", url, received, expected)
+	return TruncatedResponse{
+		error:    errors.New(msg),
+		URL:      url,
+		Expected: expected,
+		Received: received,
+	}
+}
+
+// Challenge represents a page which is still an anti-bot challenge after the
+// challenge solver of the browser was called.
+type Challenge struct {
+	error
+
+	// URL is the URL of the page.
+	URL string
+
+	// Signature is the name of the signature which matched the page.
+	Signature string
+}
+
+// NewChallenge creates and returns a Challenge type.
+func NewChallenge(url, signature string) Challenge {
+	msg := fmt.Sprintf("Challenge: '%s' is still a %s challenge page.", url, signature)
+	return Challenge{
+		error:     errors.New(msg),
+		URL:       url,
+		Signature: signature,
+	}
+}
+
+// WaitTimeout represents an element which did not appear on a page before
+// the time allowed to wait for it ran out.
+type WaitTimeout struct {
+	error
+
+	// URL is the URL of the page.
+	URL string
+
+	// Selector is the selector of the element.
+	Selector string
+
+	// Timeout is the time allowed to wait for the element.
+	Timeout time.Duration
+}
+
+// NewWaitTimeout creates and returns a WaitTimeout type.
+func NewWaitTimeout(url, selector string, timeout time.Duration) WaitTimeout {
+	msg := fmt.Sprintf("Wait Timeout: '%s' did not appear on '%s' within %s.", selector, url, timeout)
+	return WaitTimeout{
+		error:    errors.New(msg),
+		URL:      url,
+		Selector: selector,
+		Timeout:  timeout,
+	}
+}
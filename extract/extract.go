@@ -0,0 +1,252 @@
+// Package extract runs declarative extraction pipelines against the pages
+// loaded by a browser.
+//
+// A pipeline is made of steps, each selecting elements and transforming them
+// into a field of a record. Records are emitted to a sink, such as a channel
+// or a JSON Lines writer:
+//
+//	p := extract.NewPipeline(extract.JSONLSink(os.Stdout)).
+//		Scope("article").
+//		Field("title", "h2", extract.Text).
+//		Field("link", "a", extract.URL("href")).
+//		List("tags", ".tag", extract.Text)
+//	bow := surf.NewBrowser()
+//	p.Attach(bow)
+package extract
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/browser"
+	"github.com/haruyama/surf/errors"
+)
+
+// Record holds the fields extracted from a page, or from a scoped element of
+// the page, keyed by the names of the steps.
+type Record map[string]interface{}
+
+// Transform turns the elements selected by a step into the value of a field.
+type Transform func(p *browser.Page, sel *goquery.Selection) (interface{}, error)
+
+// Text is a Transform returning the trimmed text of the selection.
+func Text(_ *browser.Page, sel *goquery.Selection) (interface{}, error) {
+	return strings.TrimSpace(sel.Text()), nil
+}
+
+// HTML is a Transform returning the inner html of the selection.
+func HTML(_ *browser.Page, sel *goquery.Selection) (interface{}, error) {
+	return sel.Html()
+}
+
+// Attr returns a Transform returning the value of the named attribute of the
+// selection, or nil when the attribute is missing.
+func Attr(name string) Transform {
+	return func(_ *browser.Page, sel *goquery.Selection) (interface{}, error) {
+		if v, ok := sel.Attr(name); ok {
+			return v, nil
+		}
+		return nil, nil
+	}
+}
+
+// URL returns a Transform returning the value of the named attribute of the
+// selection as an absolute URL, resolved against the page.
+func URL(name string) Transform {
+	return func(p *browser.Page, sel *goquery.Selection) (interface{}, error) {
+		v, ok := sel.Attr(name)
+		if !ok {
+			return nil, nil
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return p.ResolveUrl(u).String(), nil
+	}
+}
+
+// Map returns a Transform which passes the value returned by t through f,
+// eg to parse a price. Nil values are not passed to f.
+func Map(t Transform, f func(v interface{}) (interface{}, error)) Transform {
+	return func(p *browser.Page, sel *goquery.Selection) (interface{}, error) {
+		v, err := t(p, sel)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return f(v)
+	}
+}
+
+// PageURL is a Transform returning the URL of the page.
+func PageURL(p *browser.Page, _ *goquery.Selection) (interface{}, error) {
+	return p.Url().String(), nil
+}
+
+// StatusCode is a Transform returning the response status code of the page.
+func StatusCode(p *browser.Page, _ *goquery.Selection) (interface{}, error) {
+	return p.StatusCode(), nil
+}
+
+// ContentType is a Transform returning the Content-Type of the page.
+func ContentType(p *browser.Page, _ *goquery.Selection) (interface{}, error) {
+	return p.ResponseHeaders().Get("Content-Type"), nil
+}
+
+// SummaryColumns are the fields of the records emitted by a summary pipeline.
+var SummaryColumns = []string{"url", "status", "content_type", "title"}
+
+// NewSummaryPipeline creates and returns a new *Pipeline type which emits a
+// summary of each page to the sink, made of the SummaryColumns fields.
+func NewSummaryPipeline(sink Sink) *Pipeline {
+	return NewPipeline(sink).
+		Field("url", "", PageURL).
+		Field("status", "", StatusCode).
+		Field("content_type", "", ContentType).
+		Field("title", "title", Text)
+}
+
+// step is a single selector and transform of a pipeline.
+type step struct {
+	name      string
+	selector  string
+	transform Transform
+	all       bool
+}
+
+// Pipeline extracts records from pages and emits them to a sink.
+type Pipeline struct {
+	// sink receives the extracted records.
+	sink Sink
+
+	// scope is the selector of the elements each record is extracted from.
+	scope string
+
+	// match limits the pages the pipeline runs against by URL.
+	match *regexp.Regexp
+
+	// steps are the fields of each record.
+	steps []step
+
+	// errors are the errors of the pipelines run by attached browsers.
+	errors []error
+
+	// errorsMutex protects errors.
+	errorsMutex sync.Mutex
+}
+
+// NewPipeline creates and returns a new *Pipeline type which emits records to
+// the given sink.
+func NewPipeline(sink Sink) *Pipeline {
+	return &Pipeline{sink: sink}
+}
+
+// Scope sets the selector of the elements records are extracted from, so one
+// record is emitted for each matching element, and the selectors of the steps
+// are relative to the element. By default one record is emitted per page.
+func (p *Pipeline) Scope(selector string) *Pipeline {
+	p.scope = selector
+	return p
+}
+
+// Match limits the pipeline to the pages whose URL matches the expression.
+func (p *Pipeline) Match(re *regexp.Regexp) *Pipeline {
+	p.match = re
+	return p
+}
+
+// Field adds a step which sets the named field to the value transformed from
+// the first element matching the selector. The field is nil when no elements
+// match. An empty selector selects the scoped element itself.
+func (p *Pipeline) Field(name, selector string, t Transform) *Pipeline {
+	p.steps = append(p.steps, step{name: name, selector: selector, transform: t})
+	return p
+}
+
+// List adds a step which sets the named field to the values transformed from
+// each element matching the selector.
+func (p *Pipeline) List(name, selector string, t Transform) *Pipeline {
+	p.steps = append(p.steps, step{name: name, selector: selector, transform: t, all: true})
+	return p
+}
+
+// Run extracts the records of the page and emits them to the sink. Pages
+// which do not match the pipeline are skipped.
+func (p *Pipeline) Run(page *browser.Page) error {
+	if p.match != nil && !p.match.MatchString(page.Url().String()) {
+		return nil
+	}
+	roots := page.Find("html")
+	if p.scope != "" {
+		roots = page.Find(p.scope)
+	}
+	var err error
+	roots.EachWithBreak(func(_ int, root *goquery.Selection) bool {
+		var r Record
+		if r, err = p.extract(page, root); err != nil {
+			return false
+		}
+		err = p.sink.Emit(r)
+		return err == nil
+	})
+	return err
+}
+
+// extract returns the record of the root element.
+func (p *Pipeline) extract(page *browser.Page, root *goquery.Selection) (Record, error) {
+	r := make(Record, len(p.steps))
+	for _, s := range p.steps {
+		sel := root
+		if s.selector != "" {
+			sel = root.Find(s.selector)
+		}
+		if !s.all {
+			if sel.Length() == 0 {
+				r[s.name] = nil
+				continue
+			}
+			v, err := s.transform(page, sel.First())
+			if err != nil {
+				return nil, errors.New("Field '%s' of %s: %s", s.name, page.Url(), err)
+			}
+			r[s.name] = v
+			continue
+		}
+		values := make([]interface{}, 0, sel.Length())
+		for i := range sel.Nodes {
+			v, err := s.transform(page, sel.Eq(i))
+			if err != nil {
+				return nil, errors.New("Field '%s' of %s: %s", s.name, page.Url(), err)
+			}
+			values = append(values, v)
+		}
+		r[s.name] = values
+	}
+	return r, nil
+}
+
+// Attach runs the pipeline against each page the browser loads. The errors
+// of the runs are collected, and returned by Errors().
+func (p *Pipeline) Attach(bow browser.Browsable) {
+	bow.On(browser.PageLoaded, func(e *browser.Event) error {
+		if err := p.Run(bow.Page()); err != nil {
+			p.errorsMutex.Lock()
+			p.errors = append(p.errors, err)
+			p.errorsMutex.Unlock()
+		}
+		return nil
+	})
+}
+
+// Errors returns the errors of the runs started by attached browsers since
+// the last call to Errors().
+func (p *Pipeline) Errors() []error {
+	p.errorsMutex.Lock()
+	defer p.errorsMutex.Unlock()
+	errs := p.errors
+	p.errors = nil
+	return errs
+}
@@ -0,0 +1,161 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/haruyama/surf"
+	"github.com/headzoo/ut"
+)
+
+var htmlArticles = `<!doctype html>
+<html>
+	<body>
+		<article>
+			<h2> First </h2>
+			<a href="/first">Read</a>
+			<span class="tag">go</span><span class="tag">web</span>
+			<span class="price">10</span>
+		</article>
+		<article>
+			<h2>Second</h2>
+			<span class="price">25</span>
+		</article>
+	</body>
+</html>
+`
+
+func TestPipeline(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, htmlArticles)
+	}))
+	defer ts.Close()
+
+	price := Map(Text, func(v interface{}) (interface{}, error) {
+		return strconv.Atoi(v.(string))
+	})
+	ch := make(chan Record, 10)
+	p := NewPipeline(ChannelSink(ch)).
+		Match(regexp.MustCompile(`/articles$`)).
+		Scope("article").
+		Field("title", "h2", Text).
+		Field("link", "a", URL("href")).
+		Field("price", ".price", price).
+		List("tags", ".tag", Text)
+
+	bow := surf.NewBrowser()
+	p.Attach(bow)
+	ut.AssertNil(bow.Open(ts.URL + "/home"))
+	ut.AssertEquals(0, len(ch))
+	ut.AssertNil(bow.Open(ts.URL + "/articles"))
+	ut.AssertEquals(2, len(ch))
+	ut.AssertEquals(0, len(p.Errors()))
+
+	r := <-ch
+	ut.AssertEquals("First", r["title"])
+	ut.AssertEquals(ts.URL+"/first", r["link"])
+	ut.AssertEquals(10, r["price"])
+	ut.AssertEquals([]interface{}{"go", "web"}, r["tags"])
+	r = <-ch
+	ut.AssertEquals("Second", r["title"])
+	ut.AssertNil(r["link"])
+	ut.AssertEquals(25, r["price"])
+	ut.AssertEquals(0, len(r["tags"].([]interface{})))
+
+	var buf bytes.Buffer
+	ut.AssertNil(NewPipeline(JSONLSink(&buf)).Field("title", "h2", Text).Run(bow.Page()))
+	ut.AssertEquals("{\"title\":\"First\"}\n", buf.String())
+
+	bad := NewPipeline(ChannelSink(ch)).Field("price", "h2", price)
+	bad.Attach(bow)
+	ut.AssertNil(bow.Open(ts.URL + "/articles"))
+	errs := bad.Errors()
+	ut.AssertEquals(1, len(errs))
+	ut.AssertTrue(strings.Contains(errs[0].Error(), "Field 'price'"))
+}
+
+func TestFileSinks(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, htmlArticles)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf-extract-")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	csvFile, err := NewCSVFile(filepath.Join(dir, "articles.csv"), []string{"title", "tags"}, RotateOptions{MaxRecords: 1})
+	ut.AssertNil(err)
+	jsonFile, err := NewJSONLFile(filepath.Join(dir, "pages-%d.jsonl"), RotateOptions{MaxBytes: 10})
+	ut.AssertNil(err)
+
+	bow := surf.NewBrowser()
+	NewPipeline(csvFile).Scope("article").Field("title", "h2", Text).List("tags", ".tag", Text).Attach(bow)
+	NewSummaryPipeline(jsonFile).Attach(bow)
+	ut.AssertNil(bow.Open(ts.URL + "/a"))
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertEquals(filepath.Join(dir, "pages-2.jsonl"), jsonFile.Name())
+	ut.AssertNil(csvFile.Close())
+	ut.AssertNil(jsonFile.Close())
+	ut.AssertEquals(os.ErrClosed, csvFile.Emit(Record{}))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "articles.csv"))
+	ut.AssertNil(err)
+	ut.AssertEquals("title,tags\nFirst,go|web\n", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "articles.csv.4"))
+	ut.AssertNil(err)
+	ut.AssertEquals("title,tags\nSecond,\n", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "pages-1.jsonl"))
+	ut.AssertNil(err)
+	ut.AssertEquals(fmt.Sprintf(
+		`{"content_type":"text/html; charset=utf-8","status":200,"title":null,"url":"%s/a"}`+"\n", ts.URL),
+		string(b))
+}
+
+func TestListing(t *testing.T) {
+	ut.Run(t)
+	pages := map[string]string{
+		"/list":   `<ul><li><a href="/item/1">One</a></li><li><a href="/item/2">Two</a></li></ul><a class="next" href="/list/2">Next</a>`,
+		"/list/2": `<ul><li><a href="/item/2">Two</a></li><li><a href="/item/3">Three</a></li></ul><a class="next" href="/list/3">Next</a>`,
+		"/list/3": `<ul><li><a href="/item/4">Four</a></li></ul><a class="next" href="/list">First</a>`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><body>"+pages[req.URL.Path]+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := surf.NewBrowser()
+	l := &Listing{Item: "li", Next: "a.next"}
+	items, err := l.Collect(bow, ts.URL+"/list")
+	ut.AssertNil(err)
+	ut.AssertEquals(4, len(items))
+	ut.AssertEquals("Four", items[3].Text())
+	ut.AssertEquals(ts.URL+"/list/3", bow.Url().String())
+
+	l.MaxPages = 2
+	items, err = l.Collect(bow, ts.URL+"/list")
+	ut.AssertNil(err)
+	ut.AssertEquals(3, len(items))
+
+	l.MaxPages, l.MaxItems = 0, 2
+	records, err := l.Scrape(bow, ts.URL+"/list", NewPipeline(nil).
+		Field("name", "a", Text).
+		Field("link", "a", URL("href")))
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(records))
+	ut.AssertEquals("Two", records[1]["name"])
+	ut.AssertEquals(ts.URL+"/item/2", records[1]["link"])
+	ut.AssertEquals(ts.URL+"/list", bow.Url().String())
+}
@@ -0,0 +1,232 @@
+package extract
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RotateOptions control when a file sink starts a new file. Zero values mean
+// no limit, so by default every record is written to a single file.
+type RotateOptions struct {
+	// MaxBytes is the size after which a new file is started.
+	MaxBytes int64
+
+	// MaxRecords is the number of records after which a new file is started.
+	MaxRecords int
+}
+
+// FileSink is a Sink which writes records to a sequence of files, starting a
+// new file when the current one reaches the limits of its RotateOptions.
+//
+// The file names are made from a pattern. When it contains a verb, eg
+// "records-%03d.jsonl", it's formatted with the sequence number of the file,
+// starting at 1. Otherwise the first file is named after the pattern, and the
+// number is appended to the names of the following files, eg "records.jsonl.2".
+type FileSink struct {
+	// pattern is the pattern of the file names.
+	pattern string
+
+	// opts are the limits of each file.
+	opts RotateOptions
+
+	// newEncoder returns the encoder of a new file.
+	newEncoder func(w io.Writer) (recordEncoder, error)
+
+	// mutex protects the current file.
+	mutex sync.Mutex
+
+	// file is the current file, or nil once the sink is closed.
+	file *os.File
+
+	// out counts the bytes written to the current file.
+	out *countingWriter
+
+	// enc writes records to the current file.
+	enc recordEncoder
+
+	// records is the number of records written to the current file.
+	records int
+
+	// index is the sequence number of the current file.
+	index int
+}
+
+// recordEncoder writes records to a file.
+type recordEncoder interface {
+	encode(r Record) error
+}
+
+// NewJSONLFile creates and returns a new *FileSink type which writes each
+// record as a line of JSON.
+func NewJSONLFile(pattern string, opts RotateOptions) (*FileSink, error) {
+	return newFileSink(pattern, opts, func(w io.Writer) (recordEncoder, error) {
+		return jsonEncoder{json.NewEncoder(w)}, nil
+	})
+}
+
+// NewCSVFile creates and returns a new *FileSink type which writes the given
+// columns of each record as a row of CSV. Each file starts with a header row
+// of the column names.
+func NewCSVFile(pattern string, columns []string, opts RotateOptions) (*FileSink, error) {
+	return newFileSink(pattern, opts, func(w io.Writer) (recordEncoder, error) {
+		enc := csvEncoder{w: csv.NewWriter(w), columns: columns}
+		if err := enc.w.Write(columns); err != nil {
+			return nil, err
+		}
+		enc.w.Flush()
+		return enc, enc.w.Error()
+	})
+}
+
+// newFileSink creates the sink, and its first file.
+func newFileSink(pattern string, opts RotateOptions, newEncoder func(w io.Writer) (recordEncoder, error)) (*FileSink, error) {
+	s := &FileSink{pattern: pattern, opts: opts, newEncoder: newEncoder}
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Emit writes the record, starting a new file first when the current one is full.
+func (s *FileSink) Emit(r Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.full() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if err := s.enc.encode(r); err != nil {
+		return err
+	}
+	s.records++
+	return nil
+}
+
+// Name returns the name of the file records are currently written to.
+func (s *FileSink) Name() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.name(s.index)
+}
+
+// Close closes the current file.
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// full returns whether the current file has reached its limits.
+func (s *FileSink) full() bool {
+	if s.opts.MaxRecords > 0 && s.records >= s.opts.MaxRecords {
+		return true
+	}
+	return s.opts.MaxBytes > 0 && s.out.n >= s.opts.MaxBytes
+}
+
+// rotate closes the current file, and creates the next one.
+func (s *FileSink) rotate() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		s.file = nil
+	}
+	f, err := os.Create(s.name(s.index + 1))
+	if err != nil {
+		return err
+	}
+	out := &countingWriter{w: f}
+	enc, err := s.newEncoder(out)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.index++
+	s.file, s.out, s.enc, s.records = f, out, enc, 0
+	return nil
+}
+
+// name returns the name of the file with the given sequence number.
+func (s *FileSink) name(index int) string {
+	if strings.Contains(s.pattern, "%") {
+		return fmt.Sprintf(s.pattern, index)
+	}
+	if index <= 1 {
+		return s.pattern
+	}
+	return s.pattern + "." + strconv.Itoa(index)
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes to w, and counts the bytes written.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// jsonEncoder writes records as lines of JSON.
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+// encode writes the record.
+func (e jsonEncoder) encode(r Record) error {
+	return e.enc.Encode(r)
+}
+
+// csvEncoder writes records as rows of CSV.
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+}
+
+// encode writes the columns of the record. Missing and nil values are empty,
+// and the values of lists are separated by "|".
+func (e csvEncoder) encode(r Record) error {
+	row := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		row[i] = csvValue(r[c])
+	}
+	if err := e.w.Write(row); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvValue formats a record value for a CSV cell.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = csvValue(item)
+		}
+		return strings.Join(values, "|")
+	}
+	return fmt.Sprint(v)
+}
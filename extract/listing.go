@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/haruyama/surf/browser"
+)
+
+// Listing collects the items of a listing which is split across pages, such
+// as search results or a table with a next page link.
+type Listing struct {
+	// Item is the selector of the items on each page.
+	Item string
+
+	// Next is the selector of the link to the next page. The listing ends on
+	// the first page without a next link, or whose next link leads to a page
+	// already visited.
+	Next string
+
+	// MaxPages is the number of pages after which the listing ends. Zero
+	// means no limit.
+	MaxPages int
+
+	// MaxItems is the number of items after which the listing ends. Zero
+	// means no limit.
+	MaxItems int
+
+	// Key returns the key identifying an item, so items found more than once
+	// are only collected the first time. Items with an empty key are always
+	// collected. The default key is the URL of the first link in the item,
+	// or its trimmed text when it has no link.
+	Key func(p *browser.Page, item *goquery.Selection) string
+}
+
+// Each opens the URL, and calls f with each item of each page of the
+// listing, following the next page links. Returning an error from f ends the
+// listing, and the error is returned.
+func (l *Listing) Each(bow browser.Browsable, u string, f func(p *browser.Page, item *goquery.Selection) error) error {
+	if err := bow.Open(u); err != nil {
+		return err
+	}
+	key := l.Key
+	if key == nil {
+		key = defaultKey
+	}
+	seen := make(map[string]bool)
+	visited := map[string]bool{bow.Url().String(): true}
+	items := 0
+	for pages := 1; ; pages++ {
+		page := bow.Page()
+		var err error
+		page.Find(l.Item).EachWithBreak(func(_ int, item *goquery.Selection) bool {
+			if l.MaxItems > 0 && items >= l.MaxItems {
+				return false
+			}
+			if k := key(page, item); k != "" {
+				if seen[k] {
+					return true
+				}
+				seen[k] = true
+			}
+			items++
+			err = f(page, item)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		if (l.MaxItems > 0 && items >= l.MaxItems) || (l.MaxPages > 0 && pages >= l.MaxPages) {
+			return nil
+		}
+
+		next := nextURL(page, l.Next)
+		if next == "" || visited[next] {
+			return nil
+		}
+		visited[next] = true
+		if err = bow.Click(l.Next); err != nil {
+			return err
+		}
+	}
+}
+
+// Collect returns the items of each page of the listing.
+func (l *Listing) Collect(bow browser.Browsable, u string) ([]*goquery.Selection, error) {
+	var items []*goquery.Selection
+	err := l.Each(bow, u, func(_ *browser.Page, item *goquery.Selection) error {
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// Scrape returns the records extracted from each item of the listing by the
+// steps of the pipeline. The scope, the URL expression, and the sink of the
+// pipeline are not used.
+func (l *Listing) Scrape(bow browser.Browsable, u string, p *Pipeline) ([]Record, error) {
+	var records []Record
+	err := l.Each(bow, u, func(page *browser.Page, item *goquery.Selection) error {
+		r, err := p.extract(page, item)
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	})
+	return records, err
+}
+
+// nextURL returns the absolute URL of the next page link, or an empty string
+// when the page has none.
+func nextURL(p *browser.Page, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	href, ok := p.Find(selector).First().Attr("href")
+	if !ok {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return p.ResolveUrl(u).String()
+}
+
+// defaultKey returns the URL of the first link in the item, or its trimmed
+// text when it has no link.
+func defaultKey(p *browser.Page, item *goquery.Selection) string {
+	link := item
+	if !link.Is("a[href]") {
+		link = item.Find("a[href]").First()
+	}
+	if href, ok := link.Attr("href"); ok {
+		if u, err := url.Parse(href); err == nil {
+			return p.ResolveUrl(u).String()
+		}
+	}
+	return strings.TrimSpace(item.Text())
+}
@@ -0,0 +1,43 @@
+package extract
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Sink receives the records extracted by a pipeline.
+//
+// Browsers may run a pipeline concurrently, so sinks must be safe for
+// concurrent use.
+type Sink interface {
+	// Emit receives a record. Returning an error stops the pipeline run.
+	Emit(r Record) error
+}
+
+// SinkFunc is an adapter which allows the use of a function as a Sink.
+type SinkFunc func(r Record) error
+
+// Emit calls f(r).
+func (f SinkFunc) Emit(r Record) error {
+	return f(r)
+}
+
+// ChannelSink returns a Sink which sends each record to the channel.
+func ChannelSink(ch chan<- Record) Sink {
+	return SinkFunc(func(r Record) error {
+		ch <- r
+		return nil
+	})
+}
+
+// JSONLSink returns a Sink which writes each record to w as a line of JSON.
+func JSONLSink(w io.Writer) Sink {
+	var mutex sync.Mutex
+	enc := json.NewEncoder(w)
+	return SinkFunc(func(r Record) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return enc.Encode(r)
+	})
+}
@@ -0,0 +1,68 @@
+package surf
+
+import (
+	"sync"
+
+	"github.com/haruyama/surf/browser"
+)
+
+// FetchResult is the result of fetching one of the URLs given to FetchAll().
+type FetchResult struct {
+	// URL is the requested URL.
+	URL string
+
+	// Page is the fetched page, or nil when the request failed.
+	Page *browser.Page
+
+	// Err is the error which occurred fetching the page, or nil.
+	Err error
+}
+
+// FetchAll requests each of the URLs using the GET method, fetching up to
+// concurrency pages at the same time, and returns the results in the same
+// order as the URLs.
+//
+// Each worker fetches its pages with its own browser, created by calling
+// newBrowser, or NewBrowser() when it's nil. The pages are fetched like
+// Peek(), so they are independent values which may be handed to other
+// goroutines, and no browser navigates. The document of each page is parsed
+// by the worker which fetched it, since closing the browser of the worker
+// removes the bodies it spooled to disk. A concurrency below one fetches the
+// pages one at a time.
+func FetchAll(newBrowser func() *browser.Browser, urls []string, concurrency int) []FetchResult {
+	if newBrowser == nil {
+		newBrowser = NewBrowser
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	results := make([]FetchResult, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bow := newBrowser()
+			defer bow.Close()
+			for i := range jobs {
+				page, err := bow.Peek(urls[i])
+				if page != nil {
+					page.Dom()
+				}
+				results[i] = FetchResult{URL: urls[i], Page: page, Err: err}
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,100 @@
+// Package fingerprint dials TLS connections which present the ClientHello of
+// a well known browser, so the TLS fingerprint (JA3) of a Surf browser matches
+// the browser it claims to be.
+//
+// Some CDNs block clients using the default Go TLS fingerprint regardless of
+// the headers they send. Use with Browser.Emulate() to present a consistent
+// identity:
+//
+//	bow.Emulate(agent.ChromeDesktop)
+//	bow.SetDialTLSContext(fingerprint.DialTLSContext(fingerprint.Chrome))
+package fingerprint
+
+import (
+	"context"
+	"net"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+var (
+	// Chrome is the ClientHello of a recent Chrome release.
+	Chrome = utls.HelloChrome_Auto
+
+	// Firefox is the ClientHello of a recent Firefox release.
+	Firefox = utls.HelloFirefox_Auto
+
+	// Safari is the ClientHello of a recent Safari release.
+	Safari = utls.HelloSafari_Auto
+
+	// IOS is the ClientHello of a recent Safari on iOS release.
+	IOS = utls.HelloIOS_Auto
+)
+
+// Dialer dials TLS connections using the ClientHello of a browser.
+type Dialer struct {
+	// ID identifies the browser ClientHello, eg Chrome or Firefox.
+	ID utls.ClientHelloID
+
+	// Config is the TLS configuration. The server name is set from the dialed
+	// address when empty. May be nil.
+	Config *utls.Config
+
+	// NetDialer dials the underlying connections. May be nil.
+	NetDialer *net.Dialer
+}
+
+// DialTLSContext returns a function, for use with Browser.SetDialTLSContext(),
+// which dials TLS connections using the ClientHello with the given id.
+func DialTLSContext(id utls.ClientHelloID) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return (&Dialer{ID: id}).DialTLSContext
+}
+
+// DialTLSContext connects to the address and performs the TLS handshake.
+//
+// The browser ClientHello advertises HTTP/2, which the transport is unable to
+// speak over a connection it did not negotiate itself, so only HTTP/1.1 is
+// offered. The protocol list is not part of the JA3 fingerprint.
+func (d *Dialer) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	nd := d.NetDialer
+	if nd == nil {
+		nd = &net.Dialer{}
+	}
+	conn, err := nd.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &utls.Config{}
+	if d.Config != nil {
+		config = d.Config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	spec, err := utls.UTLSIdToSpec(d.ID)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, ext := range spec.Extensions {
+		if alpn, ok := ext.(*utls.ALPNExtension); ok {
+			alpn.AlpnProtocols = []string{"http/1.1"}
+		}
+	}
+
+	uconn := utls.UClient(conn, config, utls.HelloCustom)
+	if err = uconn.ApplyPreset(&spec); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = uconn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return uconn, nil
+}
@@ -0,0 +1,34 @@
+package fingerprint
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haruyama/surf/browser"
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestDialTLSContext(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}))
+	defer ts.Close()
+
+	for _, id := range []utls.ClientHelloID{Chrome, Firefox, Safari} {
+		roots := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		d := &Dialer{ID: id, Config: &utls.Config{RootCAs: roots}}
+
+		bow := &browser.Browser{}
+		bow.SetHeadersJar(jar.NewMemoryHeaders())
+		bow.SetHistoryJar(jar.NewMemoryHistory())
+		bow.SetDialTLSContext(d.DialTLSContext)
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+		ut.AssertEquals("HTTP/1.1", bow.Body())
+	}
+}
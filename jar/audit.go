@@ -0,0 +1,143 @@
+package jar
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+)
+
+// AuditRecord describes a request sent by the browser.
+type AuditRecord struct {
+	// Time is when the request was sent.
+	Time time.Time `json:"time"`
+
+	// Method is the method of the request.
+	Method string `json:"method"`
+
+	// URL is the URL of the request.
+	URL string `json:"url"`
+
+	// Status is the status code of the response, or zero when the request failed.
+	Status int `json:"status"`
+
+	// Bytes is the number of bytes of the response body read.
+	Bytes int64 `json:"bytes"`
+
+	// Duration is the time from sending the request to closing the response
+	// body, in nanoseconds.
+	Duration time.Duration `json:"duration"`
+
+	// Trigger is what caused the browser to send the request, eg "click", or
+	// empty for requests which are not navigations, such as asset downloads.
+	Trigger string `json:"trigger,omitempty"`
+
+	// Error is the error which occurred sending the request, if any.
+	Error string `json:"error,omitempty"`
+
+	// PrevHash is the hex encoded SHA-256 hash of the previous line of the
+	// log, or empty for the first record.
+	PrevHash string `json:"prev_hash"`
+}
+
+// AuditJar is a container for the records of the requests sent by a browser.
+type AuditJar interface {
+	// Record stores the record of a request.
+	Record(r *AuditRecord) error
+}
+
+// AuditLog is an implementation of AuditJar which appends each record to a
+// writer as a line of JSON.
+//
+// Each record holds the hash of the line before it, so that a line of the log
+// which has been changed, removed, or inserted is found by VerifyAuditLog().
+type AuditLog struct {
+	mutex sync.Mutex
+	w     io.Writer
+	prev  string
+}
+
+// NewAuditLog creates and returns a new *AuditLog type which writes to w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// NewFileAuditLog creates and returns a new *AuditLog type which appends to
+// the file, creating it when needed. The records continue the hash chain of
+// the records already in the file. The file is closed by Close().
+func NewFileAuditLog(file string) (*AuditLog, error) {
+	fout, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	var prev string
+	scanner := bufio.NewScanner(fout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			prev = hashLine(scanner.Bytes())
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		fout.Close()
+		return nil, err
+	}
+	return &AuditLog{w: fout, prev: prev}, nil
+}
+
+// Record appends the record to the log, setting its PrevHash.
+func (a *AuditLog) Record(r *AuditRecord) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	r.PrevHash = a.prev
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = a.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	a.prev = hashLine(line)
+	return nil
+}
+
+// Close closes the writer of the log when it's an io.Closer.
+func (a *AuditLog) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// VerifyAuditLog reads a log written by an AuditLog, and returns an error
+// naming the first line whose PrevHash does not match the line before it.
+func VerifyAuditLog(r io.Reader) error {
+	var prev string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return errors.New("Audit log line %d is not a record: %s", n, err)
+		}
+		if rec.PrevHash != prev {
+			return errors.New("Audit log line %d does not follow the line before it.", n)
+		}
+		prev = hashLine(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// hashLine returns the hex encoded SHA-256 hash of the line.
+func hashLine(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
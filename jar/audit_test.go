@@ -0,0 +1,49 @@
+package jar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestAuditLog(t *testing.T) {
+	ut.Run(t)
+	buff := &bytes.Buffer{}
+	a := NewAuditLog(buff)
+	ut.AssertNil(a.Record(&AuditRecord{Method: "GET", URL: "http://a.com/", Status: 200}))
+	ut.AssertNil(a.Record(&AuditRecord{Method: "POST", URL: "http://a.com/login", Status: 302}))
+	ut.AssertNil(a.Record(&AuditRecord{Method: "GET", URL: "http://a.com/home", Status: 200}))
+	ut.AssertNil(VerifyAuditLog(bytes.NewReader(buff.Bytes())))
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	ut.AssertEquals(3, len(lines))
+	ut.AssertTrue(strings.Contains(lines[0], `"prev_hash":""`))
+
+	tampered := strings.Replace(buff.String(), `"status":302`, `"status":200`, 1)
+	ut.AssertNotNil(VerifyAuditLog(strings.NewReader(tampered)))
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	ut.AssertNotNil(VerifyAuditLog(strings.NewReader(removed)))
+}
+
+func TestFileAuditLog(t *testing.T) {
+	ut.Run(t)
+	defer os.Remove("./audit.jsonl")
+	a, err := NewFileAuditLog("./audit.jsonl")
+	ut.AssertNil(err)
+	ut.AssertNil(a.Record(&AuditRecord{Method: "GET", URL: "http://a.com/", Status: 200}))
+	ut.AssertNil(a.Close())
+
+	a, err = NewFileAuditLog("./audit.jsonl")
+	ut.AssertNil(err)
+	ut.AssertNil(a.Record(&AuditRecord{Method: "GET", URL: "http://a.com/next", Status: 200}))
+	ut.AssertNil(a.Close())
+
+	data, err := ioutil.ReadFile("./audit.jsonl")
+	ut.AssertNil(err)
+	ut.AssertEquals(2, strings.Count(string(data), "\n"))
+	ut.AssertNil(VerifyAuditLog(bytes.NewReader(data)))
+}
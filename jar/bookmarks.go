@@ -176,6 +176,11 @@ func (b *FileBookmarks) All() BookmarksMap {
 	return b.bookmarks
 }
 
+// Flush writes the bookmarks to the file.
+func (b *FileBookmarks) Flush() error {
+	return b.writeToFile()
+}
+
 // writeToFile writes the bookmarks to the file.
 func (b *FileBookmarks) writeToFile() (err error) {
 	j, err := json.Marshal(b.bookmarks)
@@ -1,11 +1,140 @@
 package jar
 
-import "net/http/cookiejar"
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
 
-// New returns a new cookie jar.
-func NewMemoryCookies() *cookiejar.Jar {
+// CookieEntry is a cookie along with the URL of the response which set it.
+type CookieEntry struct {
+	// URL is the URL of the response which set the cookie.
+	URL string `json:"url"`
+
+	// Cookie is the cookie as it was set.
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// CookieExporter is implemented by cookie jars whose cookies may be listed.
+//
+// The listed cookies are restored by passing each entry to the SetCookies
+// method of another jar.
+type CookieExporter interface {
+	// Export returns the unexpired cookies in the jar.
+	Export() []CookieEntry
+}
+
+// MemoryCookies is an in-memory cookie jar, which records the cookies it
+// stores so they may be exported.
+type MemoryCookies struct {
+	jar     *cookiejar.Jar
+	mutex   sync.Mutex
+	entries map[string]CookieEntry
+}
+
+// NewMemoryCookies creates and returns a new *MemoryCookies type.
+func NewMemoryCookies() *MemoryCookies {
 	// cookiejar.New returns an error, but it's always nil. Maybe it's there
 	// for future use or to conform to an interface?
 	jar, _ := cookiejar.New(nil)
-	return jar
+	return &MemoryCookies{
+		jar:     jar,
+		entries: make(map[string]CookieEntry),
+	}
+}
+
+// SetCookies stores the cookies set by a response from the given URL.
+func (mc *MemoryCookies) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	mc.jar.SetCookies(u, cookies)
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		c := *c
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		mc.entries[CookieKey(u, &c)] = CookieEntry{URL: u.String(), Cookie: &c}
+	}
+}
+
+// Cookies returns the cookies to send in a request for the given URL.
+func (mc *MemoryCookies) Cookies(u *url.URL) []*http.Cookie {
+	return mc.jar.Cookies(u)
+}
+
+// Export returns the unexpired cookies in the jar, ordered by domain, path,
+// and name.
+func (mc *MemoryCookies) Export() []CookieEntry {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	keys := make([]string, 0, len(mc.entries))
+	now := time.Now()
+	for k, e := range mc.entries {
+		if e.Cookie.MaxAge < 0 || (!e.Cookie.Expires.IsZero() && !e.Cookie.Expires.After(now)) {
+			delete(mc.entries, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]CookieEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = mc.entries[k]
+	}
+	return entries
+}
+
+// CookieKey returns the key identifying a cookie set by a response from the
+// given URL, which is its domain, path, and name.
+func CookieKey(u *url.URL, c *http.Cookie) string {
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+	path := c.Path
+	if path == "" || path[0] != '/' {
+		path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			path = u.Path[:i]
+		}
+	}
+	return domain + ";" + path + ";" + c.Name
+}
+
+// ImportCookies stores the cookies of the entries in the jar, as though each
+// was set by a response from the URL of its entry.
+func ImportCookies(dst http.CookieJar, entries []CookieEntry) error {
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return err
+		}
+		dst.SetCookies(u, []*http.Cookie{e.Cookie})
+	}
+	return nil
+}
+
+// MergeCookies stores the unexpired cookies of src in dst, replacing the
+// cookies of dst with the same domain, path, and name.
+func MergeCookies(dst http.CookieJar, src CookieExporter) error {
+	return ImportCookies(dst, src.Export())
+}
+
+// CloneCookies returns a new jar holding copies of the unexpired cookies of
+// src. Changes to either jar are not seen by the other, which allows a logged
+// in session to be split across a pool of browsers.
+func CloneCookies(src CookieExporter) (*MemoryCookies, error) {
+	mc := NewMemoryCookies()
+	if err := MergeCookies(mc, src); err != nil {
+		return nil, err
+	}
+	return mc, nil
 }
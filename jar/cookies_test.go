@@ -0,0 +1,39 @@
+package jar
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestCloneCookies(t *testing.T) {
+	ut.Run(t)
+	u, _ := url.Parse("http://www.example.com/account/login")
+	src := NewMemoryCookies()
+	src.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "joe", MaxAge: 3600},
+		{Name: "theme", Value: "dark", Path: "/", Domain: "example.com"},
+	})
+
+	clone, err := CloneCookies(src)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(clone.Cookies(u)))
+	ut.AssertEquals(src.Export(), clone.Export())
+
+	clone.SetCookies(u, []*http.Cookie{{Name: "session", Value: "ann"}})
+	ut.AssertEquals("joe", src.Export()[1].Cookie.Value)
+
+	dst := NewMemoryCookies()
+	other, _ := url.Parse("http://example.org/")
+	dst.SetCookies(other, []*http.Cookie{{Name: "id", Value: "1"}})
+	dst.SetCookies(u, []*http.Cookie{{Name: "theme", Value: "light", Path: "/", Domain: "example.com"}})
+	ut.AssertNil(MergeCookies(dst, src))
+	ut.AssertEquals(3, len(dst.Export()))
+	for _, c := range dst.Cookies(u) {
+		if c.Name == "theme" {
+			ut.AssertEquals("dark", c.Value)
+		}
+	}
+}
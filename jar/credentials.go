@@ -0,0 +1,122 @@
+package jar
+
+import (
+	"encoding/base64"
+	"net"
+	"strings"
+)
+
+// Credentials are the credentials sent to a protected site.
+type Credentials struct {
+	// Username is the user name sent using Basic authentication.
+	Username string
+
+	// Password is the password sent using Basic authentication.
+	Password string
+
+	// Token is sent using Bearer authentication when not empty, instead of
+	// the username and password.
+	Token string
+}
+
+// Authorization returns the Authorization header value for the credentials.
+func (c *Credentials) Authorization() string {
+	if c.Token != "" {
+		return "Bearer " + c.Token
+	}
+	auth := c.Username + ":" + c.Password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+}
+
+// CredentialsJar is a container for credentials keyed by scheme, host and
+// realm.
+type CredentialsJar interface {
+	// Save stores the credentials for the host and realm. The host may include
+	// a port, and may be prefixed by a scheme, eg "https://example.com", in
+	// which case the credentials are only sent using that scheme. A host
+	// without a scheme matches every scheme. An empty realm matches every
+	// realm of the host.
+	Save(host, realm string, c *Credentials)
+
+	// Read returns the credentials for the host and realm.
+	Read(host, realm string) (*Credentials, bool)
+
+	// Remove deletes the credentials for the host and realm.
+	Remove(host, realm string) bool
+}
+
+// credentialsKey identifies credentials in a jar.
+type credentialsKey struct {
+	host  string
+	realm string
+}
+
+// MemoryCredentials is an in-memory implementation of CredentialsJar.
+type MemoryCredentials struct {
+	credentials map[credentialsKey]*Credentials
+}
+
+// NewMemoryCredentials creates and returns a new *MemoryCredentials type.
+func NewMemoryCredentials() *MemoryCredentials {
+	return &MemoryCredentials{
+		credentials: make(map[credentialsKey]*Credentials),
+	}
+}
+
+// Save stores the credentials for the host and realm.
+func (mc *MemoryCredentials) Save(host, realm string, c *Credentials) {
+	mc.credentials[credentialsKey{strings.ToLower(host), realm}] = c
+}
+
+// Read returns the credentials for the host and realm.
+//
+// The host may be prefixed by a scheme. Credentials saved for the scheme are
+// preferred over those saved for every scheme, credentials saved for the
+// exact host, including the port, are preferred over those saved for the
+// host name alone, and credentials saved for the realm are preferred over
+// those saved for every realm.
+func (mc *MemoryCredentials) Read(host, realm string) (*Credentials, bool) {
+	scheme, host := splitScheme(strings.ToLower(host))
+	names := []string{host}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		names = append(names, h)
+	}
+	var hosts []string
+	if scheme != "" {
+		for _, h := range names {
+			hosts = append(hosts, scheme+"://"+h)
+		}
+	}
+	hosts = append(hosts, names...)
+	realms := []string{realm}
+	if realm != "" {
+		realms = append(realms, "")
+	}
+	for _, r := range realms {
+		for _, h := range hosts {
+			if c, ok := mc.credentials[credentialsKey{h, r}]; ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Remove deletes the credentials for the host and realm.
+func (mc *MemoryCredentials) Remove(host, realm string) bool {
+	key := credentialsKey{strings.ToLower(host), realm}
+	if _, ok := mc.credentials[key]; ok {
+		delete(mc.credentials, key)
+		return true
+	}
+	return false
+}
+
+// splitScheme returns the scheme of the host, or an empty string when it has
+// none, and the host without the scheme.
+func splitScheme(host string) (string, string) {
+	if i := strings.Index(host, "://"); i >= 0 {
+		return host[:i], host[i+3:]
+	}
+	return "", host
+}
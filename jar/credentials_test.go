@@ -0,0 +1,87 @@
+package jar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/headzoo/ut"
+)
+
+func TestMemoryCredentials(t *testing.T) {
+	ut.Run(t)
+	jar := NewMemoryCredentials()
+	all := &Credentials{Username: "joe", Password: "secret"}
+	admin := &Credentials{Token: "abc123"}
+	jar.Save("Example.com", "", all)
+	jar.Save("example.com:8080", "admin", admin)
+
+	c, ok := jar.Read("example.com", "users")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(all, c)
+	ut.AssertEquals("Basic am9lOnNlY3JldA==", c.Authorization())
+
+	c, ok = jar.Read("example.com:8080", "admin")
+	ut.AssertTrue(ok)
+	ut.AssertEquals("Bearer abc123", c.Authorization())
+
+	c, ok = jar.Read("example.com:8080", "")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(all, c)
+
+	_, ok = jar.Read("example.org", "")
+	ut.AssertFalse(ok)
+
+	secure := &Credentials{Token: "tls"}
+	jar.Save("HTTPS://example.com", "", secure)
+	c, ok = jar.Read("https://example.com:443", "")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(secure, c)
+	c, ok = jar.Read("http://example.com", "")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(all, c)
+	_, ok = jar.Read("http://example.org", "")
+	ut.AssertFalse(ok)
+	jar.Save("https://example.org", "", secure)
+	_, ok = jar.Read("http://example.org", "")
+	ut.AssertFalse(ok)
+
+	ut.AssertTrue(jar.Remove("example.com", ""))
+	ut.AssertFalse(jar.Remove("example.com", ""))
+	_, ok = jar.Read("example.com", "")
+	ut.AssertFalse(ok)
+}
+
+func TestReadNetrc(t *testing.T) {
+	ut.Run(t)
+	netrc := `# comment
+machine example.com login joe password secret
+machine api.example.com
+	login admin
+	password root
+	account ignored
+macdef init
+cd /pub
+bin
+
+default login anonymous password guest
+`
+	jar := NewMemoryCredentials()
+	ut.AssertNil(ReadNetrc(jar, strings.NewReader(netrc)))
+
+	c, ok := jar.Read("example.com:443", "")
+	ut.AssertTrue(ok)
+	ut.AssertEquals("joe", c.Username)
+	ut.AssertEquals("secret", c.Password)
+
+	c, ok = jar.Read("api.example.com", "Admin")
+	ut.AssertTrue(ok)
+	ut.AssertEquals("admin", c.Username)
+	ut.AssertEquals("root", c.Password)
+
+	_, ok = jar.Read("example.org", "")
+	ut.AssertFalse(ok)
+
+	t.Setenv("NETRC", "/nonexistent/.netrc")
+	ut.AssertNil(LoadNetrc(jar, ""))
+	ut.AssertNotNil(LoadNetrc(jar, "/nonexistent/.netrc"))
+}
@@ -0,0 +1,8 @@
+package jar
+
+// Flusher is implemented by jars which persist their contents, and which
+// should be flushed before the program exits.
+type Flusher interface {
+	// Flush writes the contents of the jar to its storage.
+	Flush() error
+}
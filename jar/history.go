@@ -1,8 +1,14 @@
 package jar
 
 import (
-	"github.com/PuerkitoBio/goquery"
+	"bytes"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 // State represents a point in time.
@@ -10,6 +16,21 @@ type State struct {
 	Request  *http.Request
 	Response *http.Response
 	Dom      *goquery.Document
+
+	// Body is the original response body the Dom was parsed from. It's nil
+	// when the body was spooled to BodyFile.
+	Body []byte
+
+	// BodyFile is the path of the file holding the original response body
+	// when it was too large to keep in memory.
+	BodyFile string
+
+	// EarlyHints are the headers of the 103 Early Hints responses received
+	// before the response.
+	EarlyHints []http.Header
+
+	// domMutex protects Dom when it's parsed lazily by Document().
+	domMutex sync.Mutex
 }
 
 // NewHistoryState creates and returns a new *State type.
@@ -21,6 +42,47 @@ func NewHistoryState(req *http.Request, resp *http.Response, dom *goquery.Docume
 	}
 }
 
+// Document returns the Dom, setting it to the document returned by parse
+// when it's nil. It's safe for concurrent use, and parse is called once. The
+// lock is held while parse runs, so parse must not call back into Document().
+func (s *State) Document(parse func() *goquery.Document) *goquery.Document {
+	s.domMutex.Lock()
+	defer s.domMutex.Unlock()
+	if s.Dom == nil {
+		s.Dom = parse()
+	}
+	return s.Dom
+}
+
+// Parsed returns whether the Dom has been set.
+func (s *State) Parsed() bool {
+	s.domMutex.Lock()
+	defer s.domMutex.Unlock()
+	return s.Dom != nil
+}
+
+// SetDocument replaces the Dom. Passing nil makes Document() parse the body
+// again.
+func (s *State) SetDocument(dom *goquery.Document) {
+	s.domMutex.Lock()
+	defer s.domMutex.Unlock()
+	s.Dom = dom
+}
+
+// HasBody returns whether the original response body is available.
+func (s *State) HasBody() bool {
+	return s.Body != nil || s.BodyFile != ""
+}
+
+// BodyReader returns a reader for the original response body.
+// The caller must close the reader.
+func (s *State) BodyReader() (io.ReadCloser, error) {
+	if s.BodyFile != "" {
+		return os.Open(s.BodyFile)
+	}
+	return ioutil.NopCloser(bytes.NewReader(s.Body)), nil
+}
+
 // History is a type that records browser state.
 type History interface {
 	Len() int
@@ -70,6 +132,15 @@ func (his *MemoryHistory) Pop() *State {
 	return nil
 }
 
+// States returns the states of the history, the front first.
+func (his *MemoryHistory) States() []*State {
+	states := make([]*State, 0, his.size)
+	for n := his.top; n != nil; n = n.Next {
+		states = append(states, n.Value)
+	}
+	return states
+}
+
 // Top returns the State at the front of the history without removing it.
 func (his *MemoryHistory) Top() *State {
 	if his.size == 0 {
@@ -19,6 +19,10 @@ func TestMemoryHistory(t *testing.T) {
 	ut.AssertEquals(2, stack.Len())
 	ut.AssertEquals(page2, stack.Top())
 
+	states := stack.States()
+	ut.AssertEquals(2, len(states))
+	ut.AssertTrue(states[0] == page2 && states[1] == page1)
+
 	page := stack.Pop()
 	ut.AssertEquals(page, page2)
 	ut.AssertEquals(1, stack.Len())
@@ -0,0 +1,128 @@
+package jar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/haruyama/surf/util"
+)
+
+// HSTSPolicy is the Strict-Transport-Security policy of a host.
+type HSTSPolicy struct {
+	// Expires is when the policy expires.
+	Expires time.Time `json:"expires"`
+
+	// IncludeSubdomains is whether the policy applies to the subdomains of the host.
+	IncludeSubdomains bool `json:"include_subdomains"`
+}
+
+// HSTSMap stores HSTS policies keyed by host name.
+type HSTSMap map[string]HSTSPolicy
+
+// HSTSJar is a container for the HSTS policies of hosts.
+type HSTSJar interface {
+	// Save stores the policy of the host, replacing its previous policy. Saving
+	// a policy which has already expired removes the policy of the host.
+	Save(host string, p HSTSPolicy) error
+
+	// Secure returns whether requests to the host must use HTTPS.
+	Secure(host string) bool
+}
+
+// MemoryHSTS is an in-memory implementation of HSTSJar.
+type MemoryHSTS struct {
+	policies HSTSMap
+}
+
+// NewMemoryHSTS creates and returns a new *MemoryHSTS type.
+func NewMemoryHSTS() *MemoryHSTS {
+	return &MemoryHSTS{policies: make(HSTSMap)}
+}
+
+// Save stores the policy of the host.
+func (h *MemoryHSTS) Save(host string, p HSTSPolicy) error {
+	savePolicy(h.policies, host, p)
+	return nil
+}
+
+// Secure returns whether requests to the host must use HTTPS.
+func (h *MemoryHSTS) Secure(host string) bool {
+	return securePolicy(h.policies, host)
+}
+
+// FileHSTS is an implementation of HSTSJar which saves the policies to a
+// JSON file, so they persist between sessions.
+type FileHSTS struct {
+	policies HSTSMap
+	file     string
+}
+
+// NewFileHSTS creates and returns a new *FileHSTS type, reading the policies
+// from the file when it exists.
+func NewFileHSTS(file string) (*FileHSTS, error) {
+	policies := make(HSTSMap)
+	if util.FileExists(file) {
+		fin, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(fin, &policies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &FileHSTS{
+		policies: policies,
+		file:     file,
+	}, nil
+}
+
+// Save stores the policy of the host, and writes the policies to the file.
+func (h *FileHSTS) Save(host string, p HSTSPolicy) error {
+	savePolicy(h.policies, host, p)
+	return h.Flush()
+}
+
+// Flush writes the policies to the file.
+func (h *FileHSTS) Flush() error {
+	j, err := json.Marshal(h.policies)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.file, j, 0600)
+}
+
+// Secure returns whether requests to the host must use HTTPS.
+func (h *FileHSTS) Secure(host string) bool {
+	return securePolicy(h.policies, host)
+}
+
+// savePolicy stores the policy of the host in the map.
+func savePolicy(policies HSTSMap, host string, p HSTSPolicy) {
+	host = strings.ToLower(host)
+	if !p.Expires.After(time.Now()) {
+		delete(policies, host)
+		return
+	}
+	policies[host] = p
+}
+
+// securePolicy returns whether the map has an unexpired policy for the host,
+// or for a parent domain which includes its subdomains.
+func securePolicy(policies HSTSMap, host string) bool {
+	host = strings.ToLower(host)
+	now := time.Now()
+	if p, ok := policies[host]; ok && p.Expires.After(now) {
+		return true
+	}
+	for i := strings.Index(host, "."); i >= 0; i = strings.Index(host, ".") {
+		host = host[i+1:]
+		if p, ok := policies[host]; ok && p.IncludeSubdomains && p.Expires.After(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package jar
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/headzoo/ut"
+)
+
+func TestMemoryHSTS(t *testing.T) {
+	ut.Run(t)
+	assertHSTS(NewMemoryHSTS())
+}
+
+func TestFileHSTS(t *testing.T) {
+	ut.Run(t)
+	h, err := NewFileHSTS("./hsts.json")
+	ut.AssertNil(err)
+	defer os.Remove("./hsts.json")
+	assertHSTS(h)
+
+	h.Save("persisted.com", HSTSPolicy{Expires: time.Now().Add(time.Hour)})
+	h, err = NewFileHSTS("./hsts.json")
+	ut.AssertNil(err)
+	ut.AssertTrue(h.Secure("persisted.com"))
+}
+
+// assertHSTS tests the given HSTS jar.
+func assertHSTS(h HSTSJar) {
+	later := time.Now().Add(time.Hour)
+	ut.AssertNil(h.Save("Example.com", HSTSPolicy{Expires: later, IncludeSubdomains: true}))
+	ut.AssertNil(h.Save("example.org", HSTSPolicy{Expires: later}))
+
+	ut.AssertTrue(h.Secure("example.com"))
+	ut.AssertTrue(h.Secure("www.example.com"))
+	ut.AssertTrue(h.Secure("example.org"))
+	ut.AssertFalse(h.Secure("www.example.org"))
+	ut.AssertFalse(h.Secure("example.net"))
+
+	ut.AssertNil(h.Save("example.com", HSTSPolicy{Expires: time.Now()}))
+	ut.AssertFalse(h.Secure("example.com"))
+}
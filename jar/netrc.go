@@ -0,0 +1,110 @@
+package jar
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// NetrcPath returns the path of the user's netrc file, which is the value of
+// the NETRC environment variable, or ~/.netrc when it's not set.
+func NetrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		if u, err := user.Current(); err == nil {
+			home = u.HomeDir
+		}
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// LoadNetrc saves the machine credentials found in the netrc file into the
+// credentials jar.
+//
+// The file at NetrcPath() is used when the file is empty, in which case a
+// missing file is not an error.
+func LoadNetrc(cj CredentialsJar, file string) error {
+	optional := file == ""
+	if optional {
+		file = NetrcPath()
+	}
+	fin, err := os.Open(file)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer fin.Close()
+	return ReadNetrc(cj, fin)
+}
+
+// ReadNetrc saves the machine credentials read from r, which contains netrc
+// formatted entries, into the credentials jar.
+//
+// The credentials are saved for every realm of the machine. The default entry
+// is ignored, since it would send the credentials to every host.
+func ReadNetrc(cj CredentialsJar, r io.Reader) error {
+	var (
+		machine string
+		creds   *Credentials
+		macdef  bool
+	)
+	save := func() {
+		if machine != "" && creds != nil {
+			cj.Save(machine, "", creds)
+		}
+		machine, creds = "", nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if macdef {
+			// Macro definitions end at the first empty line.
+			macdef = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				save()
+				machine, creds = value, &Credentials{}
+				i++
+			case "default":
+				save()
+			case "login":
+				if creds != nil {
+					creds.Username = value
+				}
+				i++
+			case "password":
+				if creds != nil {
+					creds.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				macdef = true
+				i = len(fields)
+			}
+		}
+	}
+	save()
+	return scanner.Err()
+}
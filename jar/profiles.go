@@ -0,0 +1,325 @@
+package jar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/haruyama/surf/errors"
+	"github.com/haruyama/surf/util"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ChromeOptions are used to decrypt the cookies of a Chrome profile.
+type ChromeOptions struct {
+	// Password is the password the encryption key is derived from. Defaults
+	// to "peanuts", which Chrome uses on Linux when no keyring is available.
+	// On macOS it's the "Chrome Safe Storage" password from the keychain.
+	Password string
+
+	// Iterations is the number of PBKDF2 iterations used to derive the key.
+	// Defaults to 1, which Chrome uses on Linux. Chrome uses 1003 on macOS.
+	Iterations int
+}
+
+// browserCookie is a cookie read from the database of a desktop browser.
+type browserCookie struct {
+	host     string
+	name     string
+	value    string
+	path     string
+	expires  time.Time
+	secure   bool
+	httpOnly bool
+}
+
+// ImportFirefoxCookies reads the cookies of a Firefox profile into the jar,
+// and returns the number of cookies imported.
+//
+// The profile is either the profile directory, or the path of its
+// cookies.sqlite file. The driver is the name of a registered database/sql
+// driver for SQLite, eg "sqlite3", which the caller must import. The database
+// is copied before it's read, so the browser may stay open.
+func ImportFirefoxCookies(dst http.CookieJar, driver, profile string) (int, error) {
+	file, err := findDatabase(profile, "cookies.sqlite")
+	if err != nil {
+		return 0, err
+	}
+	db, cleanup, err := openCopy(driver, file)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	rows, err := db.Query(
+		"SELECT host, name, value, path, expiry, isSecure, isHttpOnly FROM moz_cookies")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var cookies []browserCookie
+	for rows.Next() {
+		var c browserCookie
+		var expiry int64
+		err = rows.Scan(&c.host, &c.name, &c.value, &c.path, &expiry, &c.secure, &c.httpOnly)
+		if err != nil {
+			return 0, err
+		}
+		c.expires = firefoxTime(expiry)
+		cookies = append(cookies, c)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return importBrowserCookies(dst, cookies)
+}
+
+// ImportChromeCookies reads the cookies of a Chrome profile into the jar, and
+// returns the number of cookies imported.
+//
+// The profile is either the profile directory, eg ~/.config/google-chrome/Default,
+// or the path of its Cookies file. The driver is the name of a registered
+// database/sql driver for SQLite, which the caller must import. Encrypted
+// values are decrypted using the options, which may be nil to use the Linux
+// defaults. Cookies which cannot be decrypted are skipped.
+func ImportChromeCookies(dst http.CookieJar, driver, profile string, opts *ChromeOptions) (int, error) {
+	file, err := findDatabase(profile, filepath.Join("Network", "Cookies"), "Cookies")
+	if err != nil {
+		return 0, err
+	}
+	db, cleanup, err := openCopy(driver, file)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	// Since version 24, the decrypted value is prefixed with a hash of the host.
+	var version int
+	err = db.QueryRow("SELECT value FROM meta WHERE key = 'version'").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	key := chromeKey(opts)
+
+	rows, err := db.Query(
+		"SELECT host_key, name, value, encrypted_value, path, expires_utc, is_secure, is_httponly FROM cookies")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var cookies []browserCookie
+	for rows.Next() {
+		var c browserCookie
+		var encrypted []byte
+		var expires int64
+		err = rows.Scan(&c.host, &c.name, &c.value, &encrypted, &c.path, &expires, &c.secure, &c.httpOnly)
+		if err != nil {
+			return 0, err
+		}
+		if c.value == "" && len(encrypted) > 0 {
+			value, err := decryptChromeValue(key, encrypted)
+			if err != nil {
+				continue
+			}
+			if version >= 24 {
+				value = stripHostHash(c.host, value)
+			}
+			c.value = string(value)
+		}
+		c.expires = chromeTime(expires)
+		cookies = append(cookies, c)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return importBrowserCookies(dst, cookies)
+}
+
+// importBrowserCookies stores the unexpired cookies in the jar.
+func importBrowserCookies(dst http.CookieJar, cookies []browserCookie) (int, error) {
+	entries := make([]CookieEntry, 0, len(cookies))
+	now := time.Now()
+	for _, c := range cookies {
+		if !c.expires.IsZero() && !c.expires.After(now) {
+			continue
+		}
+		entries = append(entries, c.entry())
+	}
+	if err := ImportCookies(dst, entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// entry returns the cookie as though it was set by a response from its host.
+func (c browserCookie) entry() CookieEntry {
+	u := &url.URL{Scheme: "http", Host: strings.TrimPrefix(c.host, "."), Path: c.path}
+	if c.secure {
+		u.Scheme = "https"
+	}
+	cookie := &http.Cookie{
+		Name:     c.name,
+		Value:    c.value,
+		Path:     c.path,
+		Expires:  c.expires,
+		Secure:   c.secure,
+		HttpOnly: c.httpOnly,
+	}
+	if strings.HasPrefix(c.host, ".") {
+		cookie.Domain = c.host
+	}
+	return CookieEntry{URL: u.String(), Cookie: cookie}
+}
+
+// findDatabase returns the path of the database file, which is either the
+// profile itself, or the first of the names found in the profile directory.
+func findDatabase(profile string, names ...string) (string, error) {
+	info, err := os.Stat(profile)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return profile, nil
+	}
+	for _, name := range names {
+		file := filepath.Join(profile, name)
+		if util.FileExists(file) {
+			return file, nil
+		}
+	}
+	return "", errors.New("No cookie database found in '%s'.", profile)
+}
+
+// openCopy copies the database, along with its write-ahead log, to a
+// temporary directory and opens the copy, which avoids the lock held by a
+// running browser. The returned function closes and removes the copy.
+func openCopy(driver, file string) (*sql.DB, func(), error) {
+	dir, err := ioutil.TempDir("", "surf-cookies-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	dup := filepath.Join(dir, filepath.Base(file))
+	for _, suffix := range []string{"", "-wal"} {
+		if suffix != "" && !util.FileExists(file+suffix) {
+			continue
+		}
+		if err = copyFile(dup+suffix, file+suffix); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	db, err := sql.Open(driver, dup)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return db, func() { db.Close(); cleanup() }, nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(dst, src string) error {
+	fin, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+	fout, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(fout, fin); err != nil {
+		fout.Close()
+		return err
+	}
+	return fout.Close()
+}
+
+// firefoxTime returns the time of a Firefox expiry, which is in seconds since
+// the Unix epoch, or in milliseconds in recent versions.
+func firefoxTime(expiry int64) time.Time {
+	if expiry <= 0 {
+		return time.Time{}
+	}
+	if expiry > 1e11 {
+		return time.Unix(0, expiry*int64(time.Millisecond))
+	}
+	return time.Unix(expiry, 0)
+}
+
+// chromeEpochOffset is the number of seconds between 1601-01-01, the epoch of
+// Chrome timestamps, and the Unix epoch.
+const chromeEpochOffset = 11644473600
+
+// chromeTime returns the time of a Chrome timestamp, which is in microseconds
+// since 1601-01-01. Zero is a session cookie.
+func chromeTime(t int64) time.Time {
+	if t <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(t/1e6-chromeEpochOffset, (t%1e6)*1e3)
+}
+
+// chromeKey returns the key derived from the options.
+func chromeKey(opts *ChromeOptions) []byte {
+	password, iterations := "peanuts", 1
+	if opts != nil {
+		if opts.Password != "" {
+			password = opts.Password
+		}
+		if opts.Iterations > 0 {
+			iterations = opts.Iterations
+		}
+	}
+	return pbkdf2.Key([]byte(password), []byte("saltysalt"), iterations, 16, sha1.New)
+}
+
+// decryptChromeValue decrypts a value encrypted with the v10 or v11 scheme,
+// which is AES-128-CBC using an IV of 16 spaces.
+func decryptChromeValue(key, encrypted []byte) ([]byte, error) {
+	if !bytes.HasPrefix(encrypted, []byte("v10")) && !bytes.HasPrefix(encrypted, []byte("v11")) {
+		return nil, errors.New("Unsupported cookie encryption.")
+	}
+	encrypted = encrypted[3:]
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, errors.New("Invalid encrypted cookie length.")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(encrypted))
+	iv := bytes.Repeat([]byte(" "), aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encrypted)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, errors.New("Invalid encrypted cookie padding.")
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("Invalid encrypted cookie padding.")
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// stripHostHash removes the SHA-256 hash of the host which prefixes values
+// decrypted from databases since version 24.
+func stripHostHash(host string, value []byte) []byte {
+	sum := sha256.Sum256([]byte(host))
+	return bytes.TrimPrefix(value, sum[:])
+}
@@ -0,0 +1,78 @@
+package jar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/headzoo/ut"
+)
+
+func TestDecryptChromeValue(t *testing.T) {
+	ut.Run(t)
+	key := chromeKey(nil)
+	sum := sha256.Sum256([]byte(".example.com"))
+	plain := append(sum[:], []byte("secret")...)
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, bytes.Repeat([]byte(" "), aes.BlockSize)).CryptBlocks(encrypted, plain)
+
+	value, err := decryptChromeValue(key, append([]byte("v10"), encrypted...))
+	ut.AssertNil(err)
+	ut.AssertEquals("secret", string(stripHostHash(".example.com", value)))
+
+	_, err = decryptChromeValue(chromeKey(&ChromeOptions{Password: "wrong"}), append([]byte("v10"), encrypted...))
+	ut.AssertNotNil(err)
+	_, err = decryptChromeValue(key, []byte("AQAAAA"))
+	ut.AssertNotNil(err)
+}
+
+func TestImportBrowserCookies(t *testing.T) {
+	ut.Run(t)
+	ut.AssertEquals(time.Unix(1700000000, 0), chromeTime((1700000000+chromeEpochOffset)*1e6))
+	ut.AssertTrue(chromeTime(0).IsZero())
+	ut.AssertEquals(time.Unix(1700000000, 0), firefoxTime(1700000000))
+	ut.AssertEquals(time.Unix(1700000000, 0), firefoxTime(1700000000000))
+
+	future := time.Now().Add(time.Hour)
+	mc := NewMemoryCookies()
+	n, err := importBrowserCookies(mc, []browserCookie{
+		{host: ".example.com", name: "session", value: "joe", path: "/", expires: future, secure: true},
+		{host: "www.example.com", name: "theme", value: "dark", path: "/"},
+		{host: "www.example.com", name: "old", value: "x", path: "/", expires: time.Unix(1, 0)},
+	})
+	ut.AssertNil(err)
+	ut.AssertEquals(2, n)
+
+	u, _ := url.Parse("https://www.example.com/")
+	ut.AssertEquals(2, len(mc.Cookies(u)))
+	u, _ = url.Parse("http://api.example.com/")
+	ut.AssertEquals(0, len(mc.Cookies(u)))
+	u, _ = url.Parse("https://api.example.com/")
+	ut.AssertEquals(1, len(mc.Cookies(u)))
+}
+
+func TestFindDatabase(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf-profile-")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	_, err = findDatabase(dir, "cookies.sqlite")
+	ut.AssertNotNil(err)
+	ut.AssertNil(ioutil.WriteFile(dir+"/cookies.sqlite", nil, 0600))
+	file, err := findDatabase(dir, "cookies.sqlite")
+	ut.AssertNil(err)
+	ut.AssertEquals(dir+"/cookies.sqlite", file)
+	file, err = findDatabase(file, "cookies.sqlite")
+	ut.AssertNil(err)
+	ut.AssertEquals(dir+"/cookies.sqlite", file)
+}
@@ -0,0 +1,31 @@
+package jar
+
+// StateJar is a container for the state of the current page.
+type StateJar interface {
+	// Current returns the state of the current page, or nil when no page
+	// has been loaded.
+	Current() *State
+
+	// SetCurrent replaces the state of the current page.
+	SetCurrent(s *State)
+}
+
+// MemoryState is an in-memory implementation of StateJar.
+type MemoryState struct {
+	state *State
+}
+
+// NewMemoryState creates and returns a new *MemoryState type.
+func NewMemoryState() *MemoryState {
+	return &MemoryState{}
+}
+
+// Current returns the state of the current page.
+func (ms *MemoryState) Current() *State {
+	return ms.state
+}
+
+// SetCurrent replaces the state of the current page.
+func (ms *MemoryState) SetCurrent(s *State) {
+	ms.state = s
+}
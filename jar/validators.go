@@ -0,0 +1,46 @@
+package jar
+
+// Validator holds the validators of a response, which are sent back to the
+// server to make a conditional request.
+type Validator struct {
+	// ETag is the value of the ETag header.
+	ETag string
+
+	// LastModified is the value of the Last-Modified header.
+	LastModified string
+}
+
+// ValidatorJar is a container for the validators of visited URLs.
+type ValidatorJar interface {
+	// Save stores the validator of the URL, replacing its previous validator.
+	// Saving an empty validator removes the validator of the URL.
+	Save(u string, v Validator)
+
+	// Read returns the validator of the URL, and whether one was found.
+	Read(u string) (Validator, bool)
+}
+
+// MemoryValidators is an in-memory implementation of ValidatorJar.
+type MemoryValidators struct {
+	validators map[string]Validator
+}
+
+// NewMemoryValidators creates and returns a new *MemoryValidators type.
+func NewMemoryValidators() *MemoryValidators {
+	return &MemoryValidators{validators: make(map[string]Validator)}
+}
+
+// Save stores the validator of the URL.
+func (mv *MemoryValidators) Save(u string, v Validator) {
+	if v.ETag == "" && v.LastModified == "" {
+		delete(mv.validators, u)
+		return
+	}
+	mv.validators[u] = v
+}
+
+// Read returns the validator of the URL, and whether one was found.
+func (mv *MemoryValidators) Read(u string) (Validator, bool) {
+	v, ok := mv.validators[u]
+	return v, ok
+}
@@ -0,0 +1,175 @@
+// Package metrics exposes the statistics of browsers as Prometheus collectors.
+//
+// The package is optional, and surf itself does not depend on Prometheus.
+// Create a collector, register it, and instrument each browser:
+//
+//	c := metrics.NewCollector("scraper")
+//	prometheus.MustRegister(c)
+//	bow := surf.NewBrowser()
+//	c.Instrument(bow)
+//
+// Metrics may be broken down by the labels of the requests, which are set
+// with SetLabels() or RequestOptions, by naming them when creating the
+// collector. Requests without a label have an empty value for it.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/haruyama/surf/browser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects the statistics of the browsers it instruments.
+//
+// A single collector may instrument any number of browsers, in which case the
+// metrics are totals across all of them.
+type Collector struct {
+	labels   []string
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	bytes    prometheus.Counter
+	inFlight prometheus.Gauge
+	pages    *prometheus.CounterVec
+	assets   *prometheus.CounterVec
+}
+
+// NewCollector creates and returns a new *Collector type, naming its metrics
+// with the given namespace, which may be empty.
+//
+// The request, error, latency and page metrics are broken down by the given
+// request labels.
+func NewCollector(namespace string, labels ...string) *Collector {
+	return &Collector{
+		labels: labels,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "requests_total",
+			Help:      "Number of responses received, by method and status code.",
+		}, append([]string{"method", "code"}, labels...)),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "request_errors_total",
+			Help:      "Number of requests which failed without a response.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "request_duration_seconds",
+			Help:      "Time until the response headers were received, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, append([]string{"method"}, labels...)),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "response_bytes_total",
+			Help:      "Number of response body bytes read.",
+		}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "requests_in_flight",
+			Help:      "Number of requests waiting for a response.",
+		}),
+		pages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "pages_total",
+			Help:      "Number of pages loaded.",
+		}, labels),
+		assets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "surf",
+			Name:      "asset_downloads_total",
+			Help:      "Number of asset downloads, by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Instrument adds a middleware and event handlers to the browser, which
+// update the metrics of the collector.
+func (c *Collector) Instrument(bow browser.Browsable) {
+	bow.Use(c.middleware)
+	bow.On(browser.PageLoaded, func(e *browser.Event) error {
+		c.pages.WithLabelValues(c.values(e.Labels)...).Inc()
+		return nil
+	})
+	bow.On(browser.AssetDownloadComplete, func(e *browser.Event) error {
+		c.assets.WithLabelValues("complete").Inc()
+		return nil
+	})
+	bow.On(browser.AssetDownloadError, func(e *browser.Event) error {
+		c.assets.WithLabelValues("error").Inc()
+		return nil
+	})
+}
+
+// Describe sends the descriptions of the metrics to the channel.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.errors.Describe(ch)
+	c.latency.Describe(ch)
+	c.bytes.Describe(ch)
+	c.inFlight.Describe(ch)
+	c.pages.Describe(ch)
+	c.assets.Describe(ch)
+}
+
+// Collect sends the current values of the metrics to the channel.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.errors.Collect(ch)
+	c.latency.Collect(ch)
+	c.bytes.Collect(ch)
+	c.inFlight.Collect(ch)
+	c.pages.Collect(ch)
+	c.assets.Collect(ch)
+}
+
+// middleware records the metrics of each request sent by a browser.
+func (c *Collector) middleware(next browser.RoundTripFunc) browser.RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		c.inFlight.Inc()
+		defer c.inFlight.Dec()
+		start := time.Now()
+		values := c.values(browser.Labels(req))
+		resp, err := next(req)
+		if err != nil {
+			c.errors.WithLabelValues(values...).Inc()
+			return nil, err
+		}
+		c.latency.WithLabelValues(append([]string{req.Method}, values...)...).Observe(time.Since(start).Seconds())
+		code := strconv.Itoa(resp.StatusCode)
+		c.requests.WithLabelValues(append([]string{req.Method, code}, values...)...).Inc()
+		resp.Body = &countingBody{ReadCloser: resp.Body, bytes: c.bytes}
+		return resp, nil
+	}
+}
+
+// values returns the values of the labels of the collector.
+func (c *Collector) values(labels map[string]string) []string {
+	values := make([]string, len(c.labels))
+	for i, name := range c.labels {
+		values[i] = labels[name]
+	}
+	return values
+}
+
+// countingBody adds the number of bytes read from a response body to a counter.
+type countingBody struct {
+	io.ReadCloser
+	bytes prometheus.Counter
+}
+
+// Read reads from the body, and counts the bytes read.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes.Add(float64(n))
+	return n, err
+}
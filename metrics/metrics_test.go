@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haruyama/surf"
+	"github.com/haruyama/surf/browser"
+	"github.com/headzoo/ut"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, "<html><body>hello</body></html>")
+	}))
+	defer ts.Close()
+
+	c := NewCollector("test")
+	reg := prometheus.NewRegistry()
+	ut.AssertNil(reg.Register(c))
+
+	bow := surf.NewBrowser()
+	c.Instrument(bow)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL + "/missing"))
+	ut.AssertNotNil(bow.Open("http://127.0.0.1:1/"))
+
+	ut.AssertEquals(1.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "200")))
+	ut.AssertEquals(1.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "404")))
+	ut.AssertEquals(1.0, testutil.ToFloat64(c.errors))
+	ut.AssertEquals(2.0, testutil.ToFloat64(c.pages))
+	ut.AssertEquals(0.0, testutil.ToFloat64(c.inFlight))
+	ut.AssertTrue(testutil.ToFloat64(c.bytes) >= 31)
+	ut.AssertEquals(1, testutil.CollectAndCount(c, "test_surf_request_duration_seconds"))
+}
+
+func TestCollectorLabels(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><body>hello</body></html>")
+	}))
+	defer ts.Close()
+
+	c := NewCollector("test", "job")
+	bow := surf.NewBrowser()
+	c.Instrument(bow)
+	bow.SetLabels(map[string]string{"job": "a"})
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.OpenWithOptions(ts.URL, browser.RequestOptions{Labels: map[string]string{"job": "b"}}))
+	ut.AssertNil(bow.Open(ts.URL))
+
+	ut.AssertEquals(2.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "200", "a")))
+	ut.AssertEquals(1.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "200", "b")))
+	ut.AssertEquals(2.0, testutil.ToFloat64(c.pages.WithLabelValues("a")))
+	ut.AssertEquals(1.0, testutil.ToFloat64(c.pages.WithLabelValues("b")))
+}
@@ -0,0 +1,246 @@
+// Package schedule decides when the pages of a monitoring crawl should be
+// fetched again, based on how often their content has changed.
+//
+// Each visit of a page is recorded with a hash of its content. The revisit
+// interval of the page is halved each time its content has changed, and
+// doubled each time it has not, within the bounds of the scheduler:
+//
+//	s := schedule.New(time.Hour, 7*24*time.Hour)
+//	s.Add("http://www.reddit.com/r/golang")
+//	bow := surf.NewBrowser()
+//	s.Attach(bow)
+//	for _, u := range s.Due() {
+//		bow.Open(u)
+//	}
+package schedule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haruyama/surf/browser"
+)
+
+// Entry holds the visits of a URL.
+type Entry struct {
+	// URL is the URL of the page.
+	URL string `json:"url"`
+
+	// Hash is the hash of the content of the page at the last visit, or empty
+	// when the page has not been visited.
+	Hash string `json:"hash"`
+
+	// Visits is the number of visits.
+	Visits int `json:"visits"`
+
+	// Changes is the number of visits which found the content changed since
+	// the visit before.
+	Changes int `json:"changes"`
+
+	// LastVisit is when the page was last visited.
+	LastVisit time.Time `json:"last_visit"`
+
+	// LastChange is when the content of the page was last found changed.
+	LastChange time.Time `json:"last_change"`
+
+	// Interval is the time between visits.
+	Interval time.Duration `json:"interval"`
+
+	// Next is when the page is due to be visited again.
+	Next time.Time `json:"next"`
+}
+
+// ChangeRate returns the fraction of the visits after the first which found
+// the content changed, or zero when the page has been visited at most once.
+func (e Entry) ChangeRate() float64 {
+	if e.Visits < 2 {
+		return 0
+	}
+	return float64(e.Changes) / float64(e.Visits-1)
+}
+
+// Scheduler records the visits of URLs, and computes when each is due to be
+// visited again. It's safe for concurrent use.
+type Scheduler struct {
+	// min is the shortest interval between visits.
+	min time.Duration
+
+	// max is the longest interval between visits.
+	max time.Duration
+
+	// mutex protects entries.
+	mutex sync.Mutex
+
+	// entries are the entries of the URLs keyed by URL.
+	entries map[string]*Entry
+}
+
+// New creates and returns a new *Scheduler type, which revisits pages no
+// more often than min, and no less often than max.
+func New(min, max time.Duration) *Scheduler {
+	if max < min {
+		max = min
+	}
+	return &Scheduler{
+		min:     min,
+		max:     max,
+		entries: make(map[string]*Entry),
+	}
+}
+
+// Add adds the URL, which is due at once, unless it has already been added.
+func (s *Scheduler) Add(u string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.entries[u]; !ok {
+		s.entries[u] = &Entry{URL: u, Interval: s.min}
+	}
+}
+
+// Remove removes the URL.
+func (s *Scheduler) Remove(u string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, u)
+}
+
+// Visit records a visit of the URL which found the given content, adding the
+// URL when needed, and returns the updated entry.
+//
+// The first visit schedules the next after the shortest interval. Later
+// visits halve the interval when the content has changed, and double it when
+// it has not.
+func (s *Scheduler) Visit(u string, content []byte) Entry {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[u]
+	if !ok {
+		e = &Entry{URL: u, Interval: s.min}
+		s.entries[u] = e
+	}
+	switch {
+	case e.Visits == 0:
+		e.Interval = s.min
+		e.LastChange = now
+	case e.Hash != hash:
+		e.Changes++
+		e.LastChange = now
+		e.Interval /= 2
+	default:
+		e.Interval *= 2
+	}
+	if e.Interval < s.min {
+		e.Interval = s.min
+	}
+	if e.Interval > s.max {
+		e.Interval = s.max
+	}
+	e.Hash = hash
+	e.Visits++
+	e.LastVisit = now
+	e.Next = now.Add(e.Interval)
+	return *e
+}
+
+// VisitPage records a visit of the page. The visible text of HTML pages is
+// hashed, so that changes to markup, such as tokens in attributes, are not
+// counted as changes of the content. The raw body of other pages is hashed.
+func (s *Scheduler) VisitPage(p *browser.Page) (Entry, error) {
+	content := []byte(strings.Join(strings.Fields(p.Find("body").Text()), " "))
+	if len(content) == 0 {
+		body, err := p.RawBody()
+		if err != nil {
+			return Entry{}, err
+		}
+		content = body
+	}
+	return s.Visit(p.Url().String(), content), nil
+}
+
+// Attach records a visit of each page loaded by the browser.
+func (s *Scheduler) Attach(bow browser.Browsable) {
+	bow.On(browser.PageLoaded, func(e *browser.Event) error {
+		s.VisitPage(bow.Page())
+		return nil
+	})
+}
+
+// Entry returns the entry of the URL, and whether the URL has been added.
+func (s *Scheduler) Entry(u string) (Entry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.entries[u]; ok {
+		return *e, true
+	}
+	return Entry{}, false
+}
+
+// Entries returns the entries of every URL, which may be saved and passed to
+// Restore() to carry on the schedule in another session.
+func (s *Scheduler) Entries() []Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, *e)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// Restore adds the entries, replacing the entries of the same URLs.
+func (s *Scheduler) Restore(entries []Entry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, e := range entries {
+		e := e
+		s.entries[e.URL] = &e
+	}
+}
+
+// Due returns the URLs which are due to be visited, the longest overdue first.
+func (s *Scheduler) Due() []string {
+	now := time.Now()
+	s.mutex.Lock()
+	var due []Entry
+	for _, e := range s.entries {
+		if !e.Next.After(now) {
+			due = append(due, *e)
+		}
+	}
+	s.mutex.Unlock()
+	sortEntries(due)
+	urls := make([]string, len(due))
+	for i, e := range due {
+		urls[i] = e.URL
+	}
+	return urls
+}
+
+// Next returns the entry of the URL which is due to be visited first, and
+// false when no URL has been added.
+func (s *Scheduler) Next() (Entry, bool) {
+	entries := s.Entries()
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+	return entries[0], true
+}
+
+// sortEntries sorts the entries by the time they are due, then by URL.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Next.Equal(entries[j].Next) {
+			return entries[i].Next.Before(entries[j].Next)
+		}
+		return entries[i].URL < entries[j].URL
+	})
+}
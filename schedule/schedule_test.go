@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haruyama/surf"
+	"github.com/headzoo/ut"
+)
+
+func TestScheduler(t *testing.T) {
+	ut.Run(t)
+	s := New(time.Minute, 8*time.Minute)
+	s.Add("http://a.com/")
+	ut.AssertEquals([]string{"http://a.com/"}, s.Due())
+
+	e := s.Visit("http://a.com/", []byte("one"))
+	ut.AssertEquals(1, e.Visits)
+	ut.AssertEquals(time.Minute, e.Interval)
+	ut.AssertEquals(0, len(s.Due()))
+
+	e = s.Visit("http://a.com/", []byte("one"))
+	ut.AssertEquals(2*time.Minute, e.Interval)
+	e = s.Visit("http://a.com/", []byte("one"))
+	ut.AssertEquals(4*time.Minute, e.Interval)
+	e = s.Visit("http://a.com/", []byte("one"))
+	e = s.Visit("http://a.com/", []byte("one"))
+	ut.AssertEquals(8*time.Minute, e.Interval)
+	ut.AssertEquals(0.0, e.ChangeRate())
+
+	e = s.Visit("http://a.com/", []byte("two"))
+	ut.AssertEquals(4*time.Minute, e.Interval)
+	ut.AssertEquals(1, e.Changes)
+	ut.AssertEquals(e.LastVisit, e.LastChange)
+	ut.AssertEquals(0.2, e.ChangeRate())
+	e = s.Visit("http://a.com/", []byte("three"))
+	e = s.Visit("http://a.com/", []byte("four"))
+	e = s.Visit("http://a.com/", []byte("five"))
+	ut.AssertEquals(time.Minute, e.Interval)
+
+	s.Add("http://b.com/")
+	s.Add("http://a.com/")
+	next, ok := s.Next()
+	ut.AssertTrue(ok)
+	ut.AssertEquals("http://b.com/", next.URL)
+	ut.AssertEquals([]string{"http://b.com/"}, s.Due())
+
+	restored := New(time.Minute, 8*time.Minute)
+	entries := s.Entries()
+	entries[1].Next = time.Now().Add(-time.Hour)
+	restored.Restore(entries)
+	ut.AssertEquals([]string{"http://b.com/", "http://a.com/"}, restored.Due())
+	restored.Remove("http://a.com/")
+	_, ok = restored.Entry("http://a.com/")
+	ut.AssertFalse(ok)
+}
+
+func TestSchedulerAttach(t *testing.T) {
+	ut.Run(t)
+	version := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `<html><body><input name="token" value="%d">Version %d</body></html>`, time.Now().UnixNano(), version)
+	}))
+	defer ts.Close()
+
+	s := New(time.Minute, time.Hour)
+	bow := surf.NewBrowser()
+	s.Attach(bow)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL))
+	e, ok := s.Entry(ts.URL)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(2, e.Visits)
+	ut.AssertEquals(0, e.Changes)
+
+	version = 2
+	ut.AssertNil(bow.Open(ts.URL))
+	e, _ = s.Entry(ts.URL)
+	ut.AssertEquals(1, e.Changes)
+}
@@ -0,0 +1,239 @@
+// Package signer contains request signers for use with Browser.SetSigner().
+package signer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// awsAlgorithm is the name of the AWS signing algorithm.
+	awsAlgorithm = "AWS4-HMAC-SHA256"
+
+	// awsTimeFormat is the format of the X-Amz-Date header.
+	awsTimeFormat = "20060102T150405Z"
+
+	// unsignedPayload is sent in place of the payload hash when the body is
+	// not signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// AWSV4 signs requests using AWS Signature Version 4.
+type AWSV4 struct {
+	// AccessKeyID is the AWS access key ID.
+	AccessKeyID string
+
+	// SecretAccessKey is the AWS secret access key.
+	SecretAccessKey string
+
+	// SessionToken is the session token for temporary credentials. May be empty.
+	SessionToken string
+
+	// Region is the AWS region, eg "us-east-1".
+	Region string
+
+	// Service is the AWS service name, eg "s3" or "execute-api".
+	Service string
+
+	// UnsignedPayload skips hashing the request body, which would otherwise
+	// be buffered in memory. Only some services, such as S3, accept it.
+	UnsignedPayload bool
+
+	// Now returns the signing time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewAWSV4 creates and returns a new *AWSV4 type.
+func NewAWSV4(accessKeyID, secretAccessKey, region, service string) *AWSV4 {
+	return &AWSV4{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Region:          region,
+		Service:         service,
+	}
+}
+
+// Sign adds the X-Amz-Date and Authorization headers to the request.
+//
+// The host, Content-Type, and every X-Amz-* header are signed. Unless
+// UnsignedPayload is set, the request body is read into memory to be hashed
+// and replaced with a copy.
+func (s *AWSV4) Sign(req *http.Request) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format(awsTimeFormat)
+
+	payloadHash := unsignedPayload
+	if !s.UnsignedPayload {
+		body, err := readBody(req)
+		if err != nil {
+			return err
+		}
+		payloadHash = hashHex(body)
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signedHeaders := s.canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format("20060102"), s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		awsAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsAlgorithm, s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalURI returns the encoded request path. Paths are encoded twice for
+// every service except S3.
+func (s *AWSV4) canonicalURI(req *http.Request) string {
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	path = uriEncode(path, false)
+	if s.Service != "s3" {
+		path = uriEncode(path, false)
+	}
+	return path
+}
+
+// canonicalHeaders returns the canonical headers and the signed headers list.
+func (s *AWSV4) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, vals := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, len(vals))
+			for i, v := range vals {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			values[lower] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buff bytes.Buffer
+	for _, name := range names {
+		buff.WriteString(name + ":" + values[name] + "\n")
+	}
+	return buff.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery returns the query parameters sorted and encoded.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for key, vals := range query {
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent encodes every byte except the unreserved characters, and
+// the slash unless encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var buff bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buff.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buff.WriteByte(c)
+		default:
+			fmt.Fprintf(&buff, "%%%02X", c)
+		}
+	}
+	return buff.String()
+}
+
+// readBody returns a copy of the request body, leaving the body readable.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+// hashHex returns the hex encoded SHA-256 digest of the data.
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data using the key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,46 @@
+package signer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/headzoo/ut"
+)
+
+// newTestSigner returns a signer using the credentials from the AWS
+// Signature Version 4 test suite.
+func newTestSigner() *AWSV4 {
+	s := NewAWSV4("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service")
+	s.Now = func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	}
+	return s
+}
+
+func TestAWSV4GetVanilla(t *testing.T) {
+	ut.Run(t)
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+	err := newTestSigner().Sign(req)
+	ut.AssertNil(err)
+	ut.AssertEquals("20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	ut.AssertEquals("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, "+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestAWSV4PostBody(t *testing.T) {
+	ut.Run(t)
+	req, _ := http.NewRequest("POST", "https://example.amazonaws.com/", strings.NewReader("Param1=value1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := newTestSigner().Sign(req)
+	ut.AssertNil(err)
+	ut.AssertEquals("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		req.Header.Get("Authorization"))
+}
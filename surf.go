@@ -19,6 +19,39 @@ var (
 
 	// DefaultFollowRedirectsAttribute is the global value for the AttributeFollowRedirects attribute.
 	DefaultFollowRedirects = true
+
+	// DefaultDisableKeepAlives is the global value for the DisableKeepAlives attribute.
+	DefaultDisableKeepAlives = false
+
+	// DefaultProxyFromEnvironment is the global value for the ProxyFromEnvironment attribute.
+	DefaultProxyFromEnvironment = true
+
+	// DefaultFollowAltSvc is the global value for the FollowAltSvc attribute.
+	DefaultFollowAltSvc = false
+
+	// DefaultConditionalRequests is the global value for the ConditionalRequests attribute.
+	DefaultConditionalRequests = false
+
+	// DefaultRetryTransportErrors is the global value for the RetryTransportErrors attribute.
+	DefaultRetryTransportErrors = true
+
+	// DefaultValidateForms is the global value for the ValidateForms attribute.
+	DefaultValidateForms = false
+
+	// DefaultHTTPOnly is the global value for the HTTPOnly attribute.
+	DefaultHTTPOnly = false
+
+	// DefaultRefetchOnBack is the global value for the RefetchOnBack attribute.
+	DefaultRefetchOnBack = false
+
+	// DefaultPreemptiveAuth is the global value for the PreemptiveAuth attribute.
+	DefaultPreemptiveAuth = true
+
+	// DefaultRetryAuthChallenges is the global value for the RetryAuthChallenges attribute.
+	DefaultRetryAuthChallenges = true
+
+	// DefaultLenientParsing is the global value for the LenientParsing attribute.
+	DefaultLenientParsing = false
 )
 
 // NewBrowser creates and returns a *browser.Browser type.
@@ -28,11 +61,26 @@ func NewBrowser() *browser.Browser {
 	bow.SetCookieJar(jar.NewMemoryCookies())
 	bow.SetBookmarksJar(jar.NewMemoryBookmarks())
 	bow.SetHistoryJar(jar.NewMemoryHistory())
+	bow.SetStateJar(jar.NewMemoryState())
 	bow.SetHeadersJar(jar.NewMemoryHeaders())
+	bow.SetCredentialsJar(jar.NewMemoryCredentials())
+	bow.SetHSTSJar(jar.NewMemoryHSTS())
+	bow.SetValidatorJar(jar.NewMemoryValidators())
 	bow.SetAttributes(browser.AttributeMap{
-		browser.SendReferer:         DefaultSendReferer,
-		browser.MetaRefreshHandling: DefaultMetaRefreshHandling,
-		browser.FollowRedirects:     DefaultFollowRedirects,
+		browser.SendReferer:          DefaultSendReferer,
+		browser.MetaRefreshHandling:  DefaultMetaRefreshHandling,
+		browser.FollowRedirects:      DefaultFollowRedirects,
+		browser.DisableKeepAlives:    DefaultDisableKeepAlives,
+		browser.ProxyFromEnvironment: DefaultProxyFromEnvironment,
+		browser.FollowAltSvc:         DefaultFollowAltSvc,
+		browser.ConditionalRequests:  DefaultConditionalRequests,
+		browser.RetryTransportErrors: DefaultRetryTransportErrors,
+		browser.ValidateForms:        DefaultValidateForms,
+		browser.HTTPOnly:             DefaultHTTPOnly,
+		browser.RefetchOnBack:        DefaultRefetchOnBack,
+		browser.PreemptiveAuth:       DefaultPreemptiveAuth,
+		browser.RetryAuthChallenges:  DefaultRetryAuthChallenges,
+		browser.LenientParsing:       DefaultLenientParsing,
 	})
 
 	return bow
@@ -1,15 +1,40 @@
 package surf
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/haruyama/surf/agent"
 	"github.com/haruyama/surf/browser"
+	surferrors "github.com/haruyama/surf/errors"
 	"github.com/haruyama/surf/jar"
 	"github.com/headzoo/ut"
+	"golang.org/x/net/html"
 )
 
 func TestGet(t *testing.T) {
@@ -60,6 +85,170 @@ func TestDownload(t *testing.T) {
 	ut.AssertEquals(int(l), buff.Len())
 }
 
+func TestDownloadVerbatim(t *testing.T) {
+	ut.Run(t)
+	page := "<html><body><p title=a&amp;b>Hello&nbsp;Surf</p></body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, page)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	for _, threshold := range []int64{0, 10} {
+		bow := NewBrowser()
+		bow.SetSpoolThreshold(threshold, dir)
+		ut.AssertNil(bow.Open(ts.URL))
+		ut.AssertEquals("Hello\u00a0Surf", bow.Find("p").Text())
+
+		buff := &bytes.Buffer{}
+		l, err := bow.Download(buff)
+		ut.AssertNil(err)
+		ut.AssertEquals(len(page), int(l))
+		ut.AssertEquals(page, buff.String())
+
+		buff.Reset()
+		_, err = bow.DownloadWithOptions(buff, browser.DownloadOptions{})
+		ut.AssertNil(err)
+		ut.AssertContains(`<p title="a&amp;b">`, buff.String())
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, len(files))
+
+	// Spooled bodies are removed once their page leaves the history, and
+	// when the browser is closed.
+	bow := NewBrowser()
+	bow.SetSpoolThreshold(10, dir)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL))
+	files, _ = ioutil.ReadDir(dir)
+	ut.AssertEquals(3, len(files))
+	ut.AssertTrue(bow.Back())
+	files, _ = ioutil.ReadDir(dir)
+	ut.AssertEquals(2, len(files))
+	ut.AssertNil(bow.Close())
+	files, _ = ioutil.ReadDir(dir)
+	ut.AssertEquals(1, len(files))
+}
+
+func TestDownloadSanitized(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<html><head><style>p{}</style></head><body>`+
+			`<p onclick="steal()" class="x">Hello</p><a href="javascript:go()">go</a>`+
+			`<a href="java&#09;script:go()">tab</a><a href=" &#01;JavaScript:go()">ctl</a>`+
+			`<form><button formaction="javascript:go()">b</button></form>`+
+			`<img srcset="/a.png 1x, javascript:go() 2x"><video poster="vbscript:go()"></video>`+
+			`<svg><a xlink:href="javascript:go()">svg</a></svg>`+
+			`<a href="/rel">rel</a><a href="https://example.com/">abs</a><a href="mailto:a@example.com">mail</a>`+
+			`<script>evil()</script></body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+
+	buff := &bytes.Buffer{}
+	_, err := bow.DownloadWithOptions(buff, browser.DownloadOptions{
+		Sanitize: browser.DefaultSanitizePolicy,
+	})
+	ut.AssertNil(err)
+	ut.AssertContains(`<p class="x">Hello</p><a>go</a><a>tab</a><a>ctl</a>`, buff.String())
+	ut.AssertContains(`<button>b</button>`, buff.String())
+	ut.AssertContains(`<img/><video></video>`, buff.String())
+	ut.AssertContains(`<a href="/rel">rel</a><a href="https://example.com/">abs</a><a href="mailto:a@example.com">mail</a>`, buff.String())
+	ut.AssertFalse(strings.Contains(strings.ToLower(buff.String()), "script:"))
+	ut.AssertFalse(strings.Contains(buff.String(), "evil"))
+	ut.AssertFalse(strings.Contains(buff.String(), "<style>"))
+
+	// The current page must not be modified.
+	ut.AssertEquals(1, bow.Find("script").Length())
+	ut.AssertEquals("steal()", bow.Find("p").AttrOr("onclick", ""))
+}
+
+func TestDownloadFormat(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><head><title>T</title></head><body>\n  <!-- note -->\n  <p>Hello,   <b>Surf</b>!</p>\n  <pre> a\n b</pre>\n</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+
+	buff := &bytes.Buffer{}
+	_, err := bow.DownloadWithOptions(buff, browser.DownloadOptions{Format: browser.FormatMinify})
+	ut.AssertNil(err)
+	ut.AssertEquals("<html><head><title>T</title></head><body> <p>Hello, <b>Surf</b>!</p> <pre> a\n b</pre> </body></html>", buff.String())
+
+	buff.Reset()
+	_, err = bow.DownloadWithOptions(buff, browser.DownloadOptions{Format: browser.FormatIndent})
+	ut.AssertNil(err)
+	ut.AssertEquals("<html>\n  <head>\n    <title>\n      T\n    </title>\n  </head>\n  <body>\n    <!-- note -->\n    <p>\n      Hello,\n      <b>\n        Surf\n      </b>\n      !\n    </p>\n    <pre> a\n b</pre>\n  </body>\n</html>", buff.String())
+}
+
+func TestFetchAll(t *testing.T) {
+	ut.Run(t)
+	var mu sync.Mutex
+	active, peak := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, "<html><head><title>"+req.URL.Path+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	urls := []string{ts.URL + "/a", ts.URL + "/b", ts.URL + "/missing", "http://127.0.0.1:1/", ts.URL + "/c"}
+	agents := 0
+	results := FetchAll(func() *browser.Browser {
+		mu.Lock()
+		agents++
+		mu.Unlock()
+		return NewBrowser()
+	}, urls, 2)
+
+	ut.AssertEquals(len(urls), len(results))
+	ut.AssertEquals(2, agents)
+	ut.AssertEquals(2, peak)
+	ut.AssertEquals("/a", results[0].Page.Title())
+	ut.AssertEquals("/c", results[4].Page.Title())
+	ut.AssertEquals(http.StatusNotFound, results[2].Page.StatusCode())
+	ut.AssertNotNil(results[3].Err)
+	ut.AssertTrue(results[3].Page == nil)
+	ut.AssertEquals(urls[3], results[3].URL)
+
+	// The pages may be inspected by several goroutines at once.
+	var wg sync.WaitGroup
+	for _, r := range results {
+		if r.Page == nil {
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(page *browser.Page) {
+				defer wg.Done()
+				ut.AssertEquals(0, len(page.Links()))
+			}(r.Page)
+		}
+	}
+	wg.Wait()
+}
+
 func TestUserAgent(t *testing.T) {
 	ut.Run(t)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -74,6 +263,186 @@ func TestUserAgent(t *testing.T) {
 	ut.AssertEquals("Testing/1.0", bow.Body())
 }
 
+func TestEmulate(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		fmt.Fprintf(gz, "%s|%s|%s", req.UserAgent(), req.Header.Get("sec-ch-ua-mobile"), req.Header.Get("Accept-Encoding"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.Emulate(agent.ChromeMobile)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(agent.ChromeMobile.UserAgent+"|?1|gzip, deflate, br", bow.Body())
+}
+
+// serveHeaderNames answers the requests sent to the listener with a page
+// titled with the names of the request headers in the order they were sent,
+// the Sec-Fetch-Site and Sec-Fetch-User headers, and the request body.
+func serveHeaderNames(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var raw bytes.Buffer
+			r := bufio.NewReader(io.TeeReader(conn, &raw))
+			for {
+				raw.Reset()
+				req, err := http.ReadRequest(r)
+				if err != nil {
+					return
+				}
+				body, _ := ioutil.ReadAll(req.Body)
+				var names []string
+				for _, line := range strings.Split(raw.String(), "\r\n")[1:] {
+					if line == "" {
+						break
+					}
+					names = append(names, strings.SplitN(line, ":", 2)[0])
+				}
+				page := fmt.Sprintf(`<title>%s|%s|%s|%s</title><a href="/next">next</a>`,
+					strings.Join(names, ","), req.Header.Get("Sec-Fetch-Site"), req.Header.Get("Sec-Fetch-User"), body)
+				resp := &http.Response{
+					StatusCode:    http.StatusOK,
+					ProtoMajor:    1,
+					ProtoMinor:    1,
+					Header:        http.Header{"Content-Type": {"text/html"}},
+					ContentLength: int64(len(page)),
+					Body:          ioutil.NopCloser(strings.NewReader(page)),
+				}
+				if resp.Write(conn) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestHeaderOrder(t *testing.T) {
+	ut.Run(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ut.AssertNil(err)
+	defer ln.Close()
+	go serveHeaderNames(ln)
+	u := "http://" + ln.Addr().String()
+
+	bow := NewBrowser()
+	bow.Emulate(agent.ChromeDesktop)
+	ut.AssertNil(bow.Open(u))
+	ut.AssertEquals("Host,Sec-Ch-Ua,Sec-Ch-Ua-Mobile,Sec-Ch-Ua-Platform,Upgrade-Insecure-Requests,User-Agent,Accept,Sec-Fetch-Site,Sec-Fetch-Mode,Sec-Fetch-User,Sec-Fetch-Dest,Accept-Encoding,Accept-Language|none|?1|", bow.Title())
+	ut.AssertNil(bow.Click("a"))
+	ut.AssertEquals("Host,Sec-Ch-Ua,Sec-Ch-Ua-Mobile,Sec-Ch-Ua-Platform,Upgrade-Insecure-Requests,User-Agent,Accept,Sec-Fetch-Site,Sec-Fetch-Mode,Sec-Fetch-User,Sec-Fetch-Dest,Referer,Accept-Encoding,Accept-Language|same-origin|?1|", bow.Title())
+	ut.AssertNil(bow.Reload())
+	ut.AssertEquals("same-origin||", strings.SplitN(bow.Title(), "|", 2)[1])
+
+	// The bodies are passed through, and the requests which follow them on
+	// the connection are still ordered.
+	ut.AssertNil(bow.Post(u, "text/plain", io.MultiReader(strings.NewReader("chunked"))))
+	ut.AssertEquals("Host,Sec-Ch-Ua,Sec-Ch-Ua-Mobile,Sec-Ch-Ua-Platform,Upgrade-Insecure-Requests,Content-Type,User-Agent,Accept,Sec-Fetch-Site,Sec-Fetch-Mode,Sec-Fetch-User,Sec-Fetch-Dest,Accept-Encoding,Accept-Language,Transfer-Encoding|none|?1|chunked", bow.Title())
+	ut.AssertNil(bow.Post(u, "text/plain", strings.NewReader("sized")))
+	ut.AssertTrue(strings.HasPrefix(bow.Title(), "Host,Content-Length,Sec-Ch-Ua,"))
+	ut.AssertTrue(strings.HasSuffix(bow.Title(), "|none|?1|sized"))
+	ut.AssertNil(bow.Open(u))
+	ut.AssertTrue(strings.HasPrefix(bow.Title(), "Host,Sec-Ch-Ua,"))
+
+	bow.Emulate(agent.SafariDesktop)
+	ut.AssertNil(bow.Open(u))
+	ut.AssertTrue(strings.HasSuffix(bow.Title(), "|none||"))
+	ut.AssertTrue(strings.HasPrefix(bow.Title(), "Host,Accept,Sec-Fetch-Site,Sec-Fetch-Dest,"))
+}
+
+func TestHeaderOrderTLS(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	ut.AssertNil(err)
+	defer ln.Close()
+	go serveHeaderNames(ln)
+
+	bow := NewBrowser()
+	bow.Emulate(agent.FirefoxDesktop)
+	config := ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	config.ServerName = "example.com"
+	bow.SetDialTLSContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return tls.Dial(network, ln.Addr().String(), config)
+	})
+	ut.AssertNil(bow.Open("https://example.com/"))
+	ut.AssertEquals("Host,User-Agent,Accept,Accept-Language,Accept-Encoding,Upgrade-Insecure-Requests,Sec-Fetch-Dest,Sec-Fetch-Mode,Sec-Fetch-Site,Sec-Fetch-User|none|?1|", bow.Title())
+}
+
+func TestSigner(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.Header.Get("X-Signature"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.AddRequestHeader("X-Testing", "Testing")
+	bow.SetSigner(browser.SignerFunc(func(req *http.Request) error {
+		req.Header.Set("X-Signature", req.Method+" "+req.Header.Get("X-Testing"))
+		return nil
+	}))
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("GET Testing", bow.Body())
+}
+
+func TestRequestOptions(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/redirect":
+			http.Redirect(w, req, "/", http.StatusFound)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("x", 1024))
+		default:
+			fmt.Fprint(w, req.Method+":"+req.Header.Get("X-Token")+":"+req.Referer())
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	opts := browser.RequestOptions{
+		Header:  http.Header{"X-Token": {"abc"}},
+		Referer: "http://example.com/",
+	}
+	ut.AssertNil(bow.OpenWithOptions(ts.URL, opts))
+	ut.AssertEquals("GET:abc:http://example.com/", bow.Body())
+	ut.AssertNil(bow.PostWithOptions(ts.URL, "text/plain", strings.NewReader("body"), opts))
+	ut.AssertEquals("POST:abc:http://example.com/", bow.Body())
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("GET::", bow.Body())
+
+	ut.AssertNil(bow.OpenWithOptions(ts.URL+"/redirect", browser.RequestOptions{NoFollowRedirects: true}))
+	ut.AssertEquals(302, bow.StatusCode())
+	ut.AssertNil(bow.Open(ts.URL + "/redirect"))
+	ut.AssertEquals(200, bow.StatusCode())
+
+	ut.AssertNotNil(bow.OpenWithOptions(ts.URL+"/slow", browser.RequestOptions{Timeout: 10 * time.Millisecond}))
+	ut.AssertNotNil(bow.OpenWithOptions(ts.URL+"/large", browser.RequestOptions{MaxBodySize: 1023}))
+	ut.AssertNil(bow.OpenWithOptions(ts.URL+"/large", browser.RequestOptions{MaxBodySize: 1024}))
+	ut.AssertEquals(1024, len(bow.Body()))
+
+	// The options are not used when the page is requested again.
+	ut.AssertNil(bow.OpenWithOptions(ts.URL, opts))
+	ut.AssertNil(bow.Reload())
+	ut.AssertEquals("GET::", bow.Body())
+	ut.AssertNil(bow.OpenWithOptions(ts.URL+"/redirect", browser.RequestOptions{NoFollowRedirects: true}))
+	ut.AssertNil(bow.Reload())
+	ut.AssertEquals(200, bow.StatusCode())
+}
+
 func TestHeaders(t *testing.T) {
 	ut.Run(t)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -91,186 +460,2578 @@ func TestHeaders(t *testing.T) {
 	ut.AssertContains("Testing-2", bow.Body())
 }
 
-func TestBookmarks(t *testing.T) {
+func TestDisableKeepAlives(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		fmt.Fprint(w, htmlPage1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.Close)
 	}))
 	defer ts.Close()
 
-	bookmarks := jar.NewMemoryBookmarks()
 	bow := NewBrowser()
-	bow.SetBookmarksJar(bookmarks)
+	bow.SetMaxIdleConnsPerHost(4)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("false", bow.Body())
 
-	bookmarks.Save("test1", ts.URL)
-	bow.OpenBookmark("test1")
-	ut.AssertEquals("Surf Page 1", bow.Title())
-	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+	bow.SetAttribute(browser.DisableKeepAlives, true)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("true", bow.Body())
+}
 
-	err := bow.Bookmark("test2")
+func TestExpectContinue(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.Header.Get("Expect"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetExpectContinueThreshold(10)
+	err := bow.Post(ts.URL, "text/plain", strings.NewReader("small"))
 	ut.AssertNil(err)
-	bow.OpenBookmark("test2")
-	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals("", bow.Body())
+
+	err = bow.Post(ts.URL, "text/plain", strings.NewReader("a much larger body"))
+	ut.AssertNil(err)
+	ut.AssertEquals("100-continue", bow.Body())
 }
 
-func TestClick(t *testing.T) {
+func TestPostMultipart(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			fmt.Fprint(w, htmlPage1)
-		} else if r.URL.Path == "/page2" {
-			fmt.Fprint(w, htmlPage1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		file, header, err := req.FormFile("avatar")
+		if err != nil {
+			fmt.Fprint(w, err)
+			return
 		}
+		defer file.Close()
+		contents, _ := ioutil.ReadAll(file)
+		fmt.Fprintf(w, "%s %s %s", req.FormValue("user"), header.Filename, contents)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	err := bow.Open(ts.URL)
+	err := bow.PostMultipart(ts.URL, url.Values{"user": {"joe"}}, map[string]io.Reader{
+		"avatar": strings.NewReader("image data"),
+	})
 	ut.AssertNil(err)
+	ut.AssertEquals("joe avatar image data", bow.Body())
 
-	err = bow.Click("a:contains('click')")
-	ut.AssertNil(err)
-	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+	// A streamed body cannot be sent again.
+	err = bow.Resubmit()
+	ut.AssertNotNil(err)
+	ut.AssertContains("cannot be resent", err.Error())
+
+	// The body is not produced for requests which are never sent.
+	file := &recordingReader{Reader: strings.NewReader("image data")}
+	bow.On(browser.BeforeNavigate, func(e *browser.Event) error {
+		return errors.New("vetoed")
+	})
+	err = bow.PostMultipart(ts.URL, nil, map[string]io.Reader{"avatar": file})
+	ut.AssertNotNil(err)
+	ut.AssertFalse(file.read)
 }
 
-func TestLinks(t *testing.T) {
+// recordingReader is an io.Reader which records whether it was read.
+type recordingReader struct {
+	io.Reader
+	read bool
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func TestPostFormFiles(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		fmt.Fprint(w, htmlPage1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		fmt.Fprintf(w, "%s %s", ct, req.FormValue("user"))
+		if file, header, err := req.FormFile("avatar"); err == nil {
+			defer file.Close()
+			contents, _ := ioutil.ReadAll(file)
+			fmt.Fprintf(w, " %s %s", header.Filename, contents)
+		}
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	err := bow.Open(ts.URL)
+	err := bow.PostFormFiles(ts.URL, url.Values{"user": {"joe"}}, nil)
 	ut.AssertNil(err)
+	ut.AssertEquals("application/x-www-form-urlencoded joe", bow.Body())
 
-	links := bow.Links()
-	ut.AssertEquals(2, len(links))
-	ut.AssertEquals("", links[0].ID)
-	ut.AssertEquals(ts.URL+"/page2", links[0].URL.String())
-	ut.AssertEquals("click", links[0].Text)
-	ut.AssertEquals("page3", links[1].ID)
-	ut.AssertEquals(ts.URL+"/page3", links[1].URL.String())
-	ut.AssertEquals("no clicking", links[1].Text)
+	err = bow.PostFormFiles(ts.URL, url.Values{"user": {"joe"}}, map[string]io.Reader{
+		"avatar": strings.NewReader("image data"),
+	})
+	ut.AssertNil(err)
+	ut.AssertEquals("multipart/form-data joe avatar image data", bow.Body())
 }
 
-func TestImages(t *testing.T) {
+func TestHead(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/moved" {
+			http.Redirect(w, req, "/page2", http.StatusFound)
+			return
+		}
+		w.Header().Set("X-Method", req.Method)
 		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
+	ut.AssertTrue(bow.HeadState() == nil)
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
 
-	images := bow.Images()
-	ut.AssertEquals(2, len(images))
-	ut.AssertEquals("imgur-image", images[0].ID)
-	ut.AssertEquals("http://i.imgur.com/HW4bJtY.jpg", images[0].URL.String())
-	ut.AssertEquals("", images[0].Alt)
-	ut.AssertEquals("It's a...", images[0].Title)
-
-	ut.AssertEquals("", images[1].ID)
-	ut.AssertEquals(ts.URL+"/Cxagv.jpg", images[1].URL.String())
-	ut.AssertEquals("A picture", images[1].Alt)
-	ut.AssertEquals("", images[1].Title)
-
-	buff := &bytes.Buffer{}
-	l, err := images[0].Download(buff)
+	err = bow.Head(ts.URL + "/moved")
 	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertEquals(200, bow.HeadState().StatusCode)
+	ut.AssertEquals("HEAD", bow.HeadState().Header.Get("X-Method"))
+	ut.AssertEquals(ts.URL+"/page2", bow.HeadState().URL.String())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(ts.URL, bow.Url().String())
 }
 
-func TestStylesheets(t *testing.T) {
+func TestExists(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/private":
+			if _, err := req.Cookie("session"); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		case "/nohead":
+			if req.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("X-Method", req.Method)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		}
 		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	err := bow.Open(ts.URL)
-	ut.AssertNil(err)
+	ut.AssertNil(bow.Open(ts.URL))
 
-	stylesheets := bow.Stylesheets()
-	ut.AssertEquals(2, len(stylesheets))
-	ut.AssertEquals("http://godoc.org/-/site.css", stylesheets[0].URL.String())
-	ut.AssertEquals("all", stylesheets[0].Media)
-	ut.AssertEquals("text/css", stylesheets[0].Type)
+	hs, err := bow.Exists(ts.URL + "/private")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, hs.StatusCode)
 
-	ut.AssertEquals(ts.URL+"/print.css", stylesheets[1].URL.String())
-	ut.AssertEquals("print", stylesheets[1].Media)
-	ut.AssertEquals("text/css", stylesheets[1].Type)
+	hs, err = bow.Exists(ts.URL + "/nohead")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, hs.StatusCode)
+	ut.AssertEquals("GET", hs.Header.Get("X-Method"))
 
-	buff := &bytes.Buffer{}
-	l, err := stylesheets[0].Download(buff)
+	hs, err = bow.Exists(ts.URL + "/missing")
 	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertEquals(404, hs.StatusCode)
+
+	ut.AssertEquals(ts.URL, bow.Url().String())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertTrue(bow.HeadState() == nil)
 }
 
-func TestScripts(t *testing.T) {
+func TestStatusClassification(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		fmt.Fprint(w, htmlPage1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/moved":
+			http.Redirect(w, req, "/", http.StatusMovedPermanently)
+		}
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	err := bow.Open(ts.URL)
-	ut.AssertNil(err)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertTrue(bow.IsSuccess())
+	ut.AssertFalse(bow.IsClientError())
 
-	scripts := bow.Scripts()
-	ut.AssertEquals(2, len(scripts))
-	ut.AssertEquals("http://godoc.org/-/site.js", scripts[0].URL.String())
-	ut.AssertEquals("text/javascript", scripts[0].Type)
+	ut.AssertNil(bow.Open(ts.URL + "/missing"))
+	ut.AssertTrue(bow.IsClientError())
+	ut.AssertFalse(bow.IsSuccess())
 
-	ut.AssertEquals(ts.URL+"/jquery.min.js", scripts[1].URL.String())
-	ut.AssertEquals("text/javascript", scripts[1].Type)
+	ut.AssertNil(bow.Open(ts.URL + "/broken"))
+	ut.AssertTrue(bow.IsServerError())
 
-	buff := &bytes.Buffer{}
-	l, err := scripts[0].Download(buff)
-	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertNil(bow.Head(ts.URL + "/moved"))
+	ut.AssertTrue(bow.HeadState().IsSuccess())
+	ut.AssertTrue((&browser.HeadState{StatusCode: 302}).IsRedirect())
 }
 
-var htmlPage1 = `<!doctype html>
-<html>
-	<head>
-		<title>Surf Page 1</title>
-		<link href="/favicon.ico" rel="icon" type="image/x-icon">
-		<link href="http://godoc.org/-/site.css" media="all" rel="stylesheet" type="text/css" />
-		<link href="/print.css" rel="stylesheet" media="print" />
-	</head>
-	<body>
-		<p>Hello, Surf!</p>
-		<img src="http://i.imgur.com/HW4bJtY.jpg" id="imgur-image" title="It's a..." />
-		<img src="/Cxagv.jpg" alt="A picture" />
+func TestCredentials(t *testing.T) {
+	ut.Run(t)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "other:"+req.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/away":
+			http.Redirect(w, req, other.URL, http.StatusFound)
+		case "/admin":
+			user, pass, _ := req.BasicAuth()
+			if user != "admin" || pass != "root" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Admin Area"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, "admin:"+user)
+		default:
+			user, _, _ := req.BasicAuth()
+			fmt.Fprint(w, "user:"+user)
+		}
+	}))
+	defer ts.Close()
 
-		<p>Click the link below.</p>
-		<a href="/page2">click</a>
-		<a href="/page3" id="page3">no clicking</a>
+	u, _ := url.Parse(ts.URL)
+	bow := NewBrowser()
+	bow.CredentialsJar().Save(u.Host, "", &jar.Credentials{Username: "joe", Password: "secret"})
+	bow.CredentialsJar().Save(u.Host, "Admin Area", &jar.Credentials{Username: "admin", Password: "root"})
 
-		<script src="http://godoc.org/-/site.js" type="text/javascript"></script>
-		<script src="/jquery.min.js" type="text/javascript"></script>
-		<script type="text/javascript">
-			var _gaq = _gaq || [];
-		</script>
-	</body>
-</html>
-`
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("user:joe", bow.Body())
 
-var htmlPage2 = `<!doctype html>
-<html>
-	<head>
-		<title>Surf Page 2</title>
-	</head>
+	ut.AssertNil(bow.Open(ts.URL + "/admin"))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("admin:admin", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL + "/away"))
+	ut.AssertEquals("other:", bow.Body())
+
+	bow.AddRequestHeader("Authorization", "Bearer caller")
+	ut.AssertNil(bow.Open(other.URL))
+	ut.AssertEquals("other:Bearer caller", bow.Body())
+
+	o, _ := url.Parse(other.URL)
+	bow.CredentialsJar().Save("https://"+o.Host, "", &jar.Credentials{Token: "tls-only"})
+	ut.AssertNil(bow.Open(other.URL))
+	ut.AssertEquals("other:Bearer caller", bow.Body())
+	bow.CredentialsJar().Save("http://"+o.Host, "", &jar.Credentials{Token: "plain"})
+	ut.AssertNil(bow.Open(other.URL))
+	ut.AssertEquals("other:Bearer plain", bow.Body())
+}
+
+func TestAuthPreemption(t *testing.T) {
+	ut.Run(t)
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sent = append(sent, req.Header.Get("Authorization"))
+		if _, _, ok := req.BasicAuth(); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="site"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	bow := NewBrowser()
+	bow.SetAttribute(browser.PreemptiveAuth, false)
+	bow.CredentialsJar().Save(u.Host, "", &jar.Credentials{Username: "joe", Password: "secret"})
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals(2, len(sent))
+	ut.AssertEquals("", sent[0])
+	ut.AssertEquals("Basic am9lOnNlY3JldA==", sent[1])
+
+	sent = nil
+	bow.SetAttribute(browser.RetryAuthChallenges, false)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(401, bow.StatusCode())
+	ut.AssertEquals(1, len(sent))
+
+	sent = nil
+	bow.SetAttribute(browser.PreemptiveAuth, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals(1, len(sent))
+}
+
+func TestProxyCredentials(t *testing.T) {
+	ut.Run(t)
+	challenges := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Proxy-Authorization") != "Basic am9lOnNlY3JldA==" {
+			challenges++
+			w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		fmt.Fprint(w, "proxied:"+req.URL.String())
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.User = url.UserPassword("joe", "secret")
+	bow := NewBrowser()
+	bow.SetProxy(u)
+
+	ut.AssertNil(bow.Open("http://example.com/page1"))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("proxied:http://example.com/page1", bow.Body())
+	ut.AssertEquals(1, challenges)
+
+	ut.AssertNil(bow.Open("http://example.com/page2"))
+	ut.AssertEquals("proxied:http://example.com/page2", bow.Body())
+	ut.AssertEquals(1, challenges)
+
+	bow.SetProxyCredentials(nil)
+	ut.AssertNil(bow.Open("http://example.com/page3"))
+	ut.AssertEquals(407, bow.StatusCode())
+}
+
+func TestCookieEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/set":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			http.SetCookie(w, &http.Cookie{Name: "tracker", Value: "1"})
+			http.SetCookie(w, &http.Cookie{Name: "foreign", Value: "1", Domain: "example.com"})
+		case "/update":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "2"})
+		case "/expire":
+			http.SetCookie(w, &http.Cookie{Name: "session", MaxAge: -1})
+		case "/paths":
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Path: "/a"})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "light", Path: "/b"})
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	names := map[browser.EventType]string{
+		browser.CookieSet:     "set",
+		browser.CookieUpdated: "updated",
+		browser.CookieExpired: "expired",
+		browser.CookieBlocked: "blocked",
+	}
+	var events []string
+	bow := NewBrowser()
+	for et, name := range names {
+		name := name
+		bow.On(et, func(e *browser.Event) error {
+			ut.AssertEquals(ts.URL, "http://"+e.URL.Host)
+			events = append(events, name+":"+e.Cookie.Name)
+			if e.Type == browser.CookieSet && e.Cookie.Name == "tracker" {
+				return errors.New("blocked")
+			}
+			return nil
+		})
+	}
+
+	ut.AssertNil(bow.Open(ts.URL + "/set"))
+	ut.AssertEquals(1, len(bow.SiteCookies()))
+	ut.AssertNil(bow.Open(ts.URL + "/update"))
+	ut.AssertEquals("2", bow.SiteCookies()[0].Value)
+	ut.AssertNil(bow.Open(ts.URL + "/expire"))
+	ut.AssertEquals(0, len(bow.SiteCookies()))
+	ut.AssertNil(bow.Open(ts.URL + "/paths"))
+	ut.AssertNil(bow.Open(ts.URL + "/paths"))
+	ut.AssertEquals([]string{
+		"set:session",
+		"set:tracker",
+		"blocked:tracker",
+		"blocked:foreign",
+		"updated:session",
+		"expired:session",
+		"set:theme",
+		"set:theme",
+	}, events)
+}
+
+func TestPinnedCookies(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/set":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: req.URL.Query().Get("v")})
+		case "/redirect":
+			http.Redirect(w, req, "/echo", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, req.Header.Get("Cookie"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/set?v=1"))
+	ut.AssertNil(bow.Open(ts.URL + "/echo"))
+	ut.AssertEquals("session=1", bow.Body())
+
+	u, _ := url.Parse(ts.URL)
+	bow.PinCookieHeader(u.Hostname(), `b=2;a="1"`)
+	ut.AssertNil(bow.Open(ts.URL + "/redirect"))
+	ut.AssertEquals(`b=2;a="1"`, bow.Body())
+	bow.UnpinCookieHeader(u.Hostname())
+	ut.AssertNil(bow.Open(ts.URL + "/echo"))
+	ut.AssertEquals("session=1", bow.Body())
+
+	bow.FreezeCookies(true)
+	ut.AssertNil(bow.Open(ts.URL + "/set?v=2"))
+	ut.AssertEquals("session=1", bow.Body())
+	bow.FreezeCookies(false)
+	ut.AssertNil(bow.Open(ts.URL + "/set?v=3"))
+	ut.AssertNil(bow.Open(ts.URL + "/echo"))
+	ut.AssertEquals("session=3", bow.Body())
+}
+
+func TestAssetDownloadEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/image.png" {
+			fmt.Fprint(w, "image data")
+			return
+		}
+		fmt.Fprint(w, `<html><body>
+			<img src="/image.png">
+			<img src="http://127.0.0.1:1/refused.png">
+			<img src="/blocked.png">
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	var events []string
+	bow := NewBrowser()
+	bow.On(browser.AssetDownloadStart, func(e *browser.Event) error {
+		events = append(events, "start:"+e.URL.Path)
+		if e.URL.Path == "/blocked.png" {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+	bow.On(browser.AssetDownloadComplete, func(e *browser.Event) error {
+		events = append(events, fmt.Sprintf("complete:%s:%d", e.URL.Path, e.Size))
+		ut.AssertTrue(e.Duration > 0)
+		return nil
+	})
+	bow.On(browser.AssetDownloadError, func(e *browser.Event) error {
+		events = append(events, "error:"+e.URL.Path)
+		ut.AssertNotNil(e.Err)
+		return nil
+	})
+
+	ut.AssertNil(bow.Open(ts.URL))
+	images := bow.Images()
+	ut.AssertEquals(3, len(images))
+	out := &bytes.Buffer{}
+	_, err := images[0].Download(out)
+	ut.AssertNil(err)
+	ut.AssertEquals("image data", out.String())
+	_, err = images[1].Download(out)
+	ut.AssertNotNil(err)
+	_, err = images[2].Download(out)
+	ut.AssertNotNil(err)
+
+	ut.AssertEquals([]string{
+		"start:/image.png",
+		"complete:/image.png:10",
+		"start:/refused.png",
+		"error:/refused.png",
+		"start:/blocked.png",
+	}, events)
+}
+
+func TestNavigationEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<html><head><title>`+req.URL.Path+`</title></head><body>
+			<a href="/clicked">Click</a>
+			<a href="/private">Private</a>
+			<form method="post" action="/submitted"><input name="q" value="1"></form>
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	var events []string
+	bow := NewBrowser()
+	bow.On(browser.BeforeNavigate, func(e *browser.Event) error {
+		events = append(events, "before:"+e.Trigger.String()+":"+e.URL.Path)
+		if e.URL.Path == "/private" {
+			return errors.New("cancelled")
+		}
+		return nil
+	})
+	bow.On(browser.PageLoaded, func(e *browser.Event) error {
+		events = append(events, "loaded:"+e.Trigger.String()+":"+bow.Title())
+		return nil
+	})
+
+	ut.AssertNil(bow.Open(ts.URL + "/opened"))
+	ut.AssertNil(bow.Click("a:contains('Click')"))
+	ut.AssertNotNil(bow.Click("a:contains('Private')"))
+	ut.AssertEquals("/clicked", bow.Url().Path)
+	fm, err := bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(fm.Submit())
+	bow.On(browser.BeforeResubmit, func(e *browser.Event) error {
+		return nil
+	})
+	ut.AssertNil(bow.Reload())
+
+	ut.AssertEquals([]string{
+		"before:open:/opened",
+		"loaded:open:/opened",
+		"before:click:/clicked",
+		"loaded:click:/clicked",
+		"before:click:/private",
+		"before:submit:/submitted",
+		"loaded:submit:/submitted",
+		"before:refresh:/submitted",
+		"loaded:refresh:/submitted",
+	}, events)
+}
+
+func TestAsyncEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><head><title>"+req.URL.Path+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var archived []string
+	bow := NewBrowser()
+	bow.OnAsync(browser.PageLoaded, func(e *browser.Event) error {
+		<-release
+		mu.Lock()
+		archived = append(archived, e.URL.Path)
+		mu.Unlock()
+		return errors.New("disk full: " + e.URL.Path)
+	})
+	bow.OnAsync(browser.BeforeNavigate, func(e *browser.Event) error {
+		return nil
+	})
+
+	ut.AssertNil(bow.Open(ts.URL + "/page1"))
+	ut.AssertNil(bow.Open(ts.URL + "/page2"))
+	ut.AssertEquals("/page2", bow.Title())
+	close(release)
+
+	errs := bow.Wait()
+	ut.AssertEquals(2, len(errs))
+	ut.AssertEquals(2, len(archived))
+	ut.AssertEquals(0, len(bow.Wait()))
+}
+
+func TestHandlerPanic(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><head><title>"+req.URL.Path+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	recovered := make(map[string]bool)
+	bow := NewBrowser()
+	bow.On(browser.Error, func(e *browser.Event) error {
+		mu.Lock()
+		recovered[e.Err.Error()] = true
+		mu.Unlock()
+		panic("error handler panicked too")
+	})
+	bow.OnAsync(browser.PageLoaded, func(e *browser.Event) error {
+		var m map[string]int
+		m["crash"]++
+		return nil
+	})
+	bow.On(browser.PageLoaded, func(e *browser.Event) error {
+		panic("buggy handler")
+	})
+
+	ut.AssertNil(bow.Open(ts.URL + "/page1"))
+	ut.AssertEquals("/page1", bow.Title())
+	errs := bow.Wait()
+	ut.AssertEquals(1, len(errs))
+	_, ok := errs[0].(surferrors.HandlerPanic)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(2, len(recovered))
+	ut.AssertTrue(recovered["Handler Panic: buggy handler"])
+}
+
+func TestMiddleware(t *testing.T) {
+	ut.Run(t)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		fmt.Fprint(w, req.Header.Get("X-Trace"))
+	}))
+	defer ts.Close()
+
+	var calls []string
+	bow := NewBrowser()
+	bow.Use(func(next browser.RoundTripFunc) browser.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "outer:"+req.URL.Path)
+			resp, err := next(req)
+			calls = append(calls, "outer:done")
+			return resp, err
+		}
+	})
+	bow.Use(func(next browser.RoundTripFunc) browser.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, "inner:"+req.URL.Path)
+			if req.URL.Path == "/cached" {
+				return &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{"Content-Type": {"text/plain"}},
+					Body:       ioutil.NopCloser(strings.NewReader("from cache")),
+					Request:    req,
+				}, nil
+			}
+			req.Header.Set("X-Trace", "traced")
+			return next(req)
+		}
+	})
+
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertEquals("traced", bow.Body())
+	ut.AssertNil(bow.Open(ts.URL + "/cached"))
+	ut.AssertEquals("from cache", bow.Body())
+	ut.AssertEquals(1, requests)
+	ut.AssertEquals([]string{
+		"outer:/page",
+		"inner:/page",
+		"outer:done",
+		"outer:/cached",
+		"inner:/cached",
+		"outer:done",
+	}, calls)
+}
+
+// countingStates is a jar.StateJar which counts page loads.
+type countingStates struct {
+	jar.MemoryState
+	loads int
+}
+
+func (cs *countingStates) SetCurrent(s *jar.State) {
+	cs.loads++
+	cs.MemoryState.SetCurrent(s)
+}
+
+func TestClose(t *testing.T) {
+	ut.Run(t)
+	var mu sync.Mutex
+	loads := 0
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0.05"></head></html>`)
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	dir, err := ioutil.TempDir("", "surf-close-")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	bookmarks := dir + "/bookmarks.json"
+	bj, err := jar.NewFileBookmarks(bookmarks)
+	ut.AssertNil(err)
+
+	bow := NewBrowser()
+	bow.SetBookmarksJar(bj)
+	ut.AssertNil(bow.Open(ts.URL))
+	errs := make(chan error)
+	go func() {
+		errs <- bow.Open(ts.URL + "/slow")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	os.Remove(bookmarks)
+	ut.AssertNil(bow.Close())
+	ut.AssertNotNil(<-errs)
+	_, err = os.Stat(bookmarks)
+	ut.AssertNil(err)
+
+	mu.Lock()
+	n := loads
+	mu.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	mu.Lock()
+	ut.AssertEquals(n, loads)
+	mu.Unlock()
+	ut.AssertNotNil(bow.Open(ts.URL))
+}
+
+func TestStateJar(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+
+	states := &countingStates{}
+	bow.SetStateJar(states)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(1, states.loads)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(2, states.loads)
+	ut.AssertEquals(ts.URL, states.Current().Request.URL.String())
+}
+
+func TestTabs(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "user", Value: "joe"})
+		}
+		user := ""
+		if c, err := req.Cookie("user"); err == nil {
+			user = c.Value
+		}
+		fmt.Fprint(w, "<html><head><title>"+req.URL.Path+":"+user+"</title></head></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals(1, bow.TabCount())
+	ut.AssertNil(bow.Open(ts.URL + "/login"))
+	ut.AssertNil(bow.Open(ts.URL + "/page1"))
+
+	ut.AssertNil(bow.OpenInNewTab(ts.URL + "/page2"))
+	ut.AssertEquals(2, bow.TabCount())
+	ut.AssertEquals(1, bow.ActiveTab())
+	ut.AssertEquals("/page2:joe", bow.Title())
+	ut.AssertFalse(bow.Back())
+
+	ut.AssertNil(bow.OpenInNewTab(ts.URL + "/page3"))
+	ut.AssertNil(bow.SwitchTab(0))
+	ut.AssertEquals("/page1:joe", bow.Title())
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("/login:", bow.Title())
+	ut.AssertNotNil(bow.SwitchTab(3))
+
+	ut.AssertNil(bow.SwitchTab(2))
+	ut.AssertNil(bow.CloseTab(2))
+	ut.AssertEquals(2, bow.TabCount())
+	ut.AssertEquals(1, bow.ActiveTab())
+	ut.AssertEquals("/page2:joe", bow.Title())
+
+	ut.AssertNil(bow.CloseTab(0))
+	ut.AssertEquals(0, bow.ActiveTab())
+	ut.AssertEquals("/page2:joe", bow.Title())
+	ut.AssertNotNil(bow.CloseTab(0))
+}
+
+func TestBaseUrl(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<html><head><base href="/static/"></head><body>
+			<a href="page.html">Page</a>
+			<img src="logo.png">
+			<form method="get" action="search"><input name="q" value="surf"></form>
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/docs/index.html"))
+	ut.AssertEquals(ts.URL+"/static/", bow.ResolutionContext().Base.String())
+	ut.AssertEquals(ts.URL+"/static/page.html", bow.Links()[0].Url().String())
+	ut.AssertEquals(ts.URL+"/static/logo.png", bow.Images()[0].Url().String())
+	u, err := bow.ResolveStringUrl("other.html")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/static/other.html", u)
+
+	fm, err := bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(fm.Submit())
+	ut.AssertEquals("/static/search", bow.Url().Path)
+}
+
+func TestBookmarks(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bookmarks := jar.NewMemoryBookmarks()
+	bow := NewBrowser()
+	bow.SetBookmarksJar(bookmarks)
+
+	bookmarks.Save("test1", ts.URL)
+	bow.OpenBookmark("test1")
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+
+	err := bow.Bookmark("test2")
+	ut.AssertNil(err)
+	bow.OpenBookmark("test2")
+	ut.AssertEquals("Surf Page 1", bow.Title())
+}
+
+func TestClick(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlPage1)
+		} else if r.URL.Path == "/page2" {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	err = bow.Click("a:contains('click')")
+	ut.AssertNil(err)
+	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+}
+
+func TestDocument(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("Surf Page 1", bow.Document().Find("title").Text())
+	ut.AssertEquals(html.DocumentNode, bow.Node().Type)
+	ut.AssertEquals("html", bow.Node().FirstChild.NextSibling.Data)
+
+	buf := &bytes.Buffer{}
+	ut.AssertNil(browser.RenderNode(buf, bow.Find("p").First()))
+	ut.AssertEquals("<p>Hello, Surf!</p>", buf.String())
+}
+
+func TestPage(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			fmt.Fprint(w, "posted "+r.URL.Path)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>`+r.URL.Path+`</title></head><body>
+			<a href="next">next</a>
+			<img src="a.png">
+			<form method="post" action="save"></form>
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	page, err := bow.OpenPage(ts.URL + "/dir/first")
+	ut.AssertNil(err)
+	other, err := bow.OpenPage(ts.URL + "/other")
+	ut.AssertNil(err)
+	ut.AssertEquals("/other", other.Title())
+	history := bow.HistoryPages()
+	ut.AssertEquals(1, len(history))
+	ut.AssertEquals("/dir/first", history[0].Title())
+	_, err = bow.OpenPage("http://127.0.0.1:1/")
+	ut.AssertNotNil(err)
+
+	// The document of the page is parsed lazily by whichever goroutine
+	// needs it first.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ut.AssertEquals(1, len(page.Links()))
+		}()
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ut.AssertEquals("/dir/first", page.Title())
+		ut.AssertEquals(200, page.StatusCode())
+		ut.AssertEquals(ts.URL+"/dir/next", page.Links()[0].URL.String())
+		ut.AssertEquals(ts.URL+"/dir/a.png", page.Images()[0].URL.String())
+		ut.AssertEquals(2, len(page.Assets(browser.LinkAsset, browser.ImageAsset)))
+	}()
+	<-done
+
+	ut.AssertEquals(1, len(page.Forms()))
+	f, err := page.Form("form")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/dir/save", f.Action())
+	ut.AssertEquals(ts.URL+"/next", bow.Links()[0].URL.String())
+	ut.AssertNil(f.Submit())
+	ut.AssertEquals("posted /dir/save", bow.Body())
+	ut.AssertEquals("/other", bow.BackPage().Title())
+}
+
+func TestFollowLink(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlPage1)
+		} else {
+			fmt.Fprint(w, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	err := bow.FollowLink(browser.HrefMatches(regexp.MustCompile(`/page\d$`)))
+	ut.AssertNil(err)
+	ut.AssertEquals("/page2", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	err = bow.FollowLink(browser.LinkFunc(func(l *browser.Link) bool {
+		return l.ID == "page3"
+	}))
+	ut.AssertNil(err)
+	ut.AssertEquals("/page3", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	err = bow.FollowLink(browser.HrefMatches(regexp.MustCompile(`/page4`)))
+	nm, ok := err.(surferrors.NoMatchingLink)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(2, len(nm.Candidates))
+	ut.AssertEquals(ts.URL+"/page2", nm.Candidates[0])
+}
+
+func TestImageMetadata(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><body>
+				<img src="/a.png" width="640" height=" 480 " loading="lazy" decoding="async" srcset="/a2.png 2x">
+				<img src="/b.png" width="50%">
+			</body></html>`)
+			return
+		}
+		ut.AssertEquals("HEAD", r.Method)
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", "1234")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	images := bow.Images()
+	ut.AssertEquals(2, len(images))
+	ut.AssertEquals(640, images[0].Width)
+	ut.AssertEquals(480, images[0].Height)
+	ut.AssertEquals("lazy", images[0].Loading)
+	ut.AssertEquals("async", images[0].Decoding)
+	ut.AssertTrue(images[0].HasSrcset)
+	ut.AssertEquals(0, images[1].Width)
+	ut.AssertFalse(images[1].HasSrcset)
+
+	probe, err := images[0].Probe()
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusOK, probe.StatusCode)
+	ut.AssertEquals(int64(1234), probe.ContentLength)
+	ut.AssertEquals("image/png", probe.ContentType)
+	ut.AssertEquals(ts.URL, bow.Url().String())
+}
+
+func TestAssets(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html>
+			<head>
+				<link rel="shortcut icon" href="/favicon.ico">
+				<link rel="apple-touch-icon" href="/touch.png" sizes="180x180">
+				<link rel="stylesheet" href="/site.css">
+			</head>
+			<body>
+				<a href="/page2">page2</a>
+				<img src="/a.png">
+				<video src="/movie.mp4"></video>
+				<audio><source src="/song.ogg" type="audio/ogg"></audio>
+				<script src="/app.js"></script>
+			</body>
+		</html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+
+	media := bow.Media()
+	ut.AssertEquals(2, len(media))
+	ut.AssertEquals("video", media[0].Tag)
+	ut.AssertEquals("audio", media[1].Tag)
+	ut.AssertEquals("audio/ogg", media[1].Type)
+	ut.AssertEquals(ts.URL+"/song.ogg", media[1].URL.String())
+
+	icons := bow.Favicons()
+	ut.AssertEquals(2, len(icons))
+	ut.AssertEquals(ts.URL+"/favicon.ico", icons[0].URL.String())
+	ut.AssertEquals("180x180", icons[1].Sizes)
+
+	ut.AssertEquals(8, len(bow.Assets()))
+	assets := bow.Assets(browser.FaviconAsset, browser.ImageAsset)
+	ut.AssertEquals(3, len(assets))
+	ut.AssertEquals(browser.FaviconAsset, assets[0].AssetType())
+	ut.AssertEquals(browser.ImageAsset, assets[2].AssetType())
+	ut.AssertEquals(ts.URL+"/a.png", assets[2].Url().String())
+}
+
+func TestLinks(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	links := bow.Links()
+	ut.AssertEquals(2, len(links))
+	ut.AssertEquals("", links[0].ID)
+	ut.AssertEquals(ts.URL+"/page2", links[0].URL.String())
+	ut.AssertEquals("click", links[0].Text)
+	ut.AssertEquals("page3", links[1].ID)
+	ut.AssertEquals(ts.URL+"/page3", links[1].URL.String())
+	ut.AssertEquals("no clicking", links[1].Text)
+}
+
+func TestImages(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	images := bow.Images()
+	ut.AssertEquals(2, len(images))
+	ut.AssertEquals("imgur-image", images[0].ID)
+	ut.AssertEquals("http://i.imgur.com/HW4bJtY.jpg", images[0].URL.String())
+	ut.AssertEquals("", images[0].Alt)
+	ut.AssertEquals("It's a...", images[0].Title)
+
+	ut.AssertEquals("", images[1].ID)
+	ut.AssertEquals(ts.URL+"/Cxagv.jpg", images[1].URL.String())
+	ut.AssertEquals("A picture", images[1].Alt)
+	ut.AssertEquals("", images[1].Title)
+
+	buff := &bytes.Buffer{}
+	l, err := images[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestStylesheets(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	stylesheets := bow.Stylesheets()
+	ut.AssertEquals(2, len(stylesheets))
+	ut.AssertEquals("http://godoc.org/-/site.css", stylesheets[0].URL.String())
+	ut.AssertEquals("all", stylesheets[0].Media)
+	ut.AssertEquals("text/css", stylesheets[0].Type)
+
+	ut.AssertEquals(ts.URL+"/print.css", stylesheets[1].URL.String())
+	ut.AssertEquals("print", stylesheets[1].Media)
+	ut.AssertEquals("text/css", stylesheets[1].Type)
+
+	buff := &bytes.Buffer{}
+	l, err := stylesheets[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestScripts(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	scripts := bow.Scripts()
+	ut.AssertEquals(2, len(scripts))
+	ut.AssertEquals("http://godoc.org/-/site.js", scripts[0].URL.String())
+	ut.AssertEquals("text/javascript", scripts[0].Type)
+
+	ut.AssertEquals(ts.URL+"/jquery.min.js", scripts[1].URL.String())
+	ut.AssertEquals("text/javascript", scripts[1].Type)
+
+	buff := &bytes.Buffer{}
+	l, err := scripts[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestCSPViolations(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src *")
+		w.Header().Set("Content-Security-Policy-Report-Only", "img-src 'self'")
+		fmt.Fprint(w, `<html><head>
+			<link rel="stylesheet" href="/site.css">
+			<link rel="stylesheet" href="http://cdn.example.com/theme.css">
+			<script src="http://cdn.example.com/app.js"></script>
+		</head><body><img src="http://images.example.com/a.png"><img src="/b.png"></body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	policies := bow.CSP()
+	ut.AssertEquals(2, len(policies))
+	ut.AssertEquals([]string{"'self'"}, policies[0].Directives["default-src"])
+	ut.AssertFalse(policies[0].ReportOnly)
+	ut.AssertTrue(policies[1].ReportOnly)
+
+	violations := bow.CSPViolations()
+	var found []string
+	for _, v := range violations {
+		found = append(found, fmt.Sprintf("%s %v %s", v.Directive, v.Policy.ReportOnly, v.Asset.Url()))
+	}
+	ut.AssertEquals([]string{
+		"script-src false http://cdn.example.com/app.js",
+		"style-src false http://cdn.example.com/theme.css",
+		"img-src true http://images.example.com/a.png",
+	}, found)
+}
+
+func TestResourceHints(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	hints := bow.ResourceHints()
+	ut.AssertEquals(3, len(hints))
+	ut.AssertEquals(ts.URL+"/fonts/surf.woff2", hints[0].URL.String())
+	ut.AssertEquals("preload", hints[0].Rel)
+	ut.AssertEquals("font", hints[0].As)
+	ut.AssertEquals("prefetch", hints[1].Rel)
+	ut.AssertEquals("modulepreload", hints[2].Rel)
+	ut.AssertEquals("", hints[2].As)
+}
+
+func TestConnectionHints(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	hints := bow.ConnectionHints()
+	ut.AssertEquals(2, len(hints))
+	ut.AssertEquals("dns-prefetch", hints[0].Rel)
+	ut.AssertEquals("http://cdn.example.com", hints[0].Origin.String())
+	ut.AssertEquals("preconnect", hints[1].Rel)
+	ut.AssertEquals("https://fonts.example.com", hints[1].Origin.String())
+}
+
+func TestRobotsMeta(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("X-Robots-Tag", "noarchive")
+		w.Header().Add("X-Robots-Tag", "otherbot: noindex")
+		fmt.Fprint(w, `<html><head><meta name="ROBOTS" content="NoFollow"></head></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	robots := bow.RobotsMeta()
+	ut.AssertFalse(robots.NoIndex)
+	ut.AssertTrue(robots.NoFollow)
+	ut.AssertTrue(robots.NoArchive)
+	ut.AssertEquals(2, len(robots.Directives))
+}
+
+func TestDescriptionKeywords(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/og" {
+			fmt.Fprint(w, `<html><head>
+				<meta property="og:description" content="Open Graph description">
+				<meta property="article:tag" content="go">
+				<meta property="article:tag" content="surf">
+			</head></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><head>
+			<meta name="Description" content=" A virtual browser. ">
+			<meta property="og:description" content="Open Graph description">
+			<meta name="keywords" content="go, browser,,scraping">
+		</head></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("A virtual browser.", bow.Description())
+	ut.AssertEquals([]string{"go", "browser", "scraping"}, bow.Keywords())
+
+	ut.AssertNil(bow.Open(ts.URL + "/og"))
+	ut.AssertEquals("Open Graph description", bow.Description())
+	ut.AssertEquals([]string{"go", "surf"}, bow.Keywords())
+}
+
+func TestLanguage(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/lang":
+			fmt.Fprint(w, `<html lang="ja"><body>surf</body></html>`)
+		case "/header":
+			w.Header().Set("Content-Language", "de-DE, en")
+			fmt.Fprint(w, `<html><body>surf</body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body>bonjour</body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/lang"))
+	ut.AssertEquals("ja", bow.Language())
+	ut.AssertNil(bow.Open(ts.URL + "/header"))
+	ut.AssertEquals("de-DE", bow.Language())
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("", bow.Language())
+
+	bow.SetLanguageDetector(func(text string) string {
+		if strings.Contains(text, "bonjour") {
+			return "fr"
+		}
+		return ""
+	})
+	ut.AssertEquals("fr", bow.Language())
+}
+
+func TestCharset(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// "Привет" encoded as windows-1251.
+		w.Write([]byte("<html><head><title>\xcf\xf0\xe8\xe2\xe5\xf2</title></head></html>"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNotNil(bow.SetCharset("no-such-charset"))
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNotEquals("Привет", bow.Title())
+
+	ut.AssertNil(bow.SetCharset("windows-1251"))
+	ut.AssertEquals("Привет", bow.Title())
+	ut.AssertNil(bow.Reload())
+	ut.AssertEquals("Привет", bow.Title())
+}
+
+var htmlPage1 = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Page 1</title>
+		<link href="/favicon.ico" rel="icon" type="image/x-icon">
+		<link href="http://godoc.org/-/site.css" media="all" rel="stylesheet" type="text/css" />
+		<link href="/print.css" rel="stylesheet" media="print" />
+		<link href="/fonts/surf.woff2" rel="preload" as="font" crossorigin>
+		<link href="/page2" rel="prefetch" as="document">
+		<link href="/app.mjs" rel="modulepreload">
+		<link href="//cdn.example.com" rel="dns-prefetch">
+		<link href="https://fonts.example.com/" rel="preconnect" crossorigin>
+	</head>
+	<body>
+		<p>Hello, Surf!</p>
+		<img src="http://i.imgur.com/HW4bJtY.jpg" id="imgur-image" title="It's a..." />
+		<img src="/Cxagv.jpg" alt="A picture" />
+
+		<p>Click the link below.</p>
+		<a href="/page2">click</a>
+		<a href="/page3" id="page3">no clicking</a>
+
+		<script src="http://godoc.org/-/site.js" type="text/javascript"></script>
+		<script src="/jquery.min.js" type="text/javascript"></script>
+		<script type="text/javascript">
+			var _gaq = _gaq || [];
+		</script>
+	</body>
+</html>
+`
+
+var htmlPage2 = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Page 2</title>
+	</head>
 	<body>
 		<p>Hello, Surf!</p>
 	</body>
 </html>
 `
+
+func TestHTTPOnly(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Fast</title></head><body><a href="/a">a</a></body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.HTTPOnly, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("", bow.Title())
+	ut.AssertEquals(0, len(bow.Links()))
+	ut.AssertContains("<title>Fast</title>", bow.Body())
+
+	bow.SetAttribute(browser.HTTPOnly, false)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("Fast", bow.Title())
+	ut.AssertEquals(1, len(bow.Links()))
+}
+
+func TestConditionalRequests(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "<html><body>"+req.URL.Path+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertFalse(bow.NotModified())
+	ut.AssertEquals(200, bow.StatusCode())
+
+	bow.SetAttribute(browser.ConditionalRequests, true)
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+	ut.AssertTrue(bow.NotModified())
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("/page", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL + "/other"))
+	ut.AssertFalse(bow.NotModified())
+	ut.AssertEquals("/other", bow.Body())
+	v, ok := bow.ValidatorJar().Read(ts.URL + "/other")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(`"v1"`, v.ETag)
+}
+
+func TestReload(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			req.ParseForm()
+			fmt.Fprint(w, "<html><body>posted "+req.PostForm.Encode()+"</body></html>")
+			return
+		}
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "<html><body>"+req.Header.Get("Cache-Control")+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.ConditionalRequests, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Reload())
+	ut.AssertTrue(bow.NotModified())
+	ut.AssertNil(bow.ReloadFromOrigin())
+	ut.AssertFalse(bow.NotModified())
+	ut.AssertEquals("no-cache", bow.Body())
+
+	ut.AssertNil(bow.PostForm(ts.URL, url.Values{"a": {"1"}}))
+	ut.AssertEquals("posted a=1", bow.Body())
+	ut.AssertNotNil(bow.Reload())
+
+	confirm := errors.New("declined")
+	bow.On(browser.BeforeResubmit, func(e *browser.Event) error {
+		return confirm
+	})
+	ut.AssertEquals(confirm, bow.Reload())
+	confirm = nil
+	ut.AssertNil(bow.Reload())
+	ut.AssertEquals("posted a=1", bow.Body())
+}
+
+func TestResubmit(t *testing.T) {
+	ut.Run(t)
+	posts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			posts++
+			fmt.Fprintf(w, "<html><body>posted %d</body></html>", posts)
+			return
+		}
+		fmt.Fprint(w, "<html><body>"+req.URL.Path+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.RefetchOnBack, true)
+	ut.AssertNil(bow.Open(ts.URL + "/a"))
+	ut.AssertFalse(bow.NeedsResubmit())
+	ut.AssertNil(bow.PostForm(ts.URL, url.Values{"a": {"1"}}))
+	ut.AssertTrue(bow.NeedsResubmit())
+	ut.AssertNotNil(bow.Reload())
+	ut.AssertNil(bow.Resubmit())
+	ut.AssertEquals("posted 2", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("posted 2", bow.Body())
+	ut.AssertEquals(2, posts)
+
+	var triggers []browser.NavigationTrigger
+	bow.On(browser.BeforeResubmit, func(e *browser.Event) error {
+		triggers = append(triggers, e.Trigger)
+		return nil
+	})
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("posted 3", bow.Body())
+	ut.AssertEquals([]browser.NavigationTrigger{browser.TriggerBack}, triggers)
+}
+
+func TestBackAndReload(t *testing.T) {
+	ut.Run(t)
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		hits[req.URL.Path]++
+		n := hits[req.URL.Path]
+		mu.Unlock()
+		fmt.Fprintf(w, "<html><body>%s %d</body></html>", req.URL.Path, n)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/a"))
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("/a 1", bow.Body())
+
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertNil(bow.BackAndReload())
+	ut.AssertEquals("/a 2", bow.Body())
+	ut.AssertNotNil(bow.BackAndReload())
+
+	bow.SetAttribute(browser.RefetchOnBack, true)
+	ut.AssertNil(bow.Open(ts.URL + "/b"))
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("/a 3", bow.Body())
+	ut.AssertFalse(bow.Back())
+}
+
+func TestPageLoadTimeout(t *testing.T) {
+	ut.Run(t)
+	var mu sync.Mutex
+	refreshes := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/redirect":
+			time.Sleep(30 * time.Millisecond)
+			http.Redirect(w, req, "/redirect", http.StatusFound)
+		case "/refresh":
+			mu.Lock()
+			refreshes++
+			mu.Unlock()
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0.02"></head></html>`)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetPageLoadTimeout(100 * time.Millisecond)
+	ut.AssertNil(bow.Open(ts.URL))
+	err := bow.Open(ts.URL + "/redirect")
+	terr, ok := err.(surferrors.PageLoadTimeout)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(ts.URL+"/redirect", terr.URL)
+
+	ut.AssertNil(bow.Open(ts.URL + "/refresh"))
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	n := refreshes
+	mu.Unlock()
+	ut.AssertGreaterThan(1, n)
+	ut.AssertTrue(n < 8)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	ut.AssertEquals(n, refreshes)
+	mu.Unlock()
+	bow.Close()
+}
+
+func TestSession(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "joe", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "gone", Value: "x", MaxAge: -1})
+		}
+		c, _ := req.Cookie("session")
+		if c == nil {
+			c = &http.Cookie{}
+		}
+		fmt.Fprint(w, req.URL.Path+":"+c.Value+":"+req.Header.Get("X-Worker"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.AddRequestHeader("X-Worker", "1")
+	bow.SetAttribute(browser.SendReferer, false)
+	ut.AssertNil(bow.Open(ts.URL + "/login"))
+	ut.AssertNil(bow.Bookmark("home"))
+
+	session, err := bow.Session()
+	ut.AssertNil(err)
+	ut.AssertEquals(1, len(session.Cookies))
+	ut.AssertEquals(ts.URL+"/login", session.URL)
+
+	var buf bytes.Buffer
+	ut.AssertNil(json.NewEncoder(&buf).Encode(session))
+	var shipped browser.Session
+	ut.AssertNil(json.NewDecoder(&buf).Decode(&shipped))
+
+	worker := NewBrowser()
+	ut.AssertNil(worker.RestoreSession(&shipped))
+	ut.AssertNil(worker.Open(ts.URL + "/page"))
+	ut.AssertEquals("/page:joe:1", worker.Body())
+	ut.AssertNil(worker.OpenBookmark("home"))
+	ut.AssertEquals("/login:joe:1", worker.Body())
+
+	cj, _ := cookiejar.New(nil)
+	bow.SetCookieJar(cj)
+	_, err = bow.Session()
+	ut.AssertNotNil(err)
+}
+
+func TestStats(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><body>hello</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL))
+	_, err := bow.Exists(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertNotNil(bow.Open("http://127.0.0.1:1/"))
+
+	stats := bow.Stats()
+	ut.AssertEquals(int64(4), stats.Requests)
+	ut.AssertEquals(int64(0), stats.InFlight)
+	ut.AssertEquals(int64(2), stats.Pages)
+	ut.AssertEquals(int64(1), stats.Errors)
+	ut.AssertEquals(0.25, stats.ErrorRate)
+
+	bow.PublishStats("surf_test_stats")
+	ut.AssertEquals(
+		`{"requests":4,"in_flight":0,"pages":2,"errors":1,"error_rate":0.25,"compressed_bytes":0,"decompressed_bytes":0}`,
+		expvar.Get("surf_test_stats").String())
+}
+
+func TestCompressionAndLength(t *testing.T) {
+	ut.Run(t)
+	page := strings.Repeat("<p>hello</p>", 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/gzip":
+			ut.AssertEquals("gzip", req.Header.Get("Accept-Encoding"))
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, page)
+			gz.Close()
+		case "/truncated":
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 100\r\n\r\n<p>short")
+			buf.Flush()
+			conn.Close()
+		default:
+			fmt.Fprint(w, page)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.RetryTransportErrors, false)
+	ut.AssertNil(bow.Open(ts.URL + "/gzip"))
+	ut.AssertEquals(100, bow.Find("p").Length())
+	ut.AssertNil(bow.Open(ts.URL + "/plain"))
+	stats := bow.Stats()
+	ut.AssertGreaterThan(0, int(stats.CompressedBytes))
+	ut.AssertEquals(int64(len(page)), stats.DecompressedBytes)
+
+	err := bow.Open(ts.URL + "/truncated")
+	terr, ok := err.(surferrors.TruncatedResponse)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(int64(100), terr.Expected)
+	ut.AssertEquals(int64(8), terr.Received)
+	ut.AssertEquals(ts.URL+"/plain", bow.Url().String())
+}
+
+func TestContentEncodings(t *testing.T) {
+	ut.Run(t)
+	page := "<html><head><title>Encoded</title></head></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zlib":
+			w.Header().Set("Content-Encoding", "deflate")
+			zw := zlib.NewWriter(w)
+			fmt.Fprint(zw, page)
+			zw.Close()
+		case "/raw":
+			w.Header().Set("Content-Encoding", "deflate")
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			fmt.Fprint(fw, page)
+			fw.Close()
+		case "/empty":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, page)
+			gz.Close()
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/zlib"))
+	ut.AssertEquals("Encoded", bow.Title())
+	ut.AssertNil(bow.Open(ts.URL + "/raw"))
+	ut.AssertEquals("Encoded", bow.Title())
+	ut.AssertNil(bow.Open(ts.URL + "/empty"))
+	ut.AssertEquals(204, bow.StatusCode())
+	ut.AssertNil(bow.Head(ts.URL + "/empty"))
+
+	// Every GET asks for gzip, so a 304 declaring the encoding of the page it
+	// validates must not be decoded.
+	bow = NewBrowser()
+	bow.SetAttribute(browser.ConditionalRequests, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Reload())
+	ut.AssertTrue(bow.NotModified())
+	ut.AssertEquals("Encoded", bow.Title())
+}
+
+func TestTraffic(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+
+	host := ts.Listener.Addr().String()
+	traffic := bow.DomainTraffic(host)
+	ut.AssertEquals(int64(2), traffic.Requests)
+	ut.AssertEquals(int64(200), traffic.Bytes)
+	ut.AssertEquals(traffic, bow.Traffic()["127.0.0.1"])
+
+	bow.ResetTraffic()
+	ut.AssertEquals(0, len(bow.Traffic()))
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	ut.Run(t)
+	var mutex sync.Mutex
+	var active, most int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			mutex.Lock()
+			active++
+			if active > most {
+				most = active
+			}
+			mutex.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+			http.ServeContent(w, req, "image.png", time.Time{}, strings.NewReader(strings.Repeat("image data", 100)))
+			return
+		}
+		fmt.Fprint(w, `<html><body>
+			<img src="/a.png"><img src="/b.png"><img src="/c.png">
+		</body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetMaxConcurrentRequests(2)
+	ut.AssertNil(bow.Open(ts.URL))
+	ch := make(browser.AsyncDownloadChannel, 1)
+	for _, img := range bow.Images() {
+		img.DownloadAsync(ioutil.Discard, ch)
+	}
+	for i := 0; i < 3; i++ {
+		result := <-ch
+		ut.AssertNil(result.Error)
+	}
+	ut.AssertEquals(2, most)
+
+	most = 0
+	bow.SetMaxConcurrentRequests(1)
+	for _, img := range bow.Images() {
+		img.DownloadAsync(ioutil.Discard, ch)
+	}
+	for i := 0; i < 3; i++ {
+		<-ch
+	}
+	ut.AssertEquals(1, most)
+	ut.AssertNil(bow.Open(ts.URL))
+
+	// Each range request of a segmented download takes a slot.
+	out, err := ioutil.TempFile("", "surf")
+	ut.AssertNil(err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+	most = 0
+	bow.SetMaxConcurrentRequests(2)
+	l, err := bow.Images()[0].DownloadSegmented(out, 4)
+	ut.AssertNil(err)
+	ut.AssertEquals(1000, int(l))
+	ut.AssertEquals(2, most)
+}
+
+func TestRetryTransportErrors(t *testing.T) {
+	ut.Run(t)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1)%2 == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, req.Method)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.DisableKeepAlives, true)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("GET", bow.Body())
+	ut.AssertEquals(int32(2), atomic.LoadInt32(&requests))
+
+	ut.AssertNotNil(bow.Post(ts.URL, "text/plain", strings.NewReader("body")))
+	ut.AssertEquals(int32(3), atomic.LoadInt32(&requests))
+
+	bow.SetAttribute(browser.RetryTransportErrors, false)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNotNil(bow.Open(ts.URL))
+	ut.AssertEquals(int32(5), atomic.LoadInt32(&requests))
+}
+
+func TestOpenTemplate(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.URL.Path+":"+req.URL.Query().Get("tab"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.OpenTemplate(ts.URL+"/user/{id}?tab={tab}", map[string]string{"id": "7", "tab": "a b"}))
+	ut.AssertEquals("/user/7:a b", bow.Body())
+
+	bookmarks := jar.NewMemoryBookmarks()
+	ut.AssertNil(bookmarks.Save("user", ts.URL+"/user/{id}"))
+	bow.SetBookmarksJar(bookmarks)
+	ut.AssertNil(bow.OpenBookmarkTemplate("user", map[string]string{"id": "8"}))
+	ut.AssertEquals("/user/8:", bow.Body())
+	ut.AssertNotNil(bow.OpenBookmarkTemplate("user", nil))
+}
+
+func TestEarlyHintsAndTrailer(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Link", "</style.css>; rel=preload; as=style, </next>; rel=next")
+		w.Header().Add("Link", `<https://cdn.example.com/app.js>; rel="modulepreload"`)
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "<html><body>hinted</body></html>")
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	var fired []*browser.ResourceHint
+	bow.On(browser.EarlyHints, func(e *browser.Event) error {
+		fired = append(fired, e.Hints...)
+		return nil
+	})
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("abc123", bow.Trailer().Get("X-Checksum"))
+
+	hints := bow.EarlyHints()
+	ut.AssertEquals(2, len(hints))
+	ut.AssertEquals(ts.URL+"/style.css", hints[0].Url().String())
+	ut.AssertEquals("preload", hints[0].Rel)
+	ut.AssertEquals("style", hints[0].As)
+	ut.AssertEquals("https://cdn.example.com/app.js", hints[1].Url().String())
+	ut.AssertEquals("modulepreload", hints[1].Rel)
+	ut.AssertEquals(2, len(fired))
+	ut.AssertEquals(0, len(bow.Page().ResourceHints()))
+}
+
+func TestLabels(t *testing.T) {
+	ut.Run(t)
+	var seen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/away" {
+			http.Redirect(w, req, "/home", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "<html><body>home</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.Use(func(next browser.RoundTripFunc) browser.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			labels := browser.Labels(req)
+			seen = append(seen, labels["job"]+"/"+labels["seed"])
+			return next(req)
+		}
+	})
+	var events []string
+	handler := func(e *browser.Event) error {
+		events = append(events, e.Labels["job"]+"/"+e.Labels["seed"])
+		return nil
+	}
+	bow.On(browser.BeforeNavigate, handler)
+	bow.On(browser.PageLoaded, handler)
+
+	bow.SetLabels(map[string]string{"job": "42"})
+	ut.AssertNil(bow.OpenWithOptions(ts.URL+"/away", browser.RequestOptions{
+		Labels: map[string]string{"seed": ts.URL},
+	}))
+	ut.AssertEquals([]string{"42/" + ts.URL}, seen)
+	ut.AssertEquals([]string{"42/" + ts.URL, "42/" + ts.URL}, events)
+
+	seen, events = nil, nil
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals([]string{"42/"}, seen)
+	ut.AssertEquals([]string{"42/", "42/"}, events)
+}
+
+func TestLenientParsing(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			fmt.Fprint(w, "<p>not compressed</p>")
+		case "/truncated":
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 100\r\n\r\n<p>short")
+			buf.Flush()
+			conn.Close()
+		case "/latin1":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><head><title>Caf\xe9</title></head></html>"))
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.RetryTransportErrors, false)
+	var parseErrors []error
+	bow.On(browser.ParseError, func(e *browser.Event) error {
+		parseErrors = append(parseErrors, e.Err)
+		return nil
+	})
+	ut.AssertNotNil(bow.Open(ts.URL + "/gzip"))
+	ut.AssertNotNil(bow.Open(ts.URL + "/truncated"))
+	ut.AssertNil(bow.Open(ts.URL + "/latin1"))
+	ut.AssertEquals("Caf\xe9", bow.Title())
+	ut.AssertEquals(0, len(parseErrors))
+
+	bow.SetAttribute(browser.LenientParsing, true)
+	ut.AssertNil(bow.Open(ts.URL + "/gzip"))
+	ut.AssertEquals("not compressed", bow.Find("p").Text())
+	ut.AssertEquals(1, len(parseErrors))
+
+	ut.AssertNil(bow.Open(ts.URL + "/truncated"))
+	ut.AssertEquals("short", bow.Find("p").Text())
+	raw, err := bow.RawBody()
+	ut.AssertNil(err)
+	ut.AssertEquals("<p>short", string(raw))
+	ut.AssertEquals(2, len(parseErrors))
+	_, ok := parseErrors[1].(surferrors.TruncatedResponse)
+	ut.AssertTrue(ok)
+
+	ut.AssertNil(bow.Open(ts.URL + "/latin1"))
+	ut.AssertEquals("Café", bow.Title())
+	ut.AssertEquals(3, len(parseErrors))
+
+	// Handlers of the ParseError event may read the page being parsed.
+	bow = NewBrowser()
+	bow.SetAttribute(browser.LenientParsing, true)
+	var titles []string
+	bow.On(browser.ParseError, func(e *browser.Event) error {
+		titles = append(titles, bow.Title())
+		return nil
+	})
+	ut.AssertNil(bow.Open(ts.URL + "/latin1"))
+	ut.AssertEquals("Café", bow.Title())
+	ut.AssertEquals([]string{"Café"}, titles)
+}
+
+func TestSiteProfile(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "visited", Value: "1"})
+		fmt.Fprintf(w, "%s|%s|%s", req.UserAgent(), req.Header.Get("X-Site"), req.Header.Get("Cookie"))
+	}))
+	defer ts.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "proxied:"+req.URL.String())
+	}))
+	defer proxy.Close()
+
+	local := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	proxyURL, _ := url.Parse(proxy.URL)
+	bow := NewBrowser()
+	bow.SetUserAgent("default")
+	bow.SiteProfile("127.0.0.*", browser.Profile{
+		UserAgent: "profiled",
+		Headers:   http.Header{"X-Site": {"ip"}},
+		Cookies:   browser.CookiesBlock,
+		Delay:     50 * time.Millisecond,
+	})
+	bow.SiteProfile("*.example.com", browser.Profile{Proxy: proxyURL})
+
+	start := time.Now()
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("profiled|ip|", bow.Body())
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("profiled|ip|", bow.Body())
+	ut.AssertTrue(time.Since(start) >= 50*time.Millisecond)
+
+	ut.AssertNil(bow.Open(local))
+	ut.AssertEquals("default||", bow.Body())
+	ut.AssertNil(bow.Open(local))
+	ut.AssertEquals("default||visited=1", bow.Body())
+
+	ut.AssertNil(bow.Open("http://www.example.com/page"))
+	ut.AssertEquals("proxied:http://www.example.com/page", bow.Body())
+
+	bow.RemoveSiteProfile("127.0.0.*")
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("default||", bow.Body())
+}
+
+func TestHandleContentType(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"name":"surf"}`)
+		case "/logo.png", "/img/..\\..\\logo.png", "/img/..":
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "PNG")
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, "p {}")
+		default:
+			fmt.Fprint(w, "<html><head><title>Home</title></head></html>")
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf-handlers-")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	var handled []string
+	var data struct{ Name string }
+	bow := NewBrowser()
+	bow.HandleContentType("text/html", func(p *browser.Page) error {
+		handled = append(handled, "html:"+p.Title())
+		return nil
+	})
+	bow.HandleContentType("application/json", func(p *browser.Page) error {
+		handled = append(handled, "json")
+		return p.DecodeJSON(&data)
+	})
+	bow.HandleContentType("image/*", browser.SaveToDir(dir))
+	bow.HandleContentType("*/*", func(p *browser.Page) error {
+		return errors.New("unexpected " + p.Url().Path)
+	})
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Open(ts.URL + "/data.json"))
+	ut.AssertEquals("surf", data.Name)
+	ut.AssertNil(bow.Open(ts.URL + "/logo.png"))
+	host := strings.Replace(bow.Url().Host, ":", "_", -1)
+	b, err := ioutil.ReadFile(filepath.Join(dir, host, "logo.png"))
+	ut.AssertNil(err)
+	ut.AssertEquals("PNG", string(b))
+	ut.AssertNil(bow.Open(ts.URL + "/img/..%5C..%5Clogo.png"))
+	_, err = os.Stat(filepath.Join(dir, host, ".._.._logo.png"))
+	ut.AssertNil(err)
+	ut.AssertNil(bow.Open(ts.URL + "/img/%2E%2E"))
+	_, err = os.Stat(filepath.Join(dir, host, "index"))
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"html:Home", "json"}, handled)
+
+	err = bow.Open(ts.URL + "/style.css")
+	ut.AssertEquals("unexpected /style.css", err.Error())
+	ut.AssertEquals(ts.URL+"/style.css", bow.Url().String())
+
+	bow.HandleContentType("*/*", nil)
+	ut.AssertNil(bow.Open(ts.URL + "/style.css"))
+}
+
+func TestKeepAlive(t *testing.T) {
+	ut.Run(t)
+	var mu sync.Mutex
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		methods = append(methods, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+		if req.URL.Path == "/expired" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "fresh"})
+		fmt.Fprint(w, "<html><body>"+req.URL.Path+"</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNotNil(bow.KeepAlive("", time.Millisecond))
+	ut.AssertNil(bow.Open(ts.URL + "/page"))
+
+	events := make(chan *browser.Event, 100)
+	handler := func(e *browser.Event) error {
+		events <- e
+		return nil
+	}
+	bow.On(browser.KeepAliveComplete, handler)
+	bow.On(browser.KeepAliveError, handler)
+
+	ut.AssertNil(bow.KeepAlive(ts.URL+"/ping", 10*time.Millisecond))
+	e := <-events
+	ut.AssertEquals(browser.KeepAliveComplete, e.Type)
+	ut.AssertEquals(ts.URL+"/ping", e.URL.String())
+
+	ut.AssertNil(bow.KeepAlive("", 10*time.Millisecond))
+	for e = <-events; e.URL.Path != "/page"; e = <-events {
+	}
+	ut.AssertEquals(browser.KeepAliveComplete, e.Type)
+
+	ut.AssertNil(bow.KeepAlive(ts.URL+"/expired", 10*time.Millisecond))
+	for e = <-events; e.Type != browser.KeepAliveError; e = <-events {
+	}
+	ut.AssertEquals(ts.URL+"/expired", e.URL.String())
+	ut.AssertNil(bow.Close())
+
+	ut.AssertEquals(ts.URL+"/page", bow.Url().String())
+	mu.Lock()
+	defer mu.Unlock()
+	ut.AssertEquals("GET /page", methods[0])
+	ut.AssertEquals("GET /ping", methods[1])
+	ut.AssertTrue(len(methods) > 3)
+}
+
+func TestChallenges(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `<html><head><title>Login</title></head><body><div class="g-recaptcha"></div></body></html>`)
+			return
+		case "/blocked":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `<html><head><title>Blocked</title></head><body><div class="h-captcha"></div></body></html>`)
+			return
+		}
+		if c, err := req.Cookie("cf_clearance"); err == nil && c.Value == "ok" {
+			fmt.Fprint(w, "<html><head><title>Content</title></head></html>")
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `<html><head><title>Just a moment...</title></head><body><div id="cf-browser-verification"></div></body></html>`)
+	}))
+	defer ts.Close()
+
+	cookies := jar.NewMemoryCookies()
+	bow := NewBrowser()
+	bow.SetCookieJar(cookies)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(503, bow.StatusCode())
+
+	var challenges []string
+	bow.DetectChallenges()
+	bow.On(browser.Challenge, func(e *browser.Event) error {
+		challenges = append(challenges, e.Challenge)
+		return nil
+	})
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals("Just a moment...", bow.Title())
+	ut.AssertEquals([]string{"cloudflare"}, challenges)
+
+	bow.SetChallengeSolver(func(p *browser.Page, signature string) error {
+		return nil
+	})
+	err := bow.Open(ts.URL)
+	cerr, ok := err.(surferrors.Challenge)
+	ut.AssertTrue(ok)
+	ut.AssertEquals("cloudflare", cerr.Signature)
+
+	bow.SetChallengeSolver(func(p *browser.Page, signature string) error {
+		ut.AssertEquals(503, p.StatusCode())
+		cookies.SetCookies(p.Url(), []*http.Cookie{{Name: "cf_clearance", Value: "ok"}})
+		return nil
+	})
+	ut.AssertNil(bow.Open(ts.URL + "/again"))
+	ut.AssertEquals("Content", bow.Title())
+	ut.AssertEquals(3, len(challenges))
+
+	ut.AssertNil(bow.Open(ts.URL + "/third"))
+	ut.AssertEquals(3, len(challenges))
+
+	// Pages which embed a captcha widget are only challenges when they are
+	// served with an error status.
+	ut.AssertNil(bow.Open(ts.URL + "/login"))
+	ut.AssertEquals("Login", bow.Title())
+	ut.AssertEquals(3, len(challenges))
+
+	dir, err := ioutil.TempDir("", "surf-challenges-")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	bow.SetSpoolThreshold(10, dir)
+	err = bow.Open(ts.URL + "/blocked")
+	cerr, ok = err.(surferrors.Challenge)
+	ut.AssertTrue(ok)
+	ut.AssertEquals("captcha", cerr.Signature)
+	files, _ := ioutil.ReadDir(dir)
+	ut.AssertEquals(0, len(files))
+}
+
+func TestHandleConsent(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/consent" && req.Method == "POST":
+			http.SetCookie(w, &http.Cookie{Name: "consent", Value: req.FormValue("choice")})
+			http.Redirect(w, req, "/", http.StatusFound)
+		case req.URL.Path == "/links":
+			fmt.Fprint(w, `<html><body><div class="cookie-banner"><a href="#">Accept</a><a href="/consent/decline">Decline</a></div></body></html>`)
+		case req.URL.Path == "/consent/decline":
+			fmt.Fprint(w, "<html><head><title>Declined</title></head></html>")
+		case req.URL.Path == "/none":
+			fmt.Fprint(w, `<html><body><button>Accept all</button></body></html>`)
+		default:
+			if c, err := req.Cookie("consent"); err == nil {
+				fmt.Fprintf(w, "<html><head><title>Content %s</title></head></html>", c.Value)
+				return
+			}
+			fmt.Fprint(w, `<html><head><title>Consent</title></head><body>
+				<form id="onetrust-banner-sdk" method="post" action="/consent">
+					<button id="onetrust-accept-btn-handler" name="choice" value="accept">Accept</button>
+					<button id="onetrust-reject-all-handler" name="choice" value="reject">Reject</button>
+				</form></body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	_, err := bow.HandleConsent(browser.ConsentAccept)
+	ut.AssertNotNil(err)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	handled, err := bow.HandleConsent(browser.ConsentReject)
+	ut.AssertNil(err)
+	ut.AssertTrue(handled)
+	ut.AssertEquals("Content reject", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL + "/links"))
+	handled, err = bow.HandleConsent(browser.ConsentReject)
+	ut.AssertNil(err)
+	ut.AssertTrue(handled)
+	ut.AssertEquals("Declined", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL + "/links"))
+	handled, err = bow.HandleConsent(browser.ConsentAccept)
+	ut.AssertNil(err)
+	ut.AssertFalse(handled)
+
+	ut.AssertNil(bow.Open(ts.URL + "/none"))
+	handled, err = bow.HandleConsent(browser.ConsentAccept)
+	ut.AssertNil(err)
+	ut.AssertFalse(handled)
+}
+
+func TestFetchLocales(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/":
+			w.Header().Add("Link", `</fr/>; rel="alternate"; hreflang="fr", </style.css>; rel=preload; as=style`)
+			fmt.Fprint(w, `<html><head><title>Home</title>
+				<link rel="alternate" hreflang="en-GB" href="/en-gb/">
+				<link rel="alternate" hreflang="de" href="/de/">
+				<link rel="alternate" hreflang="x-default" href="/">
+				<link rel="alternate" hreflang="de" href="/de-again/">
+				<link rel="stylesheet" hreflang="ja" href="/ja.css">
+				</head></html>`)
+		case "/de/":
+			http.NotFound(w, req)
+		default:
+			fmt.Fprintf(w, "<html><head><title>Home %s</title></head></html>", req.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	_, err := bow.FetchLocales(2)
+	ut.AssertNotNil(err)
+
+	ut.AssertNil(bow.Open(ts.URL))
+	alts := bow.Alternates()
+	ut.AssertEquals(4, len(alts))
+	ut.AssertEquals("en-gb", alts[0].Lang)
+	ut.AssertEquals(ts.URL+"/en-gb/", alts[0].URL.String())
+	ut.AssertEquals("de", alts[1].Lang)
+	ut.AssertEquals(ts.URL+"/de/", alts[1].URL.String())
+	ut.AssertEquals("x-default", alts[2].Lang)
+	ut.AssertEquals("fr", alts[3].Lang)
+	ut.AssertEquals(ts.URL+"/fr/", alts[3].URL.String())
+
+	pages, err := bow.FetchLocales(2)
+	ut.AssertNil(err)
+	ut.AssertEquals(4, len(pages))
+	ut.AssertEquals("Home /en-gb/", pages["en-gb"].Title())
+	ut.AssertEquals(404, pages["de"].StatusCode())
+	ut.AssertEquals("Home", pages["x-default"].Title())
+	ut.AssertEquals("Home /fr/", pages["fr"].Title())
+	ut.AssertEquals("Home", bow.Title())
+	ut.AssertEquals(ts.URL, bow.Url().String())
+}
+
+func TestOpenAMP(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/article":
+			fmt.Fprint(w, `<html><head><title>Article</title>
+				<link rel="alternate" hreflang="fr" media="only screen and (max-width: 640px)" href="/fr/m/article">
+				<link rel="alternate" media="only screen and (max-width: 640px)" href="http://m.example.com/article">
+				<link rel="amphtml" href="amp/article">
+				</head></html>`)
+		case "/amp/article":
+			fmt.Fprintf(w, "<html><head><title>AMP %s</title></head></html>", req.Referer())
+		default:
+			fmt.Fprint(w, "<html><head><title>Plain</title></head></html>")
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL + "/article"))
+	ut.AssertEquals(ts.URL+"/amp/article", bow.AMPUrl().String())
+	ut.AssertEquals("http://m.example.com/article", bow.MobileAlternateUrl().String())
+	ut.AssertNil(bow.OpenAMP())
+	ut.AssertEquals("AMP "+ts.URL+"/article", bow.Title())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertTrue(bow.AMPUrl() == nil)
+	ut.AssertTrue(bow.MobileAlternateUrl() == nil)
+	err := bow.OpenAMP()
+	_, ok := err.(surferrors.LinkNotFound)
+	ut.AssertTrue(ok)
+	_, ok = bow.OpenMobileAlternate().(surferrors.LinkNotFound)
+	ut.AssertTrue(ok)
+}
+
+func TestDownloadAs(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/latin1":
+			w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+			fmt.Fprint(w, "<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-1\"><title>Caf\xe9</title></head></html>")
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprint(w, "Café 日")
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><HEAD><title>Café 日</title></HEAD></html>")
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	buff := &bytes.Buffer{}
+	_, err := bow.DownloadAs(buff, "nope")
+	ut.AssertNotNil(err)
+
+	n, err := bow.DownloadAs(buff, "latin1")
+	ut.AssertNil(err)
+	ut.AssertEquals(int64(buff.Len()), n)
+	ut.AssertEquals("<html><HEAD><meta charset=\"windows-1252\"><title>Caf\xe9 &#26085;</title></HEAD></html>", buff.String())
+
+	ut.AssertNil(bow.Open(ts.URL + "/latin1"))
+	buff.Reset()
+	_, err = bow.DownloadAs(buff, "utf-8")
+	ut.AssertNil(err)
+	ut.AssertEquals("<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=utf-8\"><title>Café</title></head></html>", buff.String())
+
+	ut.AssertNil(bow.Open(ts.URL + "/text"))
+	buff.Reset()
+	_, err = bow.DownloadAs(buff, "iso-8859-1")
+	ut.AssertNil(err)
+	ut.AssertEquals("Caf\xe9 &#26085;", buff.String())
+}
+
+func TestAuditJar(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/old" {
+			http.Redirect(w, req, "/new", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>Page</title></head><body><a href="/old">Old</a><img src="/old"></body></html>`)
+	}))
+	defer ts.Close()
+
+	buff := &bytes.Buffer{}
+	bow := NewBrowser()
+	bow.SetAuditJar(jar.NewAuditLog(buff))
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.Click("a"))
+	_, err := bow.Peek(ts.URL + "/peek")
+	ut.AssertNil(err)
+	ut.AssertNil(jar.VerifyAuditLog(bytes.NewReader(buff.Bytes())))
+
+	var records []jar.AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(buff.String()), "\n") {
+		var rec jar.AuditRecord
+		ut.AssertNil(json.Unmarshal([]byte(line), &rec))
+		records = append(records, rec)
+	}
+	ut.AssertEquals(4, len(records))
+	ut.AssertEquals(ts.URL, records[0].URL)
+	ut.AssertEquals("open", records[0].Trigger)
+	ut.AssertEquals(200, records[0].Status)
+	ut.AssertGreaterThan(0, int(records[0].Bytes))
+	ut.AssertGreaterThan(0, int(records[0].Duration))
+	ut.AssertEquals(ts.URL+"/old", records[1].URL)
+	ut.AssertEquals(302, records[1].Status)
+	ut.AssertEquals("click", records[1].Trigger)
+	ut.AssertEquals(ts.URL+"/new", records[2].URL)
+	ut.AssertEquals("click", records[2].Trigger)
+	ut.AssertEquals(ts.URL+"/peek", records[3].URL)
+	ut.AssertEquals("", records[3].Trigger)
+
+	// The assets found by the browser are downloaded by the browser.
+	ut.AssertNil(bow.Open(ts.URL))
+	buff.Reset()
+	_, err = browser.DownloadAsset(bow.Images()[0], ioutil.Discard)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, strings.Count(buff.String(), "\n"))
+	ut.AssertContains(ts.URL+"/new", buff.String())
+
+	bow.SetAuditJar(nil)
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertEquals(2, strings.Count(buff.String(), "\n"))
+}
+
+func TestWaitFor(t *testing.T) {
+	ut.Run(t)
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/never" {
+			fmt.Fprint(w, "<html><body><p>Processing...</p></body></html>")
+			return
+		}
+		if atomic.AddInt32(&hits, 1) < 3 {
+			fmt.Fprint(w, "<html><body><p>Processing...</p></body></html>")
+			return
+		}
+		fmt.Fprint(w, `<html><body><table id="results"></table></body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNotNil(bow.WaitFor("#results", time.Second, 10*time.Millisecond))
+
+	ut.AssertNil(bow.Open(ts.URL))
+	ut.AssertNil(bow.WaitFor("#results", time.Second, 10*time.Millisecond))
+	ut.AssertEquals(int32(3), atomic.LoadInt32(&hits))
+	ut.AssertNil(bow.WaitFor("#results", time.Second, 10*time.Millisecond))
+	ut.AssertEquals(int32(3), atomic.LoadInt32(&hits))
+
+	ut.AssertNil(bow.Open(ts.URL + "/never"))
+	err := bow.WaitFor("#results", 50*time.Millisecond, 10*time.Millisecond)
+	werr, ok := err.(surferrors.WaitTimeout)
+	ut.AssertTrue(ok)
+	ut.AssertEquals("#results", werr.Selector)
+	ut.AssertEquals(ts.URL+"/never", werr.URL)
+}
+
+func TestLinkContext(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html><body>
+		<a href="/top">Top</a>
+		<h1>News</h1>
+		<div><ul>
+			<li><a href="/one" rel="NoFollow ugc">One</a> first story</li>
+			<li><span><a href="/two">Two</a></span></li>
+		</ul></div>
+		<section><h2>Sports <a href="/sports">all</a></h2>
+			<p><a href="/three">Three</a></p>
+		</section>
+		<a href="/four">Four</a>
+	</body></html>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.Open(ts.URL))
+	links := bow.Links()
+	ut.AssertEquals(6, len(links))
+	for i, l := range links {
+		ut.AssertEquals(i, l.Position)
+	}
+	ut.AssertEquals("", links[0].Heading())
+	ut.AssertEquals("", links[0].ListItem())
+	ut.AssertEquals("News", links[1].Heading())
+	ut.AssertEquals("One first story", links[1].ListItem())
+	ut.AssertEquals([]string{"nofollow", "ugc"}, links[1].Rel)
+	ut.AssertEquals("News", links[2].Heading())
+	ut.AssertEquals("Two", links[2].ListItem())
+	ut.AssertEquals(0, len(links[2].Rel))
+	ut.AssertEquals("Sports all", links[3].Heading())
+	ut.AssertEquals("Sports all", links[4].Heading())
+	ut.AssertEquals("Sports all", links[5].Heading())
+}
+
+func TestHistoryAndBookmarkEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", req.URL.Path)
+	}))
+	defer ts.Close()
+
+	var events []string
+	bow := NewBrowser()
+	for _, typ := range []browser.EventType{browser.HistoryPushed, browser.HistoryPopped, browser.BookmarkSaved, browser.BookmarkRemoved} {
+		bow.On(typ, func(e *browser.Event) error {
+			switch e.Type {
+			case browser.HistoryPushed:
+				ut.AssertEquals(e.URL, e.State.Request.URL)
+				events = append(events, "pushed "+e.URL.Path)
+			case browser.HistoryPopped:
+				ut.AssertEquals(e.URL, e.State.Request.URL)
+				events = append(events, "popped "+e.URL.Path)
+			case browser.BookmarkSaved:
+				events = append(events, "saved "+e.Bookmark+" "+e.URL.Path)
+			case browser.BookmarkRemoved:
+				events = append(events, "removed "+e.Bookmark+" "+e.URL.Path)
+			}
+			return nil
+		})
+	}
+
+	ut.AssertNil(bow.Open(ts.URL + "/one"))
+	ut.AssertNil(bow.Open(ts.URL + "/two"))
+	ut.AssertNil(bow.Bookmark("two"))
+	ut.AssertTrue(bow.Back())
+	ut.AssertFalse(bow.Back())
+	ut.AssertTrue(bow.RemoveBookmark("two"))
+	ut.AssertFalse(bow.RemoveBookmark("two"))
+	ut.AssertEquals([]string{
+		"pushed /one",
+		"saved two /two",
+		"popped /one",
+		"removed two /two",
+	}, events)
+}
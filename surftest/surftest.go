@@ -0,0 +1,103 @@
+// Package surftest contains assertions for test suites which drive a browser.
+package surftest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/haruyama/surf/browser"
+)
+
+// AssertTitle asserts the page title equals the expected value.
+func AssertTitle(t testing.TB, bow browser.Browsable, expected string) bool {
+	t.Helper()
+	return assertEqual(t, "title", expected, bow.Title())
+}
+
+// AssertStatus asserts the response status code equals the expected value.
+func AssertStatus(t testing.TB, bow browser.Browsable, expected int) bool {
+	t.Helper()
+	actual := bow.StatusCode()
+	if actual != expected {
+		t.Errorf("status mismatch for %s:\n  expected: %d %s\n  actual:   %d %s",
+			bow.Url(), expected, statusText(expected), actual, statusText(actual))
+		return false
+	}
+	return true
+}
+
+// AssertSelectorText asserts the trimmed text of the elements matching the
+// selector equals the expected value.
+func AssertSelectorText(t testing.TB, bow browser.Browsable, selector, expected string) bool {
+	t.Helper()
+	sel := bow.Find(selector)
+	if sel.Length() == 0 {
+		t.Errorf("no elements match selector %q on %s", selector, bow.Url())
+		return false
+	}
+	return assertEqual(t, fmt.Sprintf("text of %q", selector), expected, strings.TrimSpace(sel.Text()))
+}
+
+// AssertFormField asserts the field of the form matching formExpr has the
+// expected value.
+func AssertFormField(t testing.TB, bow browser.Browsable, formExpr, field, expected string) bool {
+	t.Helper()
+	form, err := bow.Form(formExpr)
+	if err != nil {
+		t.Errorf("form %q: %s", formExpr, err)
+		return false
+	}
+	actual, ok := form.Field(field)
+	if !ok {
+		t.Errorf("form %q has no field %q", formExpr, field)
+		return false
+	}
+	return assertEqual(t, fmt.Sprintf("field %q of form %q", field, formExpr), expected, actual)
+}
+
+// assertEqual reports an error showing where the strings differ when they are
+// not equal.
+func assertEqual(t testing.TB, what, expected, actual string) bool {
+	t.Helper()
+	if expected == actual {
+		return true
+	}
+	t.Errorf("%s mismatch:\n  expected: %q\n  actual:   %q\n%s", what, expected, actual, diff(expected, actual))
+	return false
+}
+
+// diffContext is the number of characters shown around the first difference.
+const diffContext = 20
+
+// diff describes the first position at which the strings differ.
+func diff(expected, actual string) string {
+	i := 0
+	for i < len(expected) && i < len(actual) && expected[i] == actual[i] {
+		i++
+	}
+	start := i - diffContext
+	if start < 0 {
+		start = 0
+	}
+	return fmt.Sprintf("  first difference at byte %d:\n  expected: ...%q\n  actual:   ...%q",
+		i, snippet(expected, start, i), snippet(actual, start, i))
+}
+
+// snippet returns the part of s surrounding the offset.
+func snippet(s string, start, offset int) string {
+	end := offset + diffContext
+	if end > len(s) {
+		end = len(s)
+	}
+	if start > len(s) {
+		start = len(s)
+	}
+	return s[start:end]
+}
+
+// statusText returns the text for the HTTP status code.
+func statusText(code int) string {
+	return "(" + http.StatusText(code) + ")"
+}
@@ -0,0 +1,66 @@
+package surftest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haruyama/surf/browser"
+	"github.com/haruyama/surf/jar"
+	"github.com/headzoo/ut"
+)
+
+// recorder is a testing.TB which records errors instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage)
+	}))
+	defer ts.Close()
+
+	bow := &browser.Browser{}
+	bow.SetHeadersJar(jar.NewMemoryHeaders())
+	bow.SetHistoryJar(jar.NewMemoryHistory())
+	ut.AssertNil(bow.Open(ts.URL))
+
+	rec := &recorder{TB: t}
+	ut.AssertTrue(AssertTitle(rec, bow, "Surf Test"))
+	ut.AssertTrue(AssertStatus(rec, bow, 200))
+	ut.AssertTrue(AssertSelectorText(rec, bow, "h1", "Welcome"))
+	ut.AssertTrue(AssertFormField(rec, bow, "form", "user", "joe"))
+	ut.AssertEquals(0, len(rec.errors))
+
+	ut.AssertFalse(AssertTitle(rec, bow, "Surf Tests"))
+	ut.AssertFalse(AssertStatus(rec, bow, 404))
+	ut.AssertFalse(AssertSelectorText(rec, bow, "h2", "Welcome"))
+	ut.AssertFalse(AssertFormField(rec, bow, "form", "password", ""))
+	ut.AssertEquals(4, len(rec.errors))
+	ut.AssertContains("first difference at byte 9", rec.errors[0])
+	ut.AssertContains("404 (Not Found)", rec.errors[1])
+}
+
+var htmlPage = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Test</title>
+	</head>
+	<body>
+		<h1> Welcome </h1>
+		<form method="post" action="/">
+			<input type="text" name="user" value="joe" />
+		</form>
+	</body>
+</html>
+`